@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"financial-reporting-system/internal/auth"
+	"financial-reporting-system/internal/budgets"
 	"financial-reporting-system/internal/cache"
 	"financial-reporting-system/internal/config"
 	dbconn "financial-reporting-system/internal/db"
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/fx"
+	"financial-reporting-system/internal/jobs"
+	"financial-reporting-system/internal/journal"
+	"financial-reporting-system/internal/mail"
+	"financial-reporting-system/internal/periods"
 	"financial-reporting-system/internal/reports"
+	"financial-reporting-system/internal/savedreports"
+	"financial-reporting-system/internal/schedules"
 	"financial-reporting-system/internal/server"
 	
 	"github.com/joho/godotenv"
@@ -25,33 +37,92 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Connect to database
 	pool, err := dbconn.NewPool(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer pool.Close()
 
 	log.Println("Database connection established")
 
-	// Initialize cache (5 minute TTL)
-	reportCache := cache.New(5 * 60 * time.Second)
+	// Initialize cache (bounded, least recently used reports evicted first),
+	// shared through Redis when configured
+	var reportCache cache.Backend = cache.New(cfg.CacheTTL, cfg.CacheMaxEntries, cfg.CacheMaxBytes)
+	if cfg.RedisURL != "" {
+		reportCache, err = cache.NewRedis(cfg.RedisURL, cfg.CacheTTL, reportCache)
+		if err != nil {
+			log.Fatalf("Failed to configure report cache: %v", err)
+		}
+		log.Println("Report cache shared through Redis")
+	}
+
+	// Background work outlives the signal until requests in flight finish
+	background, stopBackground := context.WithCancel(context.Background())
 
 	// Initialize services
-	reportService := reports.NewService(pool, reportCache, cfg.DBSchema)
+	reportService := reports.NewService(pool, reportCache, cfg.DBSchema, cfg.CacheClosedPeriodTTL)
+	// Cached reports are evicted as soon as transactions in their range change
+	reportService.StartInvalidation(background)
+	periodService := periods.NewService(pool, cfg.DBSchema)
+	journalService := journal.NewService(pool, periodService, reportService, cfg.DBSchema)
+	budgetService := budgets.NewService(pool, reportService, cfg.DBSchema, cfg.BudgetOverspendThresholdPct)
+	fxService := fx.NewService(pool, cfg.DBSchema)
 
 	// Initialize handlers
-	authHandler := auth.NewHandler(pool, cfg.JWTSecret)
-	reportHandler := reports.NewHandler(reportService)
+	authHandler := auth.NewHandler(pool, cfg.JWTSecret, cfg.AdminUsers)
+	fiscalCalendar := reports.NewFiscalCalendar(cfg.FiscalYearStartMonth)
+	renderer := export.NewRenderer(cfg.CompanyName)
+	reportRunner := reports.NewRunner(reportService, fiscalCalendar)
+	reportHandler := reports.NewHandler(reportRunner, renderer)
+	journalHandler := journal.NewHandler(journalService)
+	periodHandler := periods.NewHandler(periodService)
+	budgetHandler := budgets.NewHandler(budgetService, fiscalCalendar, renderer)
+	fxHandler := fx.NewHandler(fxService)
+	cacheHandler := cache.NewHandler(reportCache)
+
+	// Report jobs run every report type, including budget vs actual
+	reportRunner.Register(budgets.ReportType, budgetHandler.PrepareBudgetVsActual)
+	jobService := jobs.NewService(pool, reportRunner, renderer, cfg.DBSchema, cfg.ReportJobMaxAttempts, cfg.ReportJobRetention)
+	jobService.Start(background, cfg.ReportJobWorkers)
+	jobHandler := jobs.NewHandler(jobService)
+
+	savedReportService := savedreports.NewService(pool, reportRunner, cfg.DBSchema)
+	savedReportHandler := savedreports.NewHandler(savedReportService, renderer)
+
+	// Scheduled reports run as report jobs and are emailed when they finish
+	mailer := mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	scheduleService := schedules.NewService(pool, jobService, mailer, cfg.DBSchema)
+	scheduleService.Start(background)
+	scheduleHandler := schedules.NewHandler(scheduleService)
 
 	// Initialize server
-	srv := server.NewServer(authHandler, reportHandler)
+	srv := server.NewServer(authHandler, reportHandler, journalHandler, periodHandler, budgetHandler, fxHandler, jobHandler, scheduleHandler, savedReportHandler, cacheHandler)
 
 	// Start server
 	addr := fmt.Sprintf("%s:%s", cfg.ServerHost, cfg.ServerPort)
 	log.Printf("Starting server on %s", addr)
-	if err := srv.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serveErr := srv.Run(ctx, addr)
+	if serveErr != nil {
+		log.Printf("Server stopped: %v", serveErr)
+	} else {
+		log.Println("Shutting down")
+	}
+
+	// Stop the workers, the scheduler and the cache invalidation listener,
+	// then close the cache they use and the database
+	stopBackground()
+	jobService.Wait()
+	scheduleService.Wait()
+	reportService.WaitInvalidation()
+	reportCache.Close()
+	pool.Close()
+
+	if serveErr != nil {
+		os.Exit(1)
 	}
 }
 
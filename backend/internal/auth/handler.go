@@ -9,11 +9,18 @@ import (
 
 type Handler struct {
 	service *Service
+	// admins are the usernames allowed through RequireAdmin
+	admins map[string]bool
 }
 
-func NewHandler(db *pgxpool.Pool, jwtSecret string) *Handler {
+func NewHandler(db *pgxpool.Pool, jwtSecret string, admins []string) *Handler {
+	adminSet := make(map[string]bool, len(admins))
+	for _, username := range admins {
+		adminSet[username] = true
+	}
 	return &Handler{
 		service: NewService(db, jwtSecret),
+		admins:  adminSet,
 	}
 }
 
@@ -43,6 +43,21 @@ func (h *Handler) RequireAuth() gin.HandlerFunc {
 		// Set user info in context
 		c.Set("user_id", claims["user_id"])
 		c.Set("username", claims["username"])
+		c.Set("is_admin", h.admins[c.GetString("username")])
+
+		c.Next()
+	}
+}
+
+// RequireAdmin allows only the users configured as administrators. It must run
+// after RequireAuth.
+func (h *Handler) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("is_admin") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "administrator access required"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,234 @@
+package budgets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/periods"
+	"financial-reporting-system/internal/reports"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadBytes bounds the size of a budget CSV upload.
+const maxUploadBytes = 5 << 20
+
+type Handler struct {
+	service  *Service
+	calendar reports.FiscalCalendar
+	renderer *export.Renderer
+}
+
+func NewHandler(service *Service, calendar reports.FiscalCalendar, renderer *export.Renderer) *Handler {
+	return &Handler{
+		service:  service,
+		calendar: calendar,
+		renderer: renderer,
+	}
+}
+
+// ListBudgets handles GET /api/budgets
+func (h *Handler) ListBudgets(c *gin.Context) {
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil || year < 1900 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year must be a four-digit year"})
+		return
+	}
+
+	budgets, err := h.service.List(c.Request.Context(), ListFilter{
+		Year:       year,
+		CategoryID: c.Query("category_id"),
+		AccountID:  c.Query("account_id"),
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": budgets})
+}
+
+// GetBudget handles GET /api/budgets/:id
+func (h *Handler) GetBudget(c *gin.Context) {
+	budget, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// CreateBudget handles POST /api/budgets
+func (h *Handler) CreateBudget(c *gin.Context) {
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := h.service.Create(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, budget)
+}
+
+// UpdateBudget handles PUT /api/budgets/:id
+func (h *Handler) UpdateBudget(c *gin.Context) {
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := h.service.Update(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// DeleteBudget handles DELETE /api/budgets/:id
+func (h *Handler) DeleteBudget(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UploadBudgets handles POST /api/budgets/upload. The CSV is sent either as
+// the "file" field of a multipart form or as a text/csv request body.
+func (h *Handler) UploadBudgets(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+
+	body := c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "multipart upload must include a file field"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	result, err := h.service.Upload(c.Request.Context(), body)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetBudgetVsActual handles GET /api/reports/budget-vs-actual
+func (h *Handler) GetBudgetVsActual(c *gin.Context) {
+	run, err := h.PrepareBudgetVsActual(c.Request.URL.Query(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := run(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	h.renderer.Respond(c, http.StatusOK, result)
+}
+
+// PrepareBudgetVsActual is the reports.PrepareFunc of the budget vs actual
+// report, which report jobs run as ReportType.
+func (h *Handler) PrepareBudgetVsActual(params url.Values, now time.Time) (reports.ReportFunc, error) {
+	startDate, endDate, err := h.parseMonthRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (interface{}, error) {
+		return h.service.BudgetVsActual(ctx, startDate, endDate)
+	}, nil
+}
+
+// parseMonthRange reads a named period or from/to months (YYYY-MM). Budgets
+// are monthly, so the range must cover whole months. Defaults to the current
+// fiscal year.
+func (h *Handler) parseMonthRange(params url.Values, now time.Time) (time.Time, time.Time, error) {
+	from, to := params.Get("from"), params.Get("to")
+	period := params.Get("period")
+	if period != "" && (from != "" || to != "") {
+		return time.Time{}, time.Time{}, errors.New("use either period or from/to, not both")
+	}
+
+	if from == "" && to == "" {
+		if period == "" {
+			period = fmt.Sprintf("FY%d", h.calendar.FiscalYear(now))
+		}
+		dates, err := h.calendar.Resolve(period, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if dates.Start.Day() != 1 || dates.End.AddDate(0, 0, 1).Day() != 1 {
+			return time.Time{}, time.Time{}, errors.New("budget reports need a period of whole months")
+		}
+		return dates.Start, dates.End, nil
+	}
+
+	if from == "" || to == "" {
+		return time.Time{}, time.Time{}, errors.New("from and to are both required")
+	}
+	startDate, _, err := periods.ParsePeriod(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+	}
+	_, endDate, err := periods.ParsePeriod(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+	}
+	if startDate.After(endDate) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+
+	return startDate, endDate, nil
+}
+
+func writeError(c *gin.Context, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message})
+		return
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrBudgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDuplicateBudget):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "upload is too large"})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,221 @@
+package budgets
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// ReportType is the report type of budget vs actual reports.
+const ReportType = "budget-vs-actual"
+
+const (
+	TargetCategory = "category"
+	TargetAccount  = "account"
+)
+
+type BudgetVsActualRow struct {
+	Target      string       `json:"target"`
+	ID          string       `json:"id,omitempty"`
+	Code        string       `json:"code,omitempty"`
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	Budget      money.Amount `json:"budget"`
+	Actual      money.Amount `json:"actual"`
+	Variance    money.Amount `json:"variance"`
+	VariancePct *float64     `json:"variance_pct"`
+	Overspent   bool         `json:"overspent"`
+}
+
+type BudgetVsActualResponse struct {
+	StartDate             string              `json:"start_date"`
+	EndDate               string              `json:"end_date"`
+	OverspendThresholdPct float64             `json:"overspend_threshold_pct"`
+	Data                  []BudgetVsActualRow `json:"data"`
+	ExecutionTimeMs       int64               `json:"execution_time_ms"`
+}
+
+// BudgetVsActual compares the budgets of every month from startDate to endDate
+// with the actuals of the same range. Category budgets are compared with
+// sp_profit_loss and account budgets with the trial balance, signed by the
+// account's normal side. P&L categories with activity but no budget are
+// included with a zero budget.
+//
+// Variance is actual minus budget. Cost lines (expense categories, expense and
+// asset accounts) are flagged as overspent when their variance exceeds the
+// configured percentage of budget, or when they have spend but no budget.
+func (s *Service) BudgetVsActual(ctx context.Context, startDate, endDate time.Time) (*BudgetVsActualResponse, error) {
+	start := time.Now()
+
+	rows, err := s.budgetTotals(ctx, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	profitLoss, err := s.reports.GetProfitLoss(ctx, startDate, endDate, "")
+	if err != nil {
+		return nil, err
+	}
+	byCategory := make(map[string]int)
+	for i, row := range rows {
+		if row.Target == TargetCategory {
+			byCategory[row.Type+":"+row.Name] = i
+		}
+	}
+	for _, actual := range profitLoss.Data {
+		if i, ok := byCategory[actual.CategoryType+":"+actual.CategoryName]; ok {
+			rows[i].Actual = actual.TotalAmount
+			continue
+		}
+		rows = append(rows, BudgetVsActualRow{
+			Target: TargetCategory,
+			Name:   actual.CategoryName,
+			Type:   actual.CategoryType,
+			Actual: actual.TotalAmount,
+		})
+	}
+
+	if hasAccountBudgets(rows) {
+		trialBalance, err := s.reports.GetTrialBalance(ctx, startDate, endDate, "")
+		if err != nil {
+			return nil, err
+		}
+		activity := make(map[string]money.Amount, len(trialBalance.Data))
+		for _, account := range trialBalance.Data {
+			net := account.PeriodDebit - account.PeriodCredit
+			if !debitNormal(account.AccountType) {
+				net = -net
+			}
+			activity[account.AccountID] = net
+		}
+		for i := range rows {
+			if rows[i].Target == TargetAccount {
+				rows[i].Actual = activity[rows[i].ID]
+			}
+		}
+	}
+
+	for i := range rows {
+		s.applyVariance(&rows[i])
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Target != rows[j].Target {
+			return rows[i].Target == TargetCategory
+		}
+		if rows[i].Type != rows[j].Type {
+			return rows[i].Type > rows[j].Type // revenue before expense
+		}
+		if rows[i].Code != rows[j].Code {
+			return rows[i].Code < rows[j].Code
+		}
+		return rows[i].Name < rows[j].Name
+	})
+
+	return &BudgetVsActualResponse{
+		StartDate:             startDate.Format("2006-01-02"),
+		EndDate:               endDate.Format("2006-01-02"),
+		OverspendThresholdPct: s.overspendThresholdPct,
+		Data:                  rows,
+		ExecutionTimeMs:       time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// budgetTotals sums the budgets of every month in the range per category and account.
+func (s *Service) budgetTotals(ctx context.Context, startDate, endDate time.Time) ([]BudgetVsActualRow, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			CASE WHEN b.category_id IS NOT NULL THEN 'category' ELSE 'account' END,
+			COALESCE(b.category_id, b.account_id),
+			COALESCE(a.code, ''),
+			COALESCE(c.name, a.name),
+			COALESCE(c.type, a.type),
+			SUM(b.amount)
+		FROM "%[1]s".budgets b
+		LEFT JOIN "%[1]s".categories c ON b.category_id = c.id
+		LEFT JOIN "%[1]s".accounts a ON b.account_id = a.id
+		WHERE b.period_start >= DATE_TRUNC('month', $1::DATE) AND b.period_start <= $2
+		GROUP BY 1, 2, 3, 4, 5`, s.schema)
+	rows, err := s.db.Query(ctx, query, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budgets: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []BudgetVsActualRow{}
+	for rows.Next() {
+		var row BudgetVsActualRow
+		var id uuid.UUID
+		if err := rows.Scan(&row.Target, &id, &row.Code, &row.Name, &row.Type, &row.Budget); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row.ID = id.String()
+		totals = append(totals, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return totals, nil
+}
+
+func (s *Service) applyVariance(row *BudgetVsActualRow) {
+	row.Variance = row.Actual - row.Budget
+	if row.Budget != 0 {
+		pct := math.Round(row.Variance.Float64()/row.Budget.Float64()*10000) / 100
+		row.VariancePct = &pct
+	}
+
+	if !isCostLine(row.Type) {
+		return
+	}
+	if row.VariancePct == nil {
+		row.Overspent = row.Actual > 0
+		return
+	}
+	row.Overspent = *row.VariancePct > s.overspendThresholdPct
+}
+
+func hasAccountBudgets(rows []BudgetVsActualRow) bool {
+	for _, row := range rows {
+		if row.Target == TargetAccount {
+			return true
+		}
+	}
+	return false
+}
+
+func debitNormal(accountType string) bool {
+	return accountType == "asset" || accountType == "expense"
+}
+
+// isCostLine reports whether spending more than budget on a line is an overspend.
+func isCostLine(lineType string) bool {
+	return lineType == "expense" || lineType == "asset"
+}
+
+func (r *BudgetVsActualResponse) Workbook() export.Workbook {
+	sheet := export.Sheet{
+		Name: "Budget vs Actual",
+		Meta: []export.Field{
+			{Label: "Report", Value: "Budget vs Actual"},
+			{Label: "Start Date", Value: r.StartDate},
+			{Label: "End Date", Value: r.EndDate},
+			{Label: "Overspend Threshold %", Value: strconv.FormatFloat(r.OverspendThresholdPct, 'f', -1, 64)},
+		},
+		Columns: []string{"Target", "Code", "Name", "Type", "Budget", "Actual", "Variance", "Variance %", "Overspent"},
+	}
+	for _, row := range r.Data {
+		sheet.AddRow(row.Target, row.Code, row.Name, row.Type, row.Budget, row.Actual, row.Variance, row.VariancePct,
+			row.Overspent)
+	}
+	return export.Workbook{Name: "budget-vs-actual_" + r.StartDate + "_" + r.EndDate, Sheets: []export.Sheet{sheet}}
+}
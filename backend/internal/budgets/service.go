@@ -0,0 +1,326 @@
+package budgets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/periods"
+	"financial-reporting-system/internal/reports"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ValidationError is returned when a budget is rejected. Its message is safe
+// to show to API clients.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+var (
+	// ErrBudgetNotFound is returned when a budget ID does not exist.
+	ErrBudgetNotFound = errors.New("budget not found")
+	// ErrDuplicateBudget is returned when the category or account already has a budget for the month.
+	ErrDuplicateBudget = errors.New("a budget already exists for this category or account and month")
+)
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+type Service struct {
+	db                    *pgxpool.Pool
+	reports               *reports.Service
+	schema                string
+	overspendThresholdPct float64
+}
+
+// NewService creates a budget service. Cost lines whose actual exceeds budget
+// by more than overspendThresholdPct percent are flagged in BudgetVsActual.
+func NewService(db *pgxpool.Pool, reports *reports.Service, schema string, overspendThresholdPct float64) *Service {
+	return &Service{
+		db:                    db,
+		reports:               reports,
+		schema:                schema,
+		overspendThresholdPct: overspendThresholdPct,
+	}
+}
+
+// BudgetRequest creates or replaces a budget. Exactly one of CategoryID and
+// AccountID must be set.
+type BudgetRequest struct {
+	Period     string   `json:"period" binding:"required"`
+	CategoryID string   `json:"category_id"`
+	AccountID  string   `json:"account_id"`
+	Amount     *float64 `json:"amount" binding:"required"`
+	Notes      string   `json:"notes"`
+}
+
+type Budget struct {
+	ID           string    `json:"id"`
+	Period       string    `json:"period"`
+	CategoryID   string    `json:"category_id,omitempty"`
+	CategoryName string    `json:"category_name,omitempty"`
+	AccountID    string    `json:"account_id,omitempty"`
+	AccountCode  string    `json:"account_code,omitempty"`
+	AccountName  string    `json:"account_name,omitempty"`
+	Amount       float64   `json:"amount"`
+	Notes        string    `json:"notes,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ListFilter narrows List to one year and optionally one category or account.
+type ListFilter struct {
+	Year       int
+	CategoryID string
+	AccountID  string
+}
+
+// budgetRow is a validated budget ready to be written.
+type budgetRow struct {
+	periodStart time.Time
+	categoryID  *uuid.UUID
+	accountID   *uuid.UUID
+	amount      float64
+	notes       *string
+}
+
+const selectBudget = `
+	SELECT b.id, b.period_start, b.category_id, COALESCE(c.name, ''), b.account_id,
+		COALESCE(a.code, ''), COALESCE(a.name, ''), b.amount, COALESCE(b.notes, ''),
+		b.created_at, b.updated_at
+	FROM "%[1]s".budgets b
+	LEFT JOIN "%[1]s".categories c ON b.category_id = c.id
+	LEFT JOIN "%[1]s".accounts a ON b.account_id = a.id`
+
+// List returns the budgets of a calendar year ordered by month, then target.
+func (s *Service) List(ctx context.Context, filter ListFilter) ([]Budget, error) {
+	first := time.Date(filter.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	conditions := []string{"b.period_start >= $1", "b.period_start < $2"}
+	args := []interface{}{first, first.AddDate(1, 0, 0)}
+	for _, f := range []struct{ column, value string }{
+		{"b.category_id", filter.CategoryID},
+		{"b.account_id", filter.AccountID},
+	} {
+		if f.value == "" {
+			continue
+		}
+		id, err := uuid.Parse(f.value)
+		if err != nil {
+			return nil, invalid("%s must be a UUID", strings.TrimPrefix(f.column, "b."))
+		}
+		args = append(args, id)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", f.column, len(args)))
+	}
+
+	query := fmt.Sprintf(selectBudget, s.schema) + `
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY b.period_start, c.name NULLS LAST, a.code`
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := []Budget{}
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, *budget)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return budgets, nil
+}
+
+func (s *Service) Get(ctx context.Context, id string) (*Budget, error) {
+	budgetID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrBudgetNotFound
+	}
+
+	query := fmt.Sprintf(selectBudget, s.schema) + `
+	WHERE b.id = $1`
+	budget, err := scanBudget(s.db.QueryRow(ctx, query, budgetID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrBudgetNotFound
+	}
+	return budget, err
+}
+
+func (s *Service) Create(ctx context.Context, req BudgetRequest) (*Budget, error) {
+	row, err := validate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var id uuid.UUID
+	query := fmt.Sprintf(`
+		INSERT INTO "%s".budgets (period_start, category_id, account_id, amount, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`, s.schema)
+	err = s.db.QueryRow(ctx, query, row.periodStart, row.categoryID, row.accountID, row.amount, row.notes).Scan(&id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return s.Get(ctx, id.String())
+}
+
+// Update replaces every field of an existing budget.
+func (s *Service) Update(ctx context.Context, id string, req BudgetRequest) (*Budget, error) {
+	budgetID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrBudgetNotFound
+	}
+
+	row, err := validate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".budgets
+		SET period_start = $2, category_id = $3, account_id = $4, amount = $5, notes = $6,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, s.schema)
+	tag, err := s.db.Exec(ctx, query, budgetID, row.periodStart, row.categoryID, row.accountID, row.amount, row.notes)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrBudgetNotFound
+	}
+
+	return s.Get(ctx, id)
+}
+
+func (s *Service) Delete(ctx context.Context, id string) error {
+	budgetID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrBudgetNotFound
+	}
+
+	query := fmt.Sprintf(`DELETE FROM "%s".budgets WHERE id = $1`, s.schema)
+	tag, err := s.db.Exec(ctx, query, budgetID)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBudgetNotFound
+	}
+
+	return nil
+}
+
+func validate(req BudgetRequest) (budgetRow, error) {
+	var row budgetRow
+
+	start, _, err := periods.ParsePeriod(req.Period)
+	if err != nil {
+		return row, invalid("period must be formatted as YYYY-MM")
+	}
+	row.periodStart = start
+
+	if (req.CategoryID == "") == (req.AccountID == "") {
+		return row, invalid("exactly one of category_id and account_id is required")
+	}
+	if req.CategoryID != "" {
+		id, err := uuid.Parse(req.CategoryID)
+		if err != nil {
+			return row, invalid("category_id must be a UUID")
+		}
+		row.categoryID = &id
+	}
+	if req.AccountID != "" {
+		id, err := uuid.Parse(req.AccountID)
+		if err != nil {
+			return row, invalid("account_id must be a UUID")
+		}
+		row.accountID = &id
+	}
+
+	if req.Amount == nil || !validAmount(*req.Amount) {
+		return row, invalid("amount must be a non-negative number with at most two decimals")
+	}
+	row.amount = *req.Amount
+
+	if req.Notes != "" {
+		row.notes = &req.Notes
+	}
+
+	return row, nil
+}
+
+// validAmount accepts non-negative amounts with at most two decimals, judged
+// on the shortest decimal form of the float rather than a rounding tolerance.
+func validAmount(amount float64) bool {
+	if amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return false
+	}
+	text := strconv.FormatFloat(amount, 'f', -1, 64)
+	dot := strings.IndexByte(text, '.')
+	return dot < 0 || len(text)-dot-1 <= 2
+}
+
+func scanBudget(row pgx.Row) (*Budget, error) {
+	var budget Budget
+	var id uuid.UUID
+	var periodStart time.Time
+	var categoryID, accountID *uuid.UUID
+	err := row.Scan(&id, &periodStart, &categoryID, &budget.CategoryName, &accountID,
+		&budget.AccountCode, &budget.AccountName, &budget.Amount, &budget.Notes,
+		&budget.CreatedAt, &budget.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan budget: %w", err)
+	}
+
+	budget.ID = id.String()
+	budget.Period = periodStart.Format("2006-01")
+	if categoryID != nil {
+		budget.CategoryID = categoryID.String()
+	}
+	if accountID != nil {
+		budget.AccountID = accountID.String()
+	}
+	return &budget, nil
+}
+
+// translateError turns constraint violations into client errors so raw pgx
+// messages never reach the client.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return fmt.Errorf("failed to save budget: %w", err)
+	}
+
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return ErrDuplicateBudget
+	case "23503": // foreign_key_violation
+		return invalid("budget references a category or account that does not exist")
+	case "23514": // check_violation
+		return invalid("budget violates constraint %s", pgErr.ConstraintName)
+	case "22003": // numeric_value_out_of_range
+		return invalid("amount is too large")
+	}
+
+	return fmt.Errorf("failed to save budget: %w", err)
+}
@@ -0,0 +1,209 @@
+package budgets
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/periods"
+
+	"github.com/google/uuid"
+)
+
+// maxUploadErrors caps how many row errors are reported for one upload.
+const maxUploadErrors = 20
+
+// UploadResult summarises a CSV upload.
+type UploadResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// uploadCell is one month of one CSV row.
+type uploadCell struct {
+	line        int
+	periodStart time.Time
+	target      string
+	id          uuid.UUID
+	amount      float64
+}
+
+// Upload loads budgets from a wide CSV with one row per category or account
+// and one column per month, so a whole year is loaded at once:
+//
+//	target,key,2025-01,2025-02,...,2025-12
+//	category,Sales Revenue,150000000,160000000,...
+//	account,5100,2000000,2000000,...
+//
+// target is "category" (key is the category name) or "account" (key is the
+// account code). Empty cells are skipped; existing budgets for the same month
+// are overwritten. The upload is all-or-nothing.
+func (s *Service) Upload(ctx context.Context, r io.Reader) (*UploadResult, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, invalid("CSV is empty")
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	if len(header) < 3 || !strings.EqualFold(header[0], "target") || !strings.EqualFold(header[1], "key") {
+		return nil, invalid("CSV header must be target,key followed by YYYY-MM month columns")
+	}
+	months := make([]time.Time, len(header)-2)
+	for i, column := range header[2:] {
+		start, _, err := periods.ParsePeriod(strings.TrimSpace(column))
+		if err != nil {
+			return nil, invalid("CSV header column %q is not a YYYY-MM month", column)
+		}
+		months[i] = start
+	}
+
+	categories, err := s.lookup(ctx, fmt.Sprintf(`SELECT name, id FROM "%s".categories`, s.schema))
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.lookup(ctx, fmt.Sprintf(`SELECT code, id FROM "%s".accounts`, s.schema))
+	if err != nil {
+		return nil, err
+	}
+
+	var cells []uploadCell
+	var problems []string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		if len(problems) >= maxUploadErrors {
+			break
+		}
+
+		target := strings.ToLower(strings.TrimSpace(record[0]))
+		key := strings.TrimSpace(record[1])
+		var id uuid.UUID
+		var found bool
+		switch target {
+		case TargetCategory:
+			id, found = categories[key]
+		case TargetAccount:
+			id, found = accounts[key]
+		default:
+			problems = append(problems, fmt.Sprintf("line %d: target must be category or account", line))
+			continue
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("line %d: unknown %s %q", line, target, key))
+			continue
+		}
+
+		for i, value := range record[2:] {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil || !validAmount(amount) {
+				problems = append(problems, fmt.Sprintf("line %d, %s: amount must be a non-negative number with at most two decimals", line, months[i].Format("2006-01")))
+				continue
+			}
+			cells = append(cells, uploadCell{line: line, periodStart: months[i], target: target, id: id, amount: amount})
+		}
+	}
+	if len(problems) > 0 {
+		return nil, invalid("CSV rejected: %s", strings.Join(problems, "; "))
+	}
+
+	return s.upsert(ctx, cells)
+}
+
+// csvError reports malformed CSV to the client and passes read failures,
+// such as an oversized body, through unchanged.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return invalid("invalid CSV: %v", err)
+	}
+	return fmt.Errorf("failed to read CSV: %w", err)
+}
+
+// lookup loads a key to ID map, such as category names or account codes.
+func (s *Service) lookup(ctx context.Context, query string) (map[string]uuid.UUID, error) {
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lookup keys: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var key string
+		var id uuid.UUID
+		if err := rows.Scan(&key, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids[key] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (s *Service) upsert(ctx context.Context, cells []uploadCell) (*UploadResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// xmax is zero only for rows inserted by this statement
+	upsert := map[string]string{
+		TargetCategory: fmt.Sprintf(`
+			INSERT INTO "%s".budgets (period_start, category_id, amount)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (category_id, period_start) WHERE category_id IS NOT NULL
+			DO UPDATE SET amount = EXCLUDED.amount, updated_at = CURRENT_TIMESTAMP
+			RETURNING xmax = 0`, s.schema),
+		TargetAccount: fmt.Sprintf(`
+			INSERT INTO "%s".budgets (period_start, account_id, amount)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (account_id, period_start) WHERE account_id IS NOT NULL
+			DO UPDATE SET amount = EXCLUDED.amount, updated_at = CURRENT_TIMESTAMP
+			RETURNING xmax = 0`, s.schema),
+	}
+
+	result := &UploadResult{}
+	for _, cell := range cells {
+		var inserted bool
+		if err := tx.QueryRow(ctx, upsert[cell.target], cell.periodStart, cell.id, cell.amount).Scan(&inserted); err != nil {
+			var validationErr *ValidationError
+			if err := translateError(err); errors.As(err, &validationErr) {
+				return nil, invalid("line %d, %s: %s", cell.line, cell.periodStart.Format("2006-01"), validationErr.Message)
+			}
+			return nil, fmt.Errorf("failed to save budget: %w", err)
+		}
+		if inserted {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit budgets: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"time"
+)
+
+// Backend stores cached report responses. Cache keeps them in process
+// memory; Redis shares them between API replicas.
+type Backend interface {
+	// Get loads the value stored under key into value, a pointer of the same
+	// type as the one set, and reports whether there was one. Slices and maps
+	// in the loaded value may be shared with other readers and must not be
+	// modified.
+	Get(key string, value interface{}) (CacheEntry, bool)
+	// SetWithTTL stores value, a pointer, under key for ttl. The value must
+	// not be modified afterwards.
+	SetWithTTL(key string, value interface{}, ttl time.Duration)
+	Delete(key string)
+	// DeleteFunc removes every entry whose key matches and returns how many
+	// it removed.
+	DeleteFunc(match func(key string) bool) int
+	Clear()
+	// TTL is the lifetime of entries without a TTL of their own.
+	TTL() time.Duration
+	Stats() Stats
+	Close()
+}
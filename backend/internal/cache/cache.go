@@ -1,25 +1,79 @@
 package cache
 
 import (
+	"container/list"
+	"reflect"
 	"sync"
 	"time"
 )
 
+// cleanupInterval is how often expired entries are dropped.
+const cleanupInterval = 1 * time.Minute
+
+// CacheEntry tells when a cached value was stored and when it expires.
 type CacheEntry struct {
-	Data      interface{}
+	CachedAt  time.Time
 	ExpiresAt time.Time
 }
 
+// item is an entry in the recency list, most recently used first.
+type item struct {
+	key   string
+	value interface{}
+	entry CacheEntry
+	size  int64
+}
+
+// Stats are the size of a cache and its counters since it was created.
+type Stats struct {
+	// Backend is "memory" or "redis".
+	Backend string `json:"backend"`
+	Entries int    `json:"entries"`
+	// Bytes and the limits are only known for the memory backend.
+	Bytes      int64 `json:"bytes,omitempty"`
+	MaxEntries int   `json:"max_entries,omitempty"`
+	MaxBytes   int64 `json:"max_bytes,omitempty"`
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	// Evictions counts entries dropped to stay within the limits, before
+	// they expired.
+	Evictions int64 `json:"evictions"`
+	// Expirations counts entries dropped because their TTL passed.
+	Expirations int64   `json:"expirations"`
+	HitRate     float64 `json:"hit_rate"`
+	// Errors counts failed requests to a shared backend, which were then
+	// served by Fallback.
+	Errors   int64  `json:"errors,omitempty"`
+	Fallback *Stats `json:"fallback,omitempty"`
+}
+
+// Cache is an in-memory Backend bounded by entry count and by the approximate
+// size of its values. When either limit is reached the least recently used
+// entries are evicted. Values are stored as set, not copied.
 type Cache struct {
-	entries map[string]*CacheEntry
-	mu      sync.RWMutex
-	ttl     time.Duration
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	recency    *list.List
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	stats      Stats
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func New(ttl time.Duration) *Cache {
+// New creates a cache whose entries live for ttl unless set with a TTL of
+// their own. A maxEntries or maxBytes of zero leaves that limit off.
+func New(ttl time.Duration, maxEntries int, maxBytes int64) *Cache {
 	c := &Cache{
-		entries: make(map[string]*CacheEntry),
-		ttl:     ttl,
+		entries:    make(map[string]*list.Element),
+		recency:    list.New(),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		done:       make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -28,57 +82,168 @@ func New(ttl time.Duration) *Cache {
 	return c
 }
 
+// TTL is the lifetime of entries set without a TTL of their own.
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
 func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores value under key for ttl. A value larger than the whole
+// memory budget is not stored.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	size := int64(len(key)) + sizeOf(value)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.entries[key] = &CacheEntry{
-		Data:      value,
-		ExpiresAt: time.Now().Add(c.ttl),
+	if elem, exists := c.entries[key]; exists {
+		c.remove(elem)
+	}
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	now := time.Now()
+	c.entries[key] = c.recency.PushFront(&item{
+		key:   key,
+		value: value,
+		entry: CacheEntry{
+			CachedAt:  now,
+			ExpiresAt: now.Add(ttl),
+		},
+		size: size,
+	})
+	c.bytes += size
+
+	for c.overLimit() {
+		c.remove(c.recency.Back())
+		c.stats.Evictions++
 	}
 }
 
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// Get copies the value stored under key into value, unless it has expired or
+// is of another type. The copy is shallow.
+func (c *Cache) Get(key string, value interface{}) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	entry, exists := c.entries[key]
+	elem, exists := c.entries[key]
 	if !exists {
-		return nil, false
+		c.stats.Misses++
+		return CacheEntry{}, false
 	}
 
-	if time.Now().After(entry.ExpiresAt) {
-		return nil, false
+	it := elem.Value.(*item)
+	if time.Now().After(it.entry.ExpiresAt) {
+		c.remove(elem)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return CacheEntry{}, false
 	}
+	stored, dst := reflect.ValueOf(it.value), reflect.ValueOf(value)
+	if stored.Kind() != reflect.Pointer || stored.IsNil() || stored.Type() != dst.Type() {
+		c.stats.Misses++
+		return CacheEntry{}, false
+	}
+	dst.Elem().Set(stored.Elem())
 
-	return entry.Data, true
+	c.recency.MoveToFront(elem)
+	c.stats.Hits++
+	return it.entry, true
 }
 
 func (c *Cache) Delete(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.entries, key)
+	if elem, exists := c.entries[key]; exists {
+		c.remove(elem)
+	}
+}
+
+func (c *Cache) DeleteFunc(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for key, elem := range c.entries {
+		if match(key) {
+			c.remove(elem)
+			deleted++
+		}
+	}
+	return deleted
 }
 
 func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.entries = make(map[string]*CacheEntry)
+	c.entries = make(map[string]*list.Element)
+	c.recency.Init()
+	c.bytes = 0
+}
+
+// Stats returns the current size and counters of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Backend = "memory"
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	stats.MaxEntries = c.maxEntries
+	stats.MaxBytes = c.maxBytes
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// Close stops the cleanup goroutine and drops every entry. It is safe to call
+// more than once.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.Clear()
+	})
+}
+
+func (c *Cache) overLimit() bool {
+	return (c.maxEntries > 0 && len(c.entries) > c.maxEntries) ||
+		(c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
+
+// remove drops elem; c.mu must be held.
+func (c *Cache) remove(elem *list.Element) {
+	it := c.recency.Remove(elem).(*item)
+	delete(c.entries, it.key)
+	c.bytes -= it.size
 }
 
 func (c *Cache) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := time.NewTicker(cleanupInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
 		c.mu.Lock()
 		now := time.Now()
-		for key, entry := range c.entries {
-			if now.After(entry.ExpiresAt) {
-				delete(c.entries, key)
+		for elem := c.recency.Back(); elem != nil; {
+			prev := elem.Prev()
+			if now.After(elem.Value.(*item).entry.ExpiresAt) {
+				c.remove(elem)
+				c.stats.Expirations++
 			}
+			elem = prev
 		}
 		c.mu.Unlock()
 	}
 }
-
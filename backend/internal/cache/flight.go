@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// Group collapses concurrent computations of the same key into one. The zero
+// value is ready to use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// waiters is the number of callers still waiting for the result
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do runs fn once for every caller that asks for key while it is running and
+// returns its result to all of them. fn gets a context of its own that keeps
+// the values of the first caller's context: it is canceled only when every
+// waiting caller has given up, so one canceled request does not fail the
+// others. A caller whose ctx is done returns ctx.Err() at once.
+func (g *Group) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go g.run(callCtx, key, c, fn)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// Nobody wants the result any more; later callers start afresh
+			c.cancel()
+			g.forget(key, c)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// run calls fn outside of any request, so a panic in it would not reach the
+// router's recovery and would crash the process. It is returned to every
+// waiter as an error instead.
+func (g *Group) run(ctx context.Context, key string, c *call, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("cache: computing %s panicked: %v\n%s", key, r, debug.Stack())
+			c.value, c.err = nil, fmt.Errorf("computing %s failed unexpectedly", key)
+		}
+		c.cancel()
+
+		g.mu.Lock()
+		g.forget(key, c)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn(ctx)
+}
+
+// forget removes c from the group unless a newer call has replaced it. g.mu
+// must be held.
+func (g *Group) forget(key string, c *call) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	cache Backend
+}
+
+func NewHandler(cache Backend) *Handler {
+	return &Handler{
+		cache: cache,
+	}
+}
+
+// GetStats handles GET /api/admin/cache/stats
+func (h *Handler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.cache.Stats())
+}
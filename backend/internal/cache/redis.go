@@ -0,0 +1,499 @@
+package cache
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keyPrefix namespaces report cache keys, so Clear leaves other keys in
+	// the same Redis database alone.
+	keyPrefix = "report-cache:"
+	// indexKey is a sorted set of the report cache keys scored by expiry
+	// time in Unix milliseconds, so invalidation reads only those keys
+	// instead of scanning the whole database.
+	indexKey = "report-cache-index"
+	// redisTimeout bounds connecting and each command, so an unresponsive
+	// server delays requests by at most this before they fall back.
+	redisTimeout = 500 * time.Millisecond
+	// redisRetryInterval is how long the fallback is used after Redis fails
+	// before Redis is tried again.
+	redisRetryInterval = 30 * time.Second
+	// maxIdleConns is how many connections are kept open between requests.
+	maxIdleConns = 10
+)
+
+// redisEntry is how a value is stored in Redis.
+type redisEntry struct {
+	CachedAt  time.Time       `json:"cached_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Redis is a Backend shared by every API replica, on any server speaking the
+// Redis protocol. Values are stored as JSON, so they must round-trip through
+// encoding/json. While the server cannot be reached, requests are served by
+// a fallback Backend, and Redis is tried again every redisRetryInterval.
+type Redis struct {
+	addr      string
+	username  string
+	password  string
+	db        int
+	tlsConfig *tls.Config
+	ttl       time.Duration
+	fallback  Backend
+
+	idle chan *respConn
+
+	mu        sync.Mutex
+	downUntil time.Time
+	stats     Stats
+	// missed counts deletions that did not reach Redis since it was last
+	// flushed. Entries they should have removed are still there, so Redis is
+	// flushed before it is used again.
+	missed   int
+	flushing sync.Mutex
+}
+
+// NewRedis connects to the server at rawURL, such as
+// redis://:password@localhost:6379/0 (rediss:// for TLS). An unreachable
+// server is not an error: fallback serves requests until it is back.
+func NewRedis(rawURL string, ttl time.Duration, fallback Backend) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	r := &Redis{
+		ttl:      ttl,
+		fallback: fallback,
+		idle:     make(chan *respConn, maxIdleConns),
+	}
+
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		r.tlsConfig = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("invalid Redis URL: scheme must be redis or rediss")
+	}
+	r.addr = u.Host
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil || r.db < 0 {
+			return nil, fmt.Errorf("invalid Redis URL: database must be a number")
+		}
+	}
+
+	if _, err := r.do("PING"); err != nil {
+		r.markDown(err)
+	}
+	return r, nil
+}
+
+// TTL is the lifetime of entries set without a TTL of their own.
+func (r *Redis) TTL() time.Duration {
+	return r.ttl
+}
+
+func (r *Redis) Get(key string, value interface{}) (CacheEntry, bool) {
+	if !r.available() {
+		return r.fallback.Get(key, value)
+	}
+
+	reply, err := r.do("GET", keyPrefix+key)
+	if err != nil {
+		r.failed(err)
+		return r.fallback.Get(key, value)
+	}
+	data, _ := reply.([]byte)
+	if data == nil {
+		r.count(&r.stats.Misses)
+		return CacheEntry{}, false
+	}
+
+	var entry redisEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		r.logError(fmt.Errorf("undecodable entry %s: %w", key, err))
+		return CacheEntry{}, false
+	}
+	if err := json.Unmarshal(entry.Data, value); err != nil {
+		r.logError(fmt.Errorf("undecodable entry %s: %w", key, err))
+		return CacheEntry{}, false
+	}
+	r.count(&r.stats.Hits)
+	return CacheEntry{CachedAt: entry.CachedAt, ExpiresAt: entry.ExpiresAt}, true
+}
+
+// SetWithTTL stores value as JSON under key; Redis expires it after ttl.
+func (r *Redis) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	if !r.available() {
+		r.fallback.SetWithTTL(key, value, ttl)
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.logError(fmt.Errorf("unencodable value for %s: %w", key, err))
+		return
+	}
+	now := time.Now()
+	payload, err := json.Marshal(redisEntry{CachedAt: now, ExpiresAt: now.Add(ttl), Data: data})
+	if err != nil {
+		r.logError(err)
+		return
+	}
+
+	millis := ttl.Milliseconds()
+	if millis < 1 {
+		millis = 1
+	}
+	// Indexed first, so that a stored entry can always be found by DeleteFunc
+	expires := strconv.FormatInt(now.UnixMilli()+millis, 10)
+	if _, err := r.do("ZADD", indexKey, expires, keyPrefix+key); err != nil {
+		r.failed(err)
+		r.fallback.SetWithTTL(key, value, ttl)
+		return
+	}
+	if _, err := r.do("SET", keyPrefix+key, string(payload), "PX", strconv.FormatInt(millis, 10)); err != nil {
+		r.failed(err)
+		r.fallback.SetWithTTL(key, value, ttl)
+	}
+}
+
+// Delete removes key from Redis and from the fallback, so an entry cached
+// during an outage does not resurface in the next one.
+func (r *Redis) Delete(key string) {
+	r.fallback.Delete(key)
+	if !r.available() {
+		r.miss()
+		return
+	}
+	if _, err := r.do("DEL", keyPrefix+key); err != nil {
+		r.failed(err)
+		r.miss()
+	}
+}
+
+// DeleteFunc removes the matching keys from Redis and from the fallback. Only
+// the keys in the index are read, not the whole database.
+func (r *Redis) DeleteFunc(match func(key string) bool) int {
+	deleted := r.fallback.DeleteFunc(match)
+	if !r.available() {
+		r.miss()
+		return deleted
+	}
+
+	// Drop the index entries of expired keys, which Redis has removed already
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	_, err := r.do("ZREMRANGEBYSCORE", indexKey, "-inf", now)
+	if err == nil {
+		err = r.scanIndex(func(keys []string) error {
+			var matched []string
+			for _, key := range keys {
+				if match(strings.TrimPrefix(key, keyPrefix)) {
+					matched = append(matched, key)
+				}
+			}
+			if len(matched) == 0 {
+				return nil
+			}
+			reply, err := r.do(append([]string{"DEL"}, matched...)...)
+			if err != nil {
+				return err
+			}
+			if n, ok := reply.(int64); ok {
+				deleted += int(n)
+			}
+			_, err = r.do(append([]string{"ZREM", indexKey}, matched...)...)
+			return err
+		})
+	}
+	if err != nil {
+		r.failed(err)
+		r.miss()
+	}
+	return deleted
+}
+
+// Clear removes every report cache key from Redis and empties the fallback.
+func (r *Redis) Clear() {
+	r.fallback.Clear()
+	if !r.available() {
+		r.miss()
+		return
+	}
+	if err := r.clearRedis(); err != nil {
+		r.failed(err)
+		r.miss()
+	}
+}
+
+// clearRedis deletes every report cache key and the index.
+func (r *Redis) clearRedis() error {
+	err := r.scan(func(keys []string) error {
+		_, err := r.do(append([]string{"DEL"}, keys...)...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.do("DEL", indexKey)
+	return err
+}
+
+// Stats returns the counters of this replica; Entries counts the report cache
+// keys in Redis, shared by every replica.
+func (r *Redis) Stats() Stats {
+	entries := 0
+	if r.available() {
+		err := r.scan(func(keys []string) error {
+			entries += len(keys)
+			return nil
+		})
+		if err != nil {
+			r.failed(err)
+		}
+	}
+
+	r.mu.Lock()
+	stats := r.stats
+	r.mu.Unlock()
+
+	stats.Backend = "redis"
+	stats.Entries = entries
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	fallback := r.fallback.Stats()
+	stats.Fallback = &fallback
+	return stats
+}
+
+// Close closes the idle connections and the fallback.
+func (r *Redis) Close() {
+	for {
+		select {
+		case conn := <-r.idle:
+			conn.Close()
+		default:
+			r.fallback.Close()
+			return
+		}
+	}
+}
+
+// scan calls fn with each batch of report cache keys in Redis.
+func (r *Redis) scan(fn func(keys []string) error) error {
+	return r.iterate(func(cursor string) []string {
+		return []string{"SCAN", cursor, "MATCH", keyPrefix + "*", "COUNT", "500"}
+	}, fn)
+}
+
+// scanIndex calls fn with each batch of keys in the index.
+func (r *Redis) scanIndex(fn func(keys []string) error) error {
+	return r.iterate(func(cursor string) []string {
+		return []string{"ZSCAN", indexKey, cursor, "COUNT", "500"}
+	}, func(items []string) error {
+		// Members alternate with their scores
+		keys := make([]string, 0, len(items)/2)
+		for i := 0; i < len(items); i += 2 {
+			keys = append(keys, items[i])
+		}
+		return fn(keys)
+	})
+}
+
+// iterate runs the cursor command built by command until the cursor is back
+// at 0, calling fn with each batch of items returned.
+func (r *Redis) iterate(command func(cursor string) []string, fn func(items []string) error) error {
+	cursor := "0"
+	for {
+		args := command(cursor)
+		reply, err := r.do(args...)
+		if err != nil {
+			return err
+		}
+		parts, _ := reply.([]interface{})
+		if len(parts) != 2 {
+			return fmt.Errorf("redis: unexpected %s reply", args[0])
+		}
+		next, _ := parts[0].([]byte)
+		replyItems, _ := parts[1].([]interface{})
+
+		items := make([]string, 0, len(replyItems))
+		for _, item := range replyItems {
+			if data, ok := item.([]byte); ok {
+				items = append(items, string(data))
+			}
+		}
+		if len(items) > 0 {
+			if err := fn(items); err != nil {
+				return err
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// do runs one command on an idle connection, or a new one. Connections are
+// closed after a network error, as their state is unknown. A command failing
+// on an idle connection, which the server may have closed meanwhile, is
+// tried once more on a new one.
+func (r *Redis) do(args ...string) (interface{}, error) {
+	var conn *respConn
+	pooled := true
+	select {
+	case conn = <-r.idle:
+	default:
+		pooled = false
+		var err error
+		if conn, err = r.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := conn.do(redisTimeout, args...)
+	if err != nil && !isRedisError(err) {
+		conn.Close()
+		if pooled {
+			if conn, err = r.dial(); err != nil {
+				return nil, err
+			}
+			reply, err = conn.do(redisTimeout, args...)
+		}
+		if err != nil && !isRedisError(err) {
+			conn.Close()
+			return nil, err
+		}
+	}
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// dial opens a connection, authenticated and on the configured database. A
+// rejected AUTH or SELECT is returned as a failure to reach the server, as no
+// command can succeed until the configuration is fixed.
+func (r *Redis) dial() (*respConn, error) {
+	conn, err := dialRESP(r.addr, r.tlsConfig, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var setup [][]string
+	switch {
+	case r.username != "" && r.password != "":
+		setup = append(setup, []string{"AUTH", r.username, r.password})
+	case r.password != "":
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, args := range setup {
+		if _, err := conn.do(redisTimeout, args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s failed: %v", args[0], err)
+		}
+	}
+	return conn, nil
+}
+
+// available reports whether Redis should be tried, that is whether it has not
+// failed within the last redisRetryInterval and holds no entries that missed a
+// deletion.
+func (r *Redis) available() bool {
+	r.mu.Lock()
+	up, missed := time.Now().After(r.downUntil), r.missed
+	r.mu.Unlock()
+	return up && (missed == 0 || r.flushMissed())
+}
+
+// flushMissed deletes every report cache key after deletions were missed, as
+// the entries they should have removed are unknown. Requests arriving during
+// the flush use the fallback.
+func (r *Redis) flushMissed() bool {
+	if !r.flushing.TryLock() {
+		return false
+	}
+	defer r.flushing.Unlock()
+
+	r.mu.Lock()
+	missed := r.missed
+	r.mu.Unlock()
+	if missed == 0 {
+		return true
+	}
+
+	if err := r.clearRedis(); err != nil {
+		// Even an error reply leaves stale entries, so Redis is not used
+		r.count(&r.stats.Errors)
+		r.markDown(err)
+		return false
+	}
+	r.mu.Lock()
+	r.missed -= missed
+	r.mu.Unlock()
+	log.Printf("report cache: redis at %s flushed after %d missed deletions", r.addr, missed)
+	return true
+}
+
+// miss records a deletion that did not reach Redis.
+func (r *Redis) miss() {
+	r.mu.Lock()
+	r.missed++
+	r.mu.Unlock()
+}
+
+// failed counts a failed command. Failures to reach the server switch to the
+// fallback; error replies are only logged.
+func (r *Redis) failed(err error) {
+	if isRedisError(err) {
+		r.logError(err)
+		return
+	}
+	r.count(&r.stats.Errors)
+	r.markDown(err)
+}
+
+// logError counts and logs an error that leaves Redis usable.
+func (r *Redis) logError(err error) {
+	r.count(&r.stats.Errors)
+	log.Printf("report cache: redis: %v", err)
+}
+
+func (r *Redis) markDown(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Now().After(r.downUntil) {
+		log.Printf("report cache: redis at %s unreachable, using memory for %s: %v", r.addr, redisRetryInterval, err)
+	}
+	r.downUntil = time.Now().Add(redisRetryInterval)
+}
+
+func (r *Redis) count(counter *int64) {
+	r.mu.Lock()
+	*counter++
+	r.mu.Unlock()
+}
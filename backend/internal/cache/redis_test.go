@@ -0,0 +1,562 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a stand-in Redis server on a local listener. It speaks enough
+// RESP2 for the Redis backend: PING, AUTH, SELECT, GET, SET with PX, DEL,
+// SCAN with MATCH, and ZADD, ZREM, ZREMRANGEBYSCORE and ZSCAN on sorted sets.
+type fakeRedis struct {
+	password string
+
+	mu       sync.Mutex
+	listener net.Listener
+	dbs      map[int]map[string]fakeValue
+	commands [][]string
+	conns    map[net.Conn]bool
+}
+
+// fakeValue is a string, or a sorted set when zset is set.
+type fakeValue struct {
+	data    string
+	zset    map[string]int64
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{
+		listener: listener,
+		password: password,
+		dbs:      make(map[int]map[string]fakeValue),
+		conns:    make(map[net.Conn]bool),
+	}
+	go f.serve(listener)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeRedis) Addr() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.listener.Addr().String()
+}
+
+// Close stops the server and drops its connections, as a crashed server would.
+// The data is kept for Restart.
+func (f *fakeRedis) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listener.Close()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+// Restart listens again on the address of the closed server.
+func (f *fakeRedis) Restart(t *testing.T) {
+	t.Helper()
+	listener, err := net.Listen("tcp", f.Addr())
+	if err != nil {
+		t.Fatalf("listen again: %v", err)
+	}
+	f.mu.Lock()
+	f.listener = listener
+	f.mu.Unlock()
+	go f.serve(listener)
+}
+
+// keys returns the live keys of database db.
+func (f *fakeRedis) keys(db int) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key, value := range f.dbs[db] {
+		if value.expires.IsZero() || time.Now().Before(value.expires) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// members returns the members of the sorted set key in database db.
+func (f *fakeRedis) members(db int, key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var members []string
+	for member := range f.dbs[db][key].zset {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (f *fakeRedis) set(db int, key, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dbs[db] == nil {
+		f.dbs[db] = make(map[string]fakeValue)
+	}
+	f.dbs[db][key] = fakeValue{data: data}
+}
+
+// sent returns the commands received so far named name.
+func (f *fakeRedis) sent(name string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var commands [][]string
+	for _, args := range f.commands {
+		if strings.EqualFold(args[0], name) {
+			commands = append(commands, args)
+		}
+	}
+	return commands
+}
+
+func (f *fakeRedis) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns[conn] = true
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	session := &fakeSession{authed: f.password == ""}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.exec(session, args)); err != nil {
+			return
+		}
+	}
+}
+
+type fakeSession struct {
+	authed bool
+	db     int
+}
+
+// exec runs one command and returns its encoded reply.
+func (f *fakeRedis) exec(session *fakeSession, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, args)
+
+	name := strings.ToUpper(args[0])
+	switch {
+	case name == "AUTH":
+		if args[len(args)-1] != f.password {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		session.authed = true
+		return "+OK\r\n"
+	case !session.authed:
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	if f.dbs[session.db] == nil {
+		f.dbs[session.db] = make(map[string]fakeValue)
+	}
+	db := f.dbs[session.db]
+	for key, value := range db {
+		if !value.expires.IsZero() && !time.Now().Before(value.expires) {
+			delete(db, key)
+		}
+	}
+
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "-ERR invalid DB index\r\n"
+		}
+		session.db = n
+		return "+OK\r\n"
+	case "GET":
+		value, ok := db[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		if value.zset != nil {
+			return wrongType
+		}
+		return bulk(value.data)
+	case "SET":
+		value := fakeValue{data: args[2]}
+		if len(args) == 5 && strings.EqualFold(args[3], "PX") {
+			millis, err := strconv.Atoi(args[4])
+			if err != nil {
+				return "-ERR value is not an integer or out of range\r\n"
+			}
+			value.expires = time.Now().Add(time.Duration(millis) * time.Millisecond)
+		}
+		db[args[1]] = value
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := db[key]; ok {
+				delete(db, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "SCAN":
+		// Every key in one batch; MATCH is the only option honored
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "MATCH") {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range db {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		reply := fmt.Sprintf("*2\r\n%s*%d\r\n", bulk("0"), len(keys))
+		for _, key := range keys {
+			reply += bulk(key)
+		}
+		return reply
+	case "ZADD", "ZREM", "ZREMRANGEBYSCORE", "ZSCAN":
+		value, ok := db[args[1]]
+		if ok && value.zset == nil {
+			return wrongType
+		}
+		if !ok {
+			value = fakeValue{zset: make(map[string]int64)}
+		}
+		reply, changed := value.zcommand(name, args[2:])
+		if changed {
+			db[args[1]] = value
+		}
+		if len(value.zset) == 0 {
+			delete(db, args[1])
+		}
+		return reply
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+// zcommand runs a sorted set command on v with the arguments after the key,
+// and reports whether v was written. Scores are integers, as the backend only
+// stores times.
+func (v fakeValue) zcommand(name string, args []string) (string, bool) {
+	switch name {
+	case "ZADD":
+		score, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return "-ERR value is not a valid float\r\n", false
+		}
+		_, exists := v.zset[args[1]]
+		v.zset[args[1]] = score
+		if exists {
+			return ":0\r\n", true
+		}
+		return ":1\r\n", true
+	case "ZREM":
+		removed := 0
+		for _, member := range args {
+			if _, ok := v.zset[member]; ok {
+				delete(v.zset, member)
+				removed++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", removed), removed > 0
+	case "ZREMRANGEBYSCORE":
+		if args[0] != "-inf" {
+			return "-ERR only -inf is supported as the minimum\r\n", false
+		}
+		max, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return "-ERR min or max is not a float\r\n", false
+		}
+		removed := 0
+		for member, score := range v.zset {
+			if score <= max {
+				delete(v.zset, member)
+				removed++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", removed), removed > 0
+	}
+	// ZSCAN: every member in one batch
+	reply := fmt.Sprintf("*2\r\n%s*%d\r\n", bulk("0"), 2*len(v.zset))
+	for member, score := range v.zset {
+		reply += bulk(member) + bulk(strconv.FormatInt(score, 10))
+	}
+	return reply, false
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// readCommand reads one command, an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected an array, got %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+type testReport struct {
+	Name  string   `json:"name"`
+	Lines []string `json:"lines"`
+}
+
+func newTestRedis(t *testing.T, url string) (*Redis, *Cache) {
+	t.Helper()
+	fallback := New(time.Minute, 0, 0)
+	r, err := NewRedis(url, time.Minute, fallback)
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return r, fallback
+}
+
+func TestRedisGetSet(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r, _ := newTestRedis(t, "redis://"+server.Addr())
+
+	var missing testReport
+	if _, ok := r.Get("pl:2024-01-01:2024-12-31", &missing); ok {
+		t.Fatal("Get of a missing key reported a hit")
+	}
+
+	want := testReport{Name: "P&L", Lines: []string{}}
+	before := time.Now()
+	r.SetWithTTL("pl:2024-01-01:2024-12-31", &want, 90*time.Second)
+
+	sets := server.sent("SET")
+	if len(sets) != 1 {
+		t.Fatalf("sent %d SET commands, want 1", len(sets))
+	}
+	if got := sets[0]; got[1] != keyPrefix+"pl:2024-01-01:2024-12-31" || len(got) != 5 || got[3] != "PX" || got[4] != "90000" {
+		t.Errorf("SET = %q, want the prefixed key with PX 90000", got)
+	}
+
+	var got testReport
+	entry, ok := r.Get("pl:2024-01-01:2024-12-31", &got)
+	if !ok {
+		t.Fatal("Get after SetWithTTL missed")
+	}
+	if got.Name != want.Name || got.Lines == nil || len(got.Lines) != 0 {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+	if entry.CachedAt.Before(before.Add(-time.Second)) || entry.ExpiresAt.Sub(entry.CachedAt) != 90*time.Second {
+		t.Errorf("entry = %+v, want cached now and expiring 90s later", entry)
+	}
+
+	stats := r.Stats()
+	if stats.Backend != "redis" || stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 || stats.Errors != 0 {
+		t.Errorf("Stats = %+v, want 1 hit, 1 miss, 1 entry and no errors", stats)
+	}
+}
+
+func TestRedisExpiry(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r, _ := newTestRedis(t, "redis://"+server.Addr())
+
+	r.SetWithTTL("pl:short", &testReport{Name: "short"}, 50*time.Millisecond)
+	var got testReport
+	if _, ok := r.Get("pl:short", &got); !ok {
+		t.Fatal("Get before the TTL passed missed")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := r.Get("pl:short", &got); ok {
+		t.Fatal("Get after the TTL passed reported a hit")
+	}
+}
+
+func TestRedisDeleteFuncAndClear(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r, fallback := newTestRedis(t, "redis://"+server.Addr())
+	server.set(0, "unrelated", "kept")
+
+	for _, key := range []string{"pl:1", "pl:2", "bs:1"} {
+		r.SetWithTTL(key, &testReport{Name: key}, time.Minute)
+	}
+	fallback.SetWithTTL("pl:3", &testReport{Name: "pl:3"}, time.Minute)
+
+	deleted := r.DeleteFunc(func(key string) bool {
+		return strings.HasPrefix(key, "pl:")
+	})
+	if deleted != 3 {
+		t.Errorf("DeleteFunc deleted %d, want 2 in Redis and 1 in the fallback", deleted)
+	}
+	if scans := server.sent("SCAN"); len(scans) != 0 {
+		t.Errorf("DeleteFunc sent %q, want the index read instead of the keyspace", scans)
+	}
+	if members := server.members(0, indexKey); len(members) != 1 || members[0] != keyPrefix+"bs:1" {
+		t.Errorf("index after DeleteFunc = %q, want only the remaining key", members)
+	}
+	var got testReport
+	for key, want := range map[string]bool{"pl:1": false, "pl:2": false, "bs:1": true} {
+		if _, ok := r.Get(key, &got); ok != want {
+			t.Errorf("Get(%s) after DeleteFunc = %v, want %v", key, ok, want)
+		}
+	}
+
+	r.Clear()
+	if keys := server.keys(0); len(keys) != 1 || keys[0] != "unrelated" {
+		t.Errorf("keys after Clear = %q, want only the unrelated key", keys)
+	}
+}
+
+func TestRedisDeleteFuncPrunesExpired(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r, _ := newTestRedis(t, "redis://"+server.Addr())
+
+	r.SetWithTTL("pl:short", &testReport{Name: "short"}, 20*time.Millisecond)
+	r.SetWithTTL("pl:long", &testReport{Name: "long"}, time.Minute)
+	time.Sleep(50 * time.Millisecond)
+
+	if deleted := r.DeleteFunc(func(string) bool { return false }); deleted != 0 {
+		t.Errorf("DeleteFunc matching nothing deleted %d", deleted)
+	}
+	if members := server.members(0, indexKey); len(members) != 1 || members[0] != keyPrefix+"pl:long" {
+		t.Errorf("index after DeleteFunc = %q, want the expired key dropped", members)
+	}
+}
+
+func TestRedisFlushesAfterMissedDeletions(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r, _ := newTestRedis(t, "redis://"+server.Addr())
+
+	r.SetWithTTL("pl:stale", &testReport{Name: "stale"}, time.Minute)
+	server.Close()
+	r.DeleteFunc(func(string) bool { return true })
+
+	server.Restart(t)
+	var got testReport
+	if _, ok := r.Get("pl:stale", &got); ok {
+		t.Fatal("Get before the retry interval passed reached Redis")
+	}
+	r.mu.Lock()
+	r.downUntil = time.Time{}
+	r.mu.Unlock()
+
+	if _, ok := r.Get("pl:stale", &got); ok {
+		t.Fatal("entry whose deletion was missed was served after Redis came back")
+	}
+	if keys := server.keys(0); len(keys) != 0 {
+		t.Errorf("keys after Redis came back = %q, want every report key flushed", keys)
+	}
+
+	// Once flushed, Redis is used again
+	r.SetWithTTL("pl:fresh", &testReport{Name: "fresh"}, time.Minute)
+	if keys := server.keys(0); len(keys) != 2 {
+		t.Errorf("keys after setting a fresh entry = %q, want it and the index", keys)
+	}
+}
+
+func TestRedisAuthSelect(t *testing.T) {
+	server := newFakeRedis(t, "s3cret")
+
+	for _, url := range []string{"redis://:s3cret@%s/2", "redis://reports:s3cret@%s/2"} {
+		r, _ := newTestRedis(t, fmt.Sprintf(url, server.Addr()))
+		r.SetWithTTL("pl:auth", &testReport{Name: "auth"}, time.Minute)
+		if stats := r.Stats(); stats.Errors != 0 {
+			t.Errorf("%s: Stats.Errors = %d, want 0", url, stats.Errors)
+		}
+	}
+	if keys := server.keys(2); len(keys) != 2 || keys[1] != keyPrefix+"pl:auth" {
+		t.Errorf("keys in database 2 = %q, want the index and the report key", keys)
+	}
+	if keys := server.keys(0); len(keys) != 0 {
+		t.Errorf("keys in database 0 = %q, want none", keys)
+	}
+	if auths := server.sent("AUTH"); len(auths) == 0 || len(auths[len(auths)-1]) != 3 || auths[len(auths)-1][1] != "reports" {
+		t.Errorf("AUTH = %q, want the username sent with the password", auths)
+	}
+
+	// A wrong password leaves Redis unusable, so the fallback serves requests
+	r, fallback := newTestRedis(t, fmt.Sprintf("redis://:wrong@%s/2", server.Addr()))
+	r.SetWithTTL("pl:wrong", &testReport{Name: "wrong"}, time.Minute)
+	var got testReport
+	if _, ok := fallback.Get("pl:wrong", &got); !ok {
+		t.Error("value set with a wrong password did not reach the fallback")
+	}
+}
+
+func TestRedisFallback(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r, fallback := newTestRedis(t, "redis://"+server.Addr())
+
+	r.SetWithTTL("pl:before", &testReport{Name: "before"}, time.Minute)
+	server.Close()
+
+	var got testReport
+	if _, ok := r.Get("pl:before", &got); ok {
+		t.Error("Get of a Redis entry after the server closed reported a hit")
+	}
+	r.SetWithTTL("pl:during", &testReport{Name: "during"}, time.Minute)
+	if _, ok := r.Get("pl:during", &got); !ok || got.Name != "during" {
+		t.Errorf("Get during the outage = %+v, %v, want the value from the fallback", got, ok)
+	}
+	if _, ok := fallback.Get("pl:during", &got); !ok {
+		t.Error("value set during the outage is not in the fallback")
+	}
+
+	stats := r.Stats()
+	if stats.Errors == 0 || stats.Fallback == nil || stats.Fallback.Entries != 1 {
+		t.Errorf("Stats = %+v, want errors counted and the fallback entry", stats)
+	}
+}
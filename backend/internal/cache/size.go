@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"reflect"
+	"time"
+)
+
+// timeType is skipped when walking values: its *time.Location is shared by
+// every time in the same zone.
+var timeType = reflect.TypeOf(time.Time{})
+
+// sizeOf estimates the memory held by v in bytes: the value itself plus what
+// it references through pointers, slices, maps, strings and interfaces.
+// Memory reachable twice is counted once. Allocator and map overhead is
+// ignored, so the estimate is a lower bound good enough for a budget.
+func sizeOf(v interface{}) int64 {
+	if v == nil {
+		return 0
+	}
+	value := reflect.ValueOf(v)
+	return int64(value.Type().Size()) + referencedSize(value, make(map[uintptr]bool))
+}
+
+// referencedSize is the memory referenced by v, excluding v itself.
+func referencedSize(v reflect.Value, seen map[uintptr]bool) int64 {
+	if v.Type() == timeType {
+		return 0
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + referencedSize(elem, seen)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + referencedSize(elem, seen)
+
+	case reflect.String:
+		return int64(v.Len())
+
+	case reflect.Slice:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), seen)
+		}
+		return size
+
+	case reflect.Array:
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), seen)
+		}
+		return size
+
+	case reflect.Map:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		entrySize := int64(v.Type().Key().Size() + v.Type().Elem().Size())
+		size := int64(v.Len()) * entrySize
+		iter := v.MapRange()
+		for iter.Next() {
+			size += referencedSize(iter.Key(), seen) + referencedSize(iter.Value(), seen)
+		}
+		return size
+
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += referencedSize(v.Field(i), seen)
+		}
+		return size
+	}
+	return 0
+}
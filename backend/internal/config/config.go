@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -16,6 +19,51 @@ type Config struct {
 	ServerHost   string
 	JWTSecret    string
 	Environment  string
+
+	// FiscalYearStartMonth is the first month (1-12) of the fiscal year used
+	// to resolve report periods such as FY2025 and YTD.
+	FiscalYearStartMonth int
+
+	// BudgetOverspendThresholdPct is how far (in percent) actual spend may
+	// exceed budget before a line is flagged as overspent.
+	BudgetOverspendThresholdPct float64
+
+	// CompanyName heads every page of PDF reports.
+	CompanyName string
+
+	// ReportJobWorkers is the number of report jobs run at the same time.
+	ReportJobWorkers int
+
+	// ReportJobMaxAttempts is how many times a failing report job is tried.
+	ReportJobMaxAttempts int
+
+	// ReportJobRetention is how long finished report jobs and their results
+	// are kept.
+	ReportJobRetention time.Duration
+
+	// SMTP server that emails scheduled reports. Without a host, scheduled
+	// reports still run but their deliveries fail.
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// AdminUsers may use the admin endpoints, such as cache statistics.
+	AdminUsers []string
+
+	// Report cache: how long reports are cached, how long reports within
+	// closed accounting periods are cached, and the limits beyond which the
+	// least recently used reports are evicted.
+	CacheTTL             time.Duration
+	CacheClosedPeriodTTL time.Duration
+	CacheMaxEntries      int
+	CacheMaxBytes        int64
+
+	// RedisURL, when set, shares the report cache between API replicas
+	// through a Redis-compatible server. The in-memory cache is used while
+	// it is unreachable.
+	RedisURL string
 }
 
 func Load() (*Config, error) {
@@ -30,12 +78,81 @@ func Load() (*Config, error) {
 		ServerHost:  getEnv("SERVER_HOST", "0.0.0.0"),
 		JWTSecret:   getEnv("JWT_SECRET", "financial_reporting_demo_secret_key_2024"),
 		Environment: getEnv("ENVIRONMENT", "development"),
+		CompanyName: getEnv("COMPANY_NAME", "Financial Reporting System"),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "reports@localhost"),
+
+		RedisURL: getEnv("REDIS_URL", ""),
 	}
 
 	if cfg.JWTSecret == "" {
 		return nil, fmt.Errorf("JWT_SECRET is required")
 	}
 
+	fiscalStart, err := strconv.Atoi(getEnv("FISCAL_YEAR_START_MONTH", "1"))
+	if err != nil || fiscalStart < 1 || fiscalStart > 12 {
+		return nil, fmt.Errorf("FISCAL_YEAR_START_MONTH must be a month number between 1 and 12")
+	}
+	cfg.FiscalYearStartMonth = fiscalStart
+
+	threshold, err := strconv.ParseFloat(getEnv("BUDGET_OVERSPEND_THRESHOLD_PCT", "10"), 64)
+	if err != nil || threshold < 0 {
+		return nil, fmt.Errorf("BUDGET_OVERSPEND_THRESHOLD_PCT must be a non-negative number")
+	}
+	cfg.BudgetOverspendThresholdPct = threshold
+
+	workers, err := strconv.Atoi(getEnv("REPORT_JOB_WORKERS", "2"))
+	if err != nil || workers < 1 {
+		return nil, fmt.Errorf("REPORT_JOB_WORKERS must be a positive integer")
+	}
+	cfg.ReportJobWorkers = workers
+
+	attempts, err := strconv.Atoi(getEnv("REPORT_JOB_MAX_ATTEMPTS", "3"))
+	if err != nil || attempts < 1 {
+		return nil, fmt.Errorf("REPORT_JOB_MAX_ATTEMPTS must be a positive integer")
+	}
+	cfg.ReportJobMaxAttempts = attempts
+
+	retention, err := time.ParseDuration(getEnv("REPORT_JOB_RETENTION", "168h"))
+	if err != nil || retention <= 0 {
+		return nil, fmt.Errorf("REPORT_JOB_RETENTION must be a positive duration such as 168h")
+	}
+	cfg.ReportJobRetention = retention
+
+	for _, username := range strings.Split(getEnv("ADMIN_USERS", ""), ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			cfg.AdminUsers = append(cfg.AdminUsers, username)
+		}
+	}
+
+	cacheTTL, err := time.ParseDuration(getEnv("CACHE_TTL", "5m"))
+	if err != nil || cacheTTL <= 0 {
+		return nil, fmt.Errorf("CACHE_TTL must be a positive duration such as 5m")
+	}
+	cfg.CacheTTL = cacheTTL
+
+	closedTTL, err := time.ParseDuration(getEnv("CACHE_CLOSED_PERIOD_TTL", "24h"))
+	if err != nil || closedTTL <= 0 {
+		return nil, fmt.Errorf("CACHE_CLOSED_PERIOD_TTL must be a positive duration such as 24h")
+	}
+	cfg.CacheClosedPeriodTTL = closedTTL
+
+	maxEntries, err := strconv.Atoi(getEnv("CACHE_MAX_ENTRIES", "1000"))
+	if err != nil || maxEntries < 0 {
+		return nil, fmt.Errorf("CACHE_MAX_ENTRIES must be a non-negative integer (0 for no limit)")
+	}
+	cfg.CacheMaxEntries = maxEntries
+
+	maxMB, err := strconv.Atoi(getEnv("CACHE_MAX_MB", "256"))
+	if err != nil || maxMB < 0 {
+		return nil, fmt.Errorf("CACHE_MAX_MB must be a non-negative integer (0 for no limit)")
+	}
+	cfg.CacheMaxBytes = int64(maxMB) << 20
+
 	return cfg, nil
 }
 
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+// WriteCSV writes every sheet as a metadata row, a column header row and the
+// data rows. Sheets after the first are separated by an empty line.
+func WriteCSV(w io.Writer, workbook Workbook, generatedAt time.Time) error {
+	writer := csv.NewWriter(w)
+	for i, sheet := range workbook.Sheets {
+		if i > 0 {
+			if err := writer.Write([]string{}); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(metaRow(sheet, generatedAt)); err != nil {
+			return err
+		}
+		if err := writer.Write(sheet.Columns); err != nil {
+			return err
+		}
+		for _, row := range sheet.Rows {
+			record := make([]string, len(row))
+			for j, cell := range row {
+				record[j] = cellText(cell)
+				if _, isText := cell.(string); isText {
+					record[j] = neutralizeFormula(record[j])
+				}
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// cellText formats a cell the way it is written to CSV and to XLSX numeric
+// cells: amounts keep both decimals, other numbers use their shortest form.
+func cellText(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case money.Amount:
+		return v.String()
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(cell)
+}
+
+// neutralizeFormula prefixes text that a spreadsheet would evaluate as a
+// formula, such as a customer named "=HYPERLINK(...)", with an apostrophe.
+func neutralizeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
@@ -0,0 +1,234 @@
+// Package export writes report responses as CSV files, XLSX workbooks and PDF
+// statements.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Format is the representation of a report response.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+	FormatPDF  Format = "pdf"
+)
+
+const (
+	// FormatParam overrides the Accept header.
+	FormatParam = "format"
+
+	MIMECSV  = "text/csv"
+	MIMEXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MIMEPDF  = "application/pdf"
+)
+
+// Field is one label and value of a sheet's metadata row.
+type Field struct {
+	Label string
+	Value string
+}
+
+// Sheet is one table of a report. Cells hold string, money.Amount, int,
+// int64, float64, *float64 (nil for an empty cell) or bool values; amounts
+// and numbers are written as numeric cells.
+type Sheet struct {
+	Name    string
+	Meta    []Field
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// AddRow appends one row of cells.
+func (s *Sheet) AddRow(cells ...interface{}) {
+	s.Rows = append(s.Rows, cells)
+}
+
+// Workbook is an exported report. Name is the file name without extension.
+type Workbook struct {
+	Name   string
+	Sheets []Sheet
+}
+
+// Exportable is implemented by report responses that can be written as CSV
+// or XLSX.
+type Exportable interface {
+	Workbook() Workbook
+}
+
+// Negotiate picks the response format from the format parameter, or else
+// from the Accept header. JSON is the default.
+func Negotiate(c *gin.Context) (Format, error) {
+	if value := c.Query(FormatParam); value != "" {
+		return ParseFormat(value)
+	}
+
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case MIMECSV:
+			return FormatCSV, nil
+		case MIMEXLSX:
+			return FormatXLSX, nil
+		case MIMEPDF:
+			return FormatPDF, nil
+		case "application/json", "*/*":
+			return FormatJSON, nil
+		}
+	}
+	return FormatJSON, nil
+}
+
+// ValidateFormat rejects requests with an unknown format parameter before any
+// report is computed.
+func ValidateFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := Negotiate(c); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Renderer writes report responses in the format each request negotiates.
+type Renderer struct {
+	// companyName heads every PDF page
+	companyName string
+}
+
+func NewRenderer(companyName string) *Renderer {
+	return &Renderer{companyName: companyName}
+}
+
+// Respond writes v in the negotiated format. Values that are not Exportable
+// are always written as JSON. Other formats are sent as an attachment.
+func (r *Renderer) Respond(c *gin.Context, status int, v interface{}) {
+	format, _ := Negotiate(c)
+	if _, ok := v.(Exportable); format == FormatJSON || !ok {
+		money.JSON(c, status, v)
+		return
+	}
+
+	file, err := r.Render(v, format, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", file.Disposition())
+	c.Data(status, file.ContentType, file.Data)
+}
+
+// File is a rendered report.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Disposition is the Content-Disposition header that downloads the file.
+func (f File) Disposition() string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": f.Name})
+}
+
+// Render writes v in format. JSON is written as is, so amounts are numbers;
+// pass money.Strings(v) for string amounts. The other formats need v to be
+// Exportable. PDFs use the report's own statement layout when it is Printable
+// and print its workbook otherwise.
+func (r *Renderer) Render(v interface{}, format Format, generatedAt time.Time) (File, error) {
+	if format == FormatJSON {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return File{}, fmt.Errorf("failed to encode report: %w", err)
+		}
+		name := "report"
+		if report, ok := v.(Exportable); ok {
+			name = report.Workbook().Name
+		}
+		return File{Name: name + ".json", ContentType: "application/json; charset=utf-8", Data: data}, nil
+	}
+
+	report, ok := v.(Exportable)
+	if !ok {
+		return File{}, fmt.Errorf("report cannot be exported as %s", format)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	var name, contentType string
+	switch format {
+	case FormatCSV:
+		workbook := report.Workbook()
+		name, contentType = workbook.Name, MIMECSV+"; charset=utf-8"
+		err = WriteCSV(&buf, workbook, generatedAt)
+	case FormatXLSX:
+		workbook := report.Workbook()
+		name, contentType = workbook.Name, MIMEXLSX
+		err = WriteXLSX(&buf, workbook, generatedAt)
+	case FormatPDF:
+		var statement Statement
+		if printable, ok := v.(Printable); ok {
+			statement = printable.Statement()
+		} else {
+			statement = StatementFromWorkbook(report.Workbook())
+		}
+		name, contentType = statement.Name, MIMEPDF
+		err = WritePDF(&buf, statement, PageInfo{Company: r.companyName, GeneratedAt: generatedAt})
+	default:
+		return File{}, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Name: fmt.Sprintf("%s.%s", name, format), ContentType: contentType, Data: buf.Bytes()}, nil
+}
+
+// ParseFormat checks a format name such as the format parameter.
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case FormatJSON, FormatCSV, FormatXLSX, FormatPDF:
+		return Format(strings.ToLower(value)), nil
+	}
+	return "", fmt.Errorf("%s must be one of %s, %s, %s or %s", FormatParam, FormatJSON, FormatCSV, FormatXLSX, FormatPDF)
+}
+
+// metaRow is the metadata header row of a sheet: the sheet's own fields
+// followed by the generation timestamp.
+func metaRow(sheet Sheet, generatedAt time.Time) []string {
+	row := make([]string, 0, 2*len(sheet.Meta)+2)
+	for _, field := range sheet.Meta {
+		row = append(row, field.Label, field.Value)
+	}
+	return append(row, "Generated At", generatedAt.Format(time.RFC3339))
+}
+
+// errWriter keeps the first write error so XML can be written without
+// checking every call.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) WriteString(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
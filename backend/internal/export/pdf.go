@@ -0,0 +1,496 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+// Page geometry, in points.
+const (
+	pdfMargin    = 50.0
+	pdfRowHeight = 14.0
+	pdfFontSize  = 9.0
+	pdfIndent    = 12.0 // per indent level
+	pdfPadding   = 12.0 // between columns
+	pdfMinLabel  = 140.0
+	pdfHeaderGap = 8.0
+)
+
+const (
+	pdfRegular = "F1" // Helvetica
+	pdfBold    = "F2" // Helvetica-Bold
+)
+
+type pageSize struct {
+	width, height float64
+}
+
+// A4
+var (
+	pdfPortrait  = pageSize{595, 842}
+	pdfLandscape = pageSize{842, 595}
+)
+
+// PageInfo is printed on every page of a PDF: the company name above the
+// title, and the generation time and page number in the footer.
+type PageInfo struct {
+	Company     string
+	GeneratedAt time.Time
+}
+
+// WritePDF renders the statement as an A4 PDF using the standard Helvetica
+// fonts. Pages are portrait unless a table needs the width of landscape.
+// Column headings are repeated on every page a table spans.
+//
+// The output depends only on its arguments, so the same statement and page
+// info always produce the same bytes.
+func WritePDF(w io.Writer, statement Statement, info PageInfo) error {
+	layout := newPDFLayout(statement, info)
+	for i, table := range statement.Tables {
+		layout.table(table, layout.widths[i], layout.right[i])
+	}
+	return writePDFDocument(w, statement.Title, info, layout.size, layout.finish())
+}
+
+type pdfLayout struct {
+	statement Statement
+	info      PageInfo
+	size      pageSize
+	widths    [][]float64 // per table: label column first
+	right     [][]bool    // per table: columns aligned right
+	pages     []*bytes.Buffer
+	page      *bytes.Buffer
+	y         float64 // top of the next row
+}
+
+func newPDFLayout(statement Statement, info PageInfo) *pdfLayout {
+	layout := &pdfLayout{statement: statement, info: info, size: pdfPortrait}
+
+	natural := make([][]float64, len(statement.Tables))
+	for i, table := range statement.Tables {
+		natural[i] = naturalWidths(table)
+		layout.right = append(layout.right, rightAligned(table, len(natural[i])))
+		if requiredWidth(natural[i], layout.right[i]) > pdfPortrait.width-2*pdfMargin {
+			layout.size = pdfLandscape
+		}
+	}
+	for i, widths := range natural {
+		layout.widths = append(layout.widths, fitWidths(widths, layout.right[i], layout.size.width-2*pdfMargin))
+	}
+
+	layout.newPage()
+	return layout
+}
+
+// naturalWidths is the width each column needs to print its widest cell.
+func naturalWidths(table Table) []float64 {
+	count := len(table.Columns)
+	for _, line := range table.Lines {
+		if n := 1 + len(line.Values); n > count {
+			count = n
+		}
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	widths := make([]float64, count)
+	for i, column := range table.Columns {
+		widths[i] = textWidth(column, true) + pdfPadding
+	}
+	for _, line := range table.Lines {
+		bold := line.Style != LineItem
+		widths[0] = max(widths[0], textWidth(line.Label, bold)+float64(line.Indent)*pdfIndent+pdfPadding)
+		for j, value := range line.Values {
+			widths[j+1] = max(widths[j+1], textWidth(pdfCellText(value), bold)+pdfPadding)
+		}
+	}
+	return widths
+}
+
+// requiredWidth is the width a table needs with its text columns cut down to
+// pdfMinLabel. Numbers are never cut.
+func requiredWidth(natural []float64, right []bool) float64 {
+	total := 0.0
+	for i, width := range natural {
+		if right[i] {
+			total += width
+		} else {
+			total += min(width, pdfMinLabel)
+		}
+	}
+	return total
+}
+
+// fitWidths gives the label column whatever the other columns leave over.
+// When the table is too wide, the text columns are narrowed first; the number
+// columns only when the text columns are down to pdfMinLabel.
+func fitWidths(natural []float64, right []bool, available float64) []float64 {
+	widths := append([]float64(nil), natural...)
+	numbers, text := 0.0, 0.0
+	for i, width := range natural {
+		if right[i] {
+			numbers += width
+		} else {
+			text += width
+		}
+	}
+
+	switch {
+	case numbers+text <= available:
+		widths[0] += available - numbers - text
+	case requiredWidth(natural, right) <= available:
+		scale := (available - numbers) / text
+		for i := range widths {
+			if !right[i] {
+				widths[i] *= scale
+			}
+		}
+	default:
+		scale := available / (numbers + text)
+		for i := range widths {
+			widths[i] *= scale
+		}
+	}
+	return widths
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = &bytes.Buffer{}
+	l.pages = append(l.pages, l.page)
+
+	top := l.size.height - pdfMargin
+	l.text(pdfMargin, top-14, pdfBold, 14, l.info.Company)
+	l.text(pdfMargin, top-32, pdfBold, 12, l.statement.Title)
+	y := top - 46
+	if l.statement.Period != "" {
+		l.text(pdfMargin, y, pdfRegular, 10, l.statement.Period)
+		y -= 12
+	}
+	for _, note := range l.statement.Notes {
+		l.text(pdfMargin, y, pdfRegular, pdfFontSize, note)
+		y -= 12
+	}
+	y += 12 - pdfHeaderGap
+	l.rule(pdfMargin, y, l.size.width-pdfMargin, y, 0.75)
+	l.y = y - pdfHeaderGap
+}
+
+// ensure starts a new page when fewer than rows rows fit on this one.
+func (l *pdfLayout) ensure(rows float64) bool {
+	if l.y-rows*pdfRowHeight >= pdfMargin+pdfHeaderGap {
+		return false
+	}
+	l.newPage()
+	return true
+}
+
+func (l *pdfLayout) table(table Table, widths []float64, right []bool) {
+
+	l.ensure(3)
+	l.tableHeader(table, table.Title, widths, right)
+	for i, line := range table.Lines {
+		rows := 1.0
+		if line.Style == LineHeading && i > 0 {
+			rows = 1.5
+		}
+		if l.ensure(rows) {
+			title := table.Title
+			if title != "" {
+				title += " (continued)"
+			}
+			l.tableHeader(table, title, widths, right)
+		} else if rows > 1 {
+			l.y -= pdfRowHeight / 2
+		}
+		l.line(line, widths, right)
+	}
+	l.y -= pdfRowHeight
+}
+
+func (l *pdfLayout) tableHeader(table Table, title string, widths []float64, right []bool) {
+	if title != "" {
+		l.text(pdfMargin, l.y-11, pdfBold, 11, title)
+		l.y -= pdfRowHeight + 2
+	}
+	if len(table.Columns) == 0 {
+		return
+	}
+	x := pdfMargin
+	for i, column := range table.Columns {
+		if i >= len(widths) {
+			break
+		}
+		padding := pdfPadding
+		if i == 0 {
+			padding = 0
+		}
+		l.cell(x, widths[i], padding, column, pdfBold, right[i])
+		x += widths[i]
+	}
+	l.y -= pdfRowHeight
+	l.rule(pdfMargin, l.y+2, l.size.width-pdfMargin, l.y+2, 0.5)
+	l.y -= 2
+}
+
+func (l *pdfLayout) line(line Line, widths []float64, right []bool) {
+	font := pdfBold
+	if line.Style == LineItem {
+		font = pdfRegular
+	}
+	indent := float64(line.Indent) * pdfIndent
+	l.cell(pdfMargin+indent, widths[0]-indent, 0, line.Label, font, false)
+
+	x := pdfMargin + widths[0]
+	for i, value := range line.Values {
+		if i+1 >= len(widths) {
+			break
+		}
+		width := widths[i+1]
+		if value != nil {
+			l.cell(x, width, pdfPadding, pdfCellText(value), font, right[i+1])
+			if line.Style == LineSubtotal || line.Style == LineTotal {
+				l.rule(x+pdfPadding, l.y, x+width, l.y, 0.5)
+			}
+			if line.Style == LineTotal {
+				bottom := l.y - pdfRowHeight + 1
+				l.rule(x+pdfPadding, bottom, x+width, bottom, 0.5)
+				l.rule(x+pdfPadding, bottom-1.5, x+width, bottom-1.5, 0.5)
+			}
+		}
+		x += width
+	}
+	l.y -= pdfRowHeight
+}
+
+// cell prints text in the current row within [x+padding, x+width]. Text
+// aligned left is cut short with an ellipsis when it does not fit; numbers
+// are always printed in full.
+func (l *pdfLayout) cell(x, width, padding float64, text, font string, alignRight bool) {
+	bold := font == pdfBold
+	if alignRight {
+		x += width - textWidth(text, bold)
+	} else {
+		// The padding before a value column already separates it from the
+		// column to its left; the label column keeps a gap on its right
+		text = fitText(text, bold, width-max(padding, pdfPadding/2))
+		x += padding
+	}
+	l.text(x, l.y-10, font, pdfFontSize, text)
+}
+
+// rightAligned reports which columns hold numbers. Those columns, and their
+// headings, are aligned right.
+func rightAligned(table Table, count int) []bool {
+	right := make([]bool, count)
+	for _, line := range table.Lines {
+		for i, value := range line.Values {
+			switch value.(type) {
+			case money.Amount, int, int64, float64, *float64:
+				if i+1 < count {
+					right[i+1] = true
+				}
+			}
+		}
+	}
+	return right
+}
+
+func (l *pdfLayout) text(x, y float64, font string, size float64, text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(l.page, "BT /%s %s Tf %s %s Td %s Tj ET\n", font, pdfNumber(size), pdfNumber(x), pdfNumber(y), pdfString(text))
+}
+
+func (l *pdfLayout) rule(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(l.page, "%s w %s %s m %s %s l S\n", pdfNumber(width), pdfNumber(x1), pdfNumber(y1), pdfNumber(x2), pdfNumber(y2))
+}
+
+// finish adds the footers, which need the page count.
+func (l *pdfLayout) finish() []*bytes.Buffer {
+	generated := "Generated " + l.info.GeneratedAt.UTC().Format("2006-01-02 15:04 MST")
+	for i, page := range l.pages {
+		l.page = page
+		l.text(pdfMargin, pdfMargin-20, pdfRegular, 8, generated)
+		number := fmt.Sprintf("Page %d of %d", i+1, len(l.pages))
+		l.text(l.size.width-pdfMargin-textWidth(number, false)*8/pdfFontSize, pdfMargin-20, pdfRegular, 8, number)
+	}
+	return l.pages
+}
+
+// writePDFDocument writes the catalog, the page tree, the two fonts, the
+// document info and a page and content stream object per page.
+func writePDFDocument(w io.Writer, title string, info PageInfo, size pageSize, pages []*bytes.Buffer) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	const firstPage = 6
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Author %s /Producer (financial-reporting-system) /CreationDate (D:%s) >>",
+		pdfString(title), pdfString(info.Company), info.GeneratedAt.UTC().Format("20060102150405Z")))
+
+	for i, page := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(size.width), pdfNumber(size.height), pdfRegular, pdfBold, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfCellText formats a value for print: amounts with thousands separators
+// and negatives in parentheses, other numbers with two decimals.
+func pdfCellText(cell interface{}) string {
+	switch v := cell.(type) {
+	case money.Amount:
+		return formatAmount(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', 2, 64)
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	}
+	return cellText(cell)
+}
+
+func formatAmount(amount money.Amount) string {
+	text := amount.String()
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	whole, cents, _ := strings.Cut(text, ".")
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	text = grouped.String() + "." + cents
+
+	if negative {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// pdfNumber formats a coordinate to a hundredth of a point.
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// pdfString encodes text as a PDF literal string in WinAnsiEncoding. Latin-1
+// characters are kept; anything else is printed as "?".
+func pdfString(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// fitText shortens text with "..." until it fits in width.
+func fitText(text string, bold bool, width float64) string {
+	if textWidth(text, bold) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if shortened := string(runes) + "..."; textWidth(shortened, bold) <= width {
+			return shortened
+		}
+	}
+	return ""
+}
+
+// textWidth is the printed width of text at the table font size.
+func textWidth(text string, bold bool) float64 {
+	metrics := &helveticaWidths
+	if bold {
+		metrics = &helveticaBoldWidths
+	}
+	units := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			units += metrics[r-32]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * pdfFontSize / 1000
+}
+
+// Glyph widths of the printable ASCII characters, from the Adobe font
+// metrics of the standard fonts, in 1/1000 of the font size.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+	278, 278, 584, 584, 584, 556, 1015, // : to @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+	278, 278, 278, 469, 556, 333, // [ to `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+	334, 260, 334, 584, // { to ~
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+	333, 333, 584, 584, 584, 611, 975, // : to @
+	722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, // A to M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+	333, 278, 333, 584, 556, 333, // [ to `
+	556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, // a to m
+	611, 611, 611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, // n to z
+	389, 280, 389, 584, // { to ~
+}
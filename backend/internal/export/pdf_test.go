@@ -0,0 +1,156 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var testPageInfo = PageInfo{
+	Company:     "Acme (Holdings) Ltd",
+	GeneratedAt: time.Date(2025, time.January, 15, 9, 30, 0, 0, time.UTC),
+}
+
+func testProfitLoss() Statement {
+	return Statement{
+		Name:   "profit-and-loss",
+		Title:  "Profit and Loss",
+		Period: PeriodLabel("2024-01-01", "2024-12-31"),
+		Notes:  []string{"Currency: USD"},
+		Tables: []Table{{
+			Columns: []string{"Account", "Amount"},
+			Lines: []Line{
+				{Style: LineHeading, Label: "Revenue"},
+				{Label: "Product sales", Indent: 1, Values: []interface{}{money.FromCents(125000000)}},
+				{Label: "Services", Indent: 1, Values: []interface{}{money.FromCents(4250050)}},
+				{Style: LineSubtotal, Label: "Total Revenue", Values: []interface{}{money.FromCents(129250050)}},
+				{Style: LineHeading, Label: "Expenses"},
+				{Label: "Salaries", Indent: 1, Values: []interface{}{money.FromCents(80000000)}},
+				{Label: "Rent \\ utilities", Indent: 1, Values: []interface{}{money.FromCents(1200000)}},
+				{Label: "Foreign exchange loss", Indent: 1, Values: []interface{}{money.FromCents(-35099)}},
+				{Style: LineSubtotal, Label: "Total Expenses", Values: []interface{}{money.FromCents(81164901)}},
+				{Style: LineTotal, Label: "Net Income", Values: []interface{}{money.FromCents(48085149)}},
+			},
+		}},
+	}
+}
+
+// testAging is wide enough for landscape and long enough to span pages.
+func testAging() Statement {
+	table := Table{
+		Title:   "Customers",
+		Columns: []string{"Customer", "Current", "1-30 Days", "31-60 Days", "61-90 Days", "Over 90 Days", "Total Outstanding", "Share"},
+	}
+	for i := 1; i <= 60; i++ {
+		cents := int64(i) * 123457
+		share := float64(i) / 18.3
+		table.Lines = append(table.Lines, Line{
+			Label: fmt.Sprintf("Customer %02d with a rather long trading name that needs cutting", i),
+			Values: []interface{}{
+				money.FromCents(cents), money.FromCents(cents / 2), money.FromCents(cents / 3),
+				money.FromCents(0), money.FromCents(-cents / 7), money.FromCents(cents*2 - cents/7), &share,
+			},
+		})
+	}
+	var missing *float64
+	table.Lines = append(table.Lines, Line{
+		Style:  LineTotal,
+		Label:  "Total",
+		Values: []interface{}{money.FromCents(1), money.FromCents(2), money.FromCents(3), money.FromCents(4), money.FromCents(5), money.FromCents(6), missing},
+	})
+	return Statement{
+		Name:   "ar-aging",
+		Title:  "Accounts Receivable Aging",
+		Period: AsOfLabel("2024-12-31"),
+		Tables: []Table{table},
+	}
+}
+
+// testLedger has text in value columns, which print in full when they fit.
+func testLedger() Statement {
+	return StatementFromWorkbook(Workbook{
+		Name: "general-ledger",
+		Sheets: []Sheet{{
+			Name:    "General Ledger",
+			Meta:    []Field{{"Report", "General Ledger"}, {"Start Date", "2024-01-01"}, {"End Date", "2024-01-31"}},
+			Columns: []string{"Date", "Reference", "Amount", "Reversal Of"},
+			Rows: [][]interface{}{
+				{"2024-01-05", "INV-1001", money.FromCents(150000), ""},
+				{"2024-01-09", "ADJ-0007", money.FromCents(-150000), "0b5c2f6e-3d1a-4f7b-9c2e-8a1d4e6f7a90"},
+			},
+		}},
+	})
+}
+
+func TestWritePDFGolden(t *testing.T) {
+	tests := []struct {
+		golden    string
+		statement Statement
+	}{
+		{"profit_loss.pdf.golden", testProfitLoss()},
+		{"ar_aging.pdf.golden", testAging()},
+		{"ledger.pdf.golden", testLedger()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WritePDF(&out, tt.statement, testPageInfo); err != nil {
+				t.Fatalf("WritePDF: %v", err)
+			}
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s (run go test -update if the change is intended)\n%s", path, firstDifference(out.Bytes(), want))
+			}
+		})
+	}
+}
+
+func TestWritePDFDeterministic(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := WritePDF(&first, testAging(), testPageInfo); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePDF(&second, testAging(), testPageInfo); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("the same statement rendered to different bytes")
+	}
+}
+
+// firstDifference describes the first line where got and want differ.
+func firstDifference(got, want []byte) string {
+	gotLines := bytes.Split(got, []byte("\n"))
+	wantLines := bytes.Split(want, []byte("\n"))
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w []byte
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if !bytes.Equal(g, w) {
+			return fmt.Sprintf("line %d:\n got: %q\nwant: %q", i+1, g, w)
+		}
+	}
+	return "lengths differ"
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LineStyle selects how a statement line is printed.
+type LineStyle int
+
+const (
+	// LineItem is a regular line.
+	LineItem LineStyle = iota
+	// LineHeading is a bold section heading without values.
+	LineHeading
+	// LineSubtotal is bold with a rule above its values.
+	LineSubtotal
+	// LineTotal is bold with a rule above and a double rule below its values.
+	LineTotal
+)
+
+// Line is one printed line: a label followed by one value per value column.
+// Values take the same cell types as Sheet rows.
+type Line struct {
+	Style  LineStyle
+	Label  string
+	Indent int
+	Values []interface{}
+}
+
+// Table is a titled block of lines. Columns are the headings of the label
+// column followed by the value columns.
+type Table struct {
+	Title   string
+	Columns []string
+	Lines   []Line
+}
+
+// Statement is a report laid out for printing.
+type Statement struct {
+	// Name is the file name without extension.
+	Name   string
+	Title  string
+	Period string
+	// Notes are printed under the period, one per line.
+	Notes  []string
+	Tables []Table
+}
+
+// Printable is implemented by reports with a statement layout of their own.
+// Other Exportable reports are printed from their workbook.
+type Printable interface {
+	Statement() Statement
+}
+
+// PeriodLabel describes a date range as "For the period 1 January 2024 to
+// 31 December 2024". Dates that do not parse are printed as given.
+func PeriodLabel(startDate, endDate string) string {
+	return fmt.Sprintf("For the period %s to %s", longDate(startDate), longDate(endDate))
+}
+
+// AsOfLabel describes a point in time as "As of 31 December 2024".
+func AsOfLabel(asOf string) string {
+	return "As of " + longDate(asOf)
+}
+
+func longDate(date string) string {
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		return t.Format("2 January 2006")
+	}
+	return date
+}
+
+// StatementFromWorkbook prints a workbook with one table per sheet. The report
+// title and dates come from the first sheet's metadata; its other fields
+// become notes.
+func StatementFromWorkbook(workbook Workbook) Statement {
+	statement := Statement{Name: workbook.Name}
+	if len(workbook.Sheets) > 0 {
+		var startDate, endDate, asOf string
+		for _, field := range workbook.Sheets[0].Meta {
+			switch field.Label {
+			case "Report":
+				statement.Title = field.Value
+			case "Start Date":
+				startDate = field.Value
+			case "End Date":
+				endDate = field.Value
+			case "As Of":
+				asOf = field.Value
+			default:
+				statement.Notes = append(statement.Notes, field.Label+": "+field.Value)
+			}
+		}
+		switch {
+		case startDate != "" && endDate != "":
+			statement.Period = PeriodLabel(startDate, endDate)
+		case asOf != "":
+			statement.Period = AsOfLabel(asOf)
+		}
+	}
+	if statement.Title == "" {
+		statement.Title = workbook.Name
+	}
+
+	for _, sheet := range workbook.Sheets {
+		table := Table{Columns: sheet.Columns}
+		if len(workbook.Sheets) > 1 {
+			table.Title = sheet.Name
+		}
+		for _, row := range sheet.Rows {
+			line := Line{}
+			if len(row) > 0 {
+				line.Label = cellText(row[0])
+				line.Values = row[1:]
+			}
+			if strings.HasPrefix(line.Label, "Total") {
+				line.Style = LineTotal
+			}
+			table.Lines = append(table.Lines, line)
+		}
+		statement.Tables = append(statement.Tables, table)
+	}
+	return statement
+}
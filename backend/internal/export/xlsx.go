@@ -0,0 +1,213 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+// Cell styles defined in xlsxStyles.
+const (
+	styleDefault = 0
+	styleAmount  = 1 // #,##0.00
+	styleHeader  = 2 // bold
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+%s</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+</styleSheet>`
+
+// WriteXLSX writes the workbook as an Office Open XML spreadsheet with one
+// worksheet per sheet. Each worksheet starts with the metadata row, followed
+// by a bold column header row and the data rows. Amounts are numeric cells
+// formatted with two decimals.
+func WriteXLSX(w io.Writer, workbook Workbook, generatedAt time.Time) error {
+	archive := zip.NewWriter(w)
+	names := sheetNames(workbook.Sheets)
+
+	var overrides, sheets, rels strings.Builder
+	for i, name := range names {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", i+1)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlText(name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n", i+1, i+1)
+	}
+	stylesID := len(names) + 1
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`+"\n", stylesID)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, overrides.String())},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+` + rels.String() + `</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		if err := writePart(archive, part.name, generatedAt, func(out *errWriter) { out.WriteString(part.content) }); err != nil {
+			return err
+		}
+	}
+
+	for i, sheet := range workbook.Sheets {
+		err := writePart(archive, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), generatedAt, func(out *errWriter) {
+			writeWorksheet(out, sheet, generatedAt)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writePart(archive *zip.Writer, name string, modified time.Time, write func(*errWriter)) error {
+	part, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	out := &errWriter{w: part}
+	write(out)
+	if out.err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, out.err)
+	}
+	return nil
+}
+
+func writeWorksheet(out *errWriter, sheet Sheet, generatedAt time.Time) {
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	meta := metaRow(sheet, generatedAt)
+	metaCells := make([]interface{}, len(meta))
+	for i, value := range meta {
+		metaCells[i] = value
+	}
+	writeRow(out, 1, metaCells, styleDefault)
+
+	headers := make([]interface{}, len(sheet.Columns))
+	for i, column := range sheet.Columns {
+		headers[i] = column
+	}
+	writeRow(out, 2, headers, styleHeader)
+
+	for i, row := range sheet.Rows {
+		writeRow(out, i+3, row, styleDefault)
+	}
+
+	out.WriteString(`</sheetData></worksheet>`)
+}
+
+func writeRow(out *errWriter, number int, cells []interface{}, style int) {
+	out.WriteString(fmt.Sprintf(`<row r="%d">`, number))
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(number)
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case *float64:
+			if v == nil {
+				continue
+			}
+			out.WriteString(fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, style, cellText(v)))
+		case money.Amount:
+			out.WriteString(fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, styleAmount, v.String()))
+		case int, int64, float64:
+			out.WriteString(fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, style, cellText(v)))
+		case bool:
+			value := "0"
+			if v {
+				value = "1"
+			}
+			out.WriteString(fmt.Sprintf(`<c r="%s" s="%d" t="b"><v>%s</v></c>`, ref, style, value))
+		default:
+			out.WriteString(fmt.Sprintf(`<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+				ref, style, xmlText(cellText(v))))
+		}
+	}
+	out.WriteString(`</row>`)
+}
+
+// columnName converts a zero-based column index to its letters: A, B, ... Z, AA.
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// sheetNames makes sheet names valid and unique: at most 31 characters and
+// none of the characters Excel rejects.
+func sheetNames(sheets []Sheet) []string {
+	names := make([]string, len(sheets))
+	used := make(map[string]bool, len(sheets))
+	for i, sheet := range sheets {
+		base := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return '_'
+			}
+			return r
+		}, sheet.Name)
+		if base == "" {
+			base = "Sheet"
+		}
+		base = truncate(base, 31)
+
+		name := base
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			suffix := fmt.Sprintf(" (%d)", n)
+			name = truncate(base, 31-len(suffix)) + suffix
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package fx
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListRates handles GET /api/fx-rates
+func (h *Handler) ListRates(c *gin.Context) {
+	var filter RateFilter
+	if value := c.Query("currency"); value != "" {
+		code, err := NormalizeCurrency(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.Currency = code
+	}
+	for _, f := range []struct {
+		name string
+		dest *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		value := c.Query(f.name)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": f.name + " must be formatted as YYYY-MM-DD"})
+			return
+		}
+		*f.dest = date
+	}
+
+	rates, err := h.service.ListRates(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// SetRate handles POST /api/fx-rates
+func (h *Handler) SetRate(c *gin.Context) {
+	var req RateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := h.service.SetRate(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// ListLedgers handles GET /api/ledgers
+func (h *Handler) ListLedgers(c *gin.Context) {
+	ledgers, err := h.service.ListLedgers(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ledgers})
+}
+
+func writeError(c *gin.Context, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,214 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ValidationError is returned when an exchange rate is rejected. Its message
+// is safe to show to API clients.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+var (
+	// ErrInvalidCurrency is returned for currency codes that are not three letters.
+	ErrInvalidCurrency = errors.New("currency must be a three-letter ISO 4217 code")
+	// ErrMissingRate is returned when no exchange rate exists on or before a date.
+	ErrMissingRate = errors.New("no exchange rate available")
+)
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// NormalizeCurrency upper-cases a currency code and checks that it has the
+// ISO 4217 shape. Whether rates exist for it is only known when converting.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !currencyCode.MatchString(code) {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+// IsMissingRate reports whether err was raised by fn_fx_rate because a
+// conversion had no exchange rate on or before the transaction date.
+func IsMissingRate(err error) bool {
+	if errors.Is(err, ErrMissingRate) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "FR003"
+}
+
+type Service struct {
+	db     *pgxpool.Pool
+	schema string
+}
+
+func NewService(db *pgxpool.Pool, schema string) *Service {
+	return &Service{
+		db:     db,
+		schema: schema,
+	}
+}
+
+// RateRequest sets the rate of one currency pair from a date on: one unit of
+// BaseCurrency is worth Rate units of QuoteCurrency.
+type RateRequest struct {
+	BaseCurrency  string   `json:"base_currency" binding:"required"`
+	QuoteCurrency string   `json:"quote_currency" binding:"required"`
+	RateDate      string   `json:"rate_date" binding:"required"`
+	Rate          *float64 `json:"rate" binding:"required"`
+}
+
+type Rate struct {
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	RateDate      string    `json:"rate_date"`
+	Rate          float64   `json:"rate"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type Ledger struct {
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	BaseCurrency string `json:"base_currency"`
+	IsDefault    bool   `json:"is_default"`
+}
+
+// RateFilter narrows ListRates to one currency and a date range. Empty fields
+// are ignored.
+type RateFilter struct {
+	Currency string
+	From     time.Time
+	To       time.Time
+}
+
+// ListRates returns stored rates, newest first. A currency filter matches
+// either side of the pair.
+func (s *Service) ListRates(ctx context.Context, filter RateFilter) ([]Rate, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		conditions = append(conditions, fmt.Sprintf("(base_currency = $%d OR quote_currency = $%d)", len(args), len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("rate_date >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("rate_date <= $%d", len(args)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT base_currency, quote_currency, rate_date, rate, updated_at
+		FROM "%s".fx_rates
+		WHERE %s
+		ORDER BY rate_date DESC, base_currency, quote_currency`, s.schema, strings.Join(conditions, " AND "))
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []Rate{}
+	for rows.Next() {
+		var rate Rate
+		var rateDate time.Time
+		if err := rows.Scan(&rate.BaseCurrency, &rate.QuoteCurrency, &rateDate, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		rate.RateDate = rateDate.Format("2006-01-02")
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return rates, nil
+}
+
+// SetRate creates or replaces the rate of a currency pair on a date.
+func (s *Service) SetRate(ctx context.Context, req RateRequest) (*Rate, error) {
+	base, err := NormalizeCurrency(req.BaseCurrency)
+	if err != nil {
+		return nil, invalid("base_currency: %v", err)
+	}
+	quote, err := NormalizeCurrency(req.QuoteCurrency)
+	if err != nil {
+		return nil, invalid("quote_currency: %v", err)
+	}
+	if base == quote {
+		return nil, invalid("base_currency and quote_currency must differ")
+	}
+	rateDate, err := time.Parse("2006-01-02", req.RateDate)
+	if err != nil {
+		return nil, invalid("rate_date must be formatted as YYYY-MM-DD")
+	}
+	if req.Rate == nil || *req.Rate <= 0 || math.IsInf(*req.Rate, 0) || math.IsNaN(*req.Rate) {
+		return nil, invalid("rate must be greater than zero")
+	}
+
+	rate := &Rate{BaseCurrency: base, QuoteCurrency: quote, RateDate: rateDate.Format("2006-01-02")}
+	query := fmt.Sprintf(`
+		INSERT INTO "%s".fx_rates (base_currency, quote_currency, rate_date, rate)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency, rate_date)
+		DO UPDATE SET rate = EXCLUDED.rate, updated_at = CURRENT_TIMESTAMP
+		RETURNING rate, updated_at`, s.schema)
+	err = s.db.QueryRow(ctx, query, base, quote, rateDate, *req.Rate).Scan(&rate.Rate, &rate.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22003" { // numeric_value_out_of_range
+			return nil, invalid("rate is too large")
+		}
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	return rate, nil
+}
+
+// ListLedgers returns every ledger with its base currency.
+func (s *Service) ListLedgers(ctx context.Context) ([]Ledger, error) {
+	query := fmt.Sprintf(`
+		SELECT code, name, base_currency, is_default
+		FROM "%s".ledgers
+		ORDER BY is_default DESC, code`, s.schema)
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledgers: %w", err)
+	}
+	defer rows.Close()
+
+	ledgers := []Ledger{}
+	for rows.Next() {
+		var ledger Ledger
+		if err := rows.Scan(&ledger.Code, &ledger.Name, &ledger.BaseCurrency, &ledger.IsDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ledgers = append(ledgers, ledger)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return ledgers, nil
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// CreateJob handles POST /api/report-jobs
+func (h *Handler) CreateJob(c *gin.Context) {
+	var req JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.service.Enqueue(c.Request.Context(), req, c.GetString("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/report-jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob handles GET /api/report-jobs/:id
+func (h *Handler) GetJob(c *gin.Context) {
+	job, err := h.service.Get(c.Request.Context(), c.Param("id"), c.GetString("username"), c.GetBool("is_admin"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadResult handles GET /api/report-jobs/:id/download
+func (h *Handler) DownloadResult(c *gin.Context) {
+	file, err := h.service.Result(c.Request.Context(), c.Param("id"), c.GetString("username"), c.GetBool("is_admin"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", file.Disposition())
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// DeleteJob handles DELETE /api/report-jobs/:id
+func (h *Handler) DeleteJob(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id"), c.GetString("username"), c.GetBool("is_admin")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func writeError(c *gin.Context, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message})
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrResultNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,278 @@
+// Package jobs runs reports in the background for date ranges too large to
+// compute within an HTTP request.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/money"
+	"financial-reporting-system/internal/reports"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Job statuses. Queued jobs include failed attempts waiting to be retried.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ValidationError is returned when a job is rejected. Its message is safe to
+// show to API clients.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+var (
+	// ErrJobNotFound is returned when a job ID does not exist.
+	ErrJobNotFound = errors.New("report job not found")
+	// ErrResultNotReady is returned when downloading a job that has not succeeded.
+	ErrResultNotReady = errors.New("report job has not succeeded")
+)
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+type Service struct {
+	db          *pgxpool.Pool
+	runner      *reports.Runner
+	renderer    *export.Renderer
+	schema      string
+	maxAttempts int
+	retention   time.Duration
+	// wake tells an idle worker that a job was queued
+	wake chan struct{}
+	// running tracks the goroutines started by Start
+	running sync.WaitGroup
+}
+
+// NewService creates a report job service. Each job is attempted at most
+// maxAttempts times, and finished jobs are deleted with their results once
+// they are older than retention.
+func NewService(db *pgxpool.Pool, runner *reports.Runner, renderer *export.Renderer, schema string, maxAttempts int, retention time.Duration) *Service {
+	return &Service{
+		db:          db,
+		runner:      runner,
+		renderer:    renderer,
+		schema:      schema,
+		maxAttempts: maxAttempts,
+		retention:   retention,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// JobRequest queues a report. Params are the query parameters of the report's
+// endpoint, plus amounts=string for JSON results. Format defaults to json.
+type JobRequest struct {
+	ReportType string            `json:"report_type" binding:"required"`
+	Params     map[string]string `json:"params"`
+	Format     string            `json:"format"`
+}
+
+type Job struct {
+	ID          string            `json:"id"`
+	ReportType  string            `json:"report_type"`
+	Params      map[string]string `json:"params"`
+	Format      string            `json:"format"`
+	Status      string            `json:"status"`
+	Progress    int               `json:"progress"`
+	Attempts    int               `json:"attempts"`
+	MaxAttempts int               `json:"max_attempts"`
+	LastError   string            `json:"last_error,omitempty"`
+	NextRunAt   *time.Time        `json:"next_run_at,omitempty"`
+	FileName    string            `json:"file_name,omitempty"`
+	DownloadURL string            `json:"download_url,omitempty"`
+	CreatedBy   string            `json:"created_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Enqueue validates the report parameters and queues the job. Named periods
+// such as YTD are resolved when the job runs.
+func (s *Service) Enqueue(ctx context.Context, req JobRequest, createdBy string) (*Job, error) {
+	id, err := s.insert(ctx, s.db, req, createdBy, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.notify()
+	return s.Get(ctx, id, createdBy, true)
+}
+
+// EnqueueTx queues a job as part of tx, for callers that record the job along
+// with rows of their own. Named periods resolve against referenceDate instead
+// of the date the job runs. Workers pick the job up once tx commits.
+func (s *Service) EnqueueTx(ctx context.Context, tx pgx.Tx, req JobRequest, createdBy string, referenceDate time.Time) (string, error) {
+	id, err := s.insert(ctx, tx, req, createdBy, &referenceDate)
+	if err != nil {
+		return "", err
+	}
+	s.notify()
+	return id, nil
+}
+
+// querier is implemented by both the pool and transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Validate checks a job request without queueing it, resolving named periods
+// against now.
+func (s *Service) Validate(req JobRequest, now time.Time) error {
+	_, err := s.validate(req, now)
+	return err
+}
+
+// validate checks a job request and returns its format.
+func (s *Service) validate(req JobRequest, now time.Time) (export.Format, error) {
+	format := export.FormatJSON
+	if req.Format != "" {
+		var err error
+		if format, err = export.ParseFormat(req.Format); err != nil {
+			return "", invalid("%s", err.Error())
+		}
+	}
+	if amounts, ok := req.Params[money.FormatParam]; ok {
+		if _, err := money.ParseFormat(amounts); err != nil {
+			return "", invalid("%s", err.Error())
+		}
+	}
+	if _, err := s.runner.Prepare(req.ReportType, urlValues(req.Params), now); err != nil {
+		return "", invalid("%s", err.Error())
+	}
+	return format, nil
+}
+
+func (s *Service) insert(ctx context.Context, db querier, req JobRequest, createdBy string, referenceDate *time.Time) (string, error) {
+	now := time.Now()
+	if referenceDate != nil {
+		now = *referenceDate
+	}
+	format, err := s.validate(req, now)
+	if err != nil {
+		return "", err
+	}
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO "%s".report_jobs (report_type, params, format, max_attempts, created_by, reference_date)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id`, s.schema)
+	var id string
+	err = db.QueryRow(ctx, query, req.ReportType, req.Params, string(format), s.maxAttempts, createdBy, referenceDate).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to queue report job: %w", err)
+	}
+	return id, nil
+}
+
+// notify wakes an idle worker; a busy pool picks the job up on its next poll.
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get returns a job without its result. Users see only the jobs they
+// created, administrators every job; other jobs are not found.
+func (s *Service) Get(ctx context.Context, id, username string, admin bool) (*Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, report_type, params, format, status, progress, attempts, max_attempts,
+			COALESCE(last_error, ''), run_at, COALESCE(file_name, ''), COALESCE(created_by, ''),
+			created_at, started_at, finished_at
+		FROM "%s".report_jobs
+		WHERE id = $1 AND ($2 OR created_by = $3)`, s.schema)
+	var job Job
+	var runAt time.Time
+	err := s.db.QueryRow(ctx, query, id, admin, username).Scan(&job.ID, &job.ReportType, &job.Params, &job.Format, &job.Status,
+		&job.Progress, &job.Attempts, &job.MaxAttempts, &job.LastError, &runAt, &job.FileName, &job.CreatedBy,
+		&job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report job: %w", err)
+	}
+
+	switch job.Status {
+	case StatusQueued:
+		job.NextRunAt = &runAt
+	case StatusSucceeded:
+		job.DownloadURL = "/api/report-jobs/" + job.ID + "/download"
+	}
+	return &job, nil
+}
+
+// Result returns the rendered report of a succeeded job, with the same
+// access as Get.
+func (s *Service) Result(ctx context.Context, id, username string, admin bool) (*export.File, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT status, COALESCE(file_name, ''), COALESCE(content_type, ''), result
+		FROM "%s".report_jobs
+		WHERE id = $1 AND ($2 OR created_by = $3)`, s.schema)
+	var status string
+	var file export.File
+	err := s.db.QueryRow(ctx, query, id, admin, username).Scan(&status, &file.Name, &file.ContentType, &file.Data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report job result: %w", err)
+	}
+	if status != StatusSucceeded {
+		return nil, ErrResultNotReady
+	}
+	return &file, nil
+}
+
+// Delete removes a job and its result, with the same access as Get. A job
+// that is running finishes, but its result is discarded.
+func (s *Service) Delete(ctx context.Context, id, username string, admin bool) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrJobNotFound
+	}
+
+	query := fmt.Sprintf(`DELETE FROM "%s".report_jobs WHERE id = $1 AND ($2 OR created_by = $3)`, s.schema)
+	tag, err := s.db.Exec(ctx, query, id, admin, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete report job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func urlValues(params map[string]string) url.Values {
+	values := make(url.Values, len(params))
+	for name, value := range params {
+		values.Set(name, value)
+	}
+	return values
+}
@@ -0,0 +1,278 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// pollInterval is how often idle workers look for due jobs, such as
+	// retries and jobs queued by another API process.
+	pollInterval = 2 * time.Second
+	// leaseDuration is how long a job stays claimed without its worker
+	// renewing the lease. A job whose worker died runs again after it.
+	leaseDuration = 2 * time.Minute
+	// retryBackoff is the delay before the first retry; it doubles with each
+	// further attempt.
+	retryBackoff = 30 * time.Second
+	// sweepInterval is how often finished jobs past their retention are
+	// deleted.
+	sweepInterval = time.Hour
+)
+
+// Progress of a running job, in percent.
+const (
+	progressPrepared = 10
+	progressComputed = 80
+	progressRendered = 90
+)
+
+// permanentError marks failures that a retry cannot fix, such as parameters
+// that no longer validate.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Start runs workers goroutines that run queued jobs, and one that deletes
+// expired jobs, until ctx is canceled. A job interrupted by cancellation is
+// run again once its lease expires.
+func (s *Service) Start(ctx context.Context, workers int) {
+	s.running.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer s.running.Done()
+			s.work(ctx)
+		}()
+	}
+	go func() {
+		defer s.running.Done()
+		s.sweep(ctx)
+	}()
+}
+
+// Wait blocks until the goroutines started by Start have stopped after their
+// context was canceled, including workers finishing an interrupted job.
+func (s *Service) Wait() {
+	s.running.Wait()
+}
+
+// sweep deletes finished jobs, with their results, once they are older than
+// the retention period.
+func (s *Service) sweep(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	query := fmt.Sprintf(`
+		DELETE FROM "%s".report_jobs
+		WHERE status IN ('succeeded', 'failed') AND finished_at < NOW() - make_interval(secs => $1)`, s.schema)
+	for {
+		tag, err := s.db.Exec(ctx, query, s.retention.Seconds())
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("report jobs: failed to delete expired jobs: %v", err)
+		case err == nil && tag.RowsAffected() > 0:
+			log.Printf("report jobs: deleted %d expired jobs", tag.RowsAffected())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext runs one due job and reports whether there was one.
+func (s *Service) runNext(ctx context.Context) bool {
+	job, err := s.claim(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("report jobs: failed to claim a job: %v", err)
+		}
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.renewLease(jobCtx, job.ID)
+
+	file, err := s.execute(jobCtx, job)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		if err := s.fail(ctx, job.ID, err); err != nil {
+			log.Printf("report jobs: failed to record failure of job %s: %v", job.ID, err)
+		}
+		return true
+	}
+
+	if err := s.complete(ctx, job.ID, file); err != nil {
+		log.Printf("report jobs: failed to store result of job %s: %v", job.ID, err)
+	}
+	return true
+}
+
+// claimedJob is what a worker needs to run a job.
+type claimedJob struct {
+	Job
+	// ReferenceDate is set for jobs whose named periods resolve against a
+	// fixed date, such as scheduled reports.
+	ReferenceDate *time.Time
+}
+
+// claim leases the next due job: a queued job whose run_at has passed, or a
+// running job whose worker stopped renewing its lease. Running jobs that have
+// used up their attempts are failed first.
+func (s *Service) claim(ctx context.Context) (*claimedJob, error) {
+	expired := fmt.Sprintf(`
+		UPDATE "%s".report_jobs
+		SET status = 'failed', finished_at = NOW(), lease_expires_at = NULL,
+			last_error = 'worker stopped before the job finished'
+		WHERE status = 'running' AND lease_expires_at < NOW() AND attempts >= max_attempts`, s.schema)
+	if _, err := s.db.Exec(ctx, expired); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".report_jobs
+		SET status = 'running', attempts = attempts + 1, progress = 0,
+			started_at = COALESCE(started_at, NOW()),
+			lease_expires_at = NOW() + make_interval(secs => $1)
+		WHERE id = (
+			SELECT id FROM "%s".report_jobs
+			WHERE (status = 'queued' AND run_at <= NOW())
+				OR (status = 'running' AND lease_expires_at < NOW())
+			ORDER BY run_at, created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, report_type, params, format, reference_date`, s.schema, s.schema)
+	var job claimedJob
+	err := s.db.QueryRow(ctx, query, leaseDuration.Seconds()).Scan(&job.ID, &job.ReportType, &job.Params, &job.Format,
+		&job.ReferenceDate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// execute computes and renders the report of a claimed job.
+func (s *Service) execute(ctx context.Context, job *claimedJob) (*export.File, error) {
+	now := time.Now()
+	if job.ReferenceDate != nil {
+		now = *job.ReferenceDate
+	}
+	run, err := s.runner.Prepare(job.ReportType, urlValues(job.Params), now)
+	if err != nil {
+		return nil, &permanentError{err: err}
+	}
+	s.setProgress(ctx, job.ID, progressPrepared)
+
+	result, err := run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.setProgress(ctx, job.ID, progressComputed)
+
+	format := export.Format(job.Format)
+	if format == export.FormatJSON && job.Params[money.FormatParam] == string(money.FormatString) {
+		result = money.Strings(result)
+	}
+	file, err := s.renderer.Render(result, format, time.Now().UTC())
+	if err != nil {
+		return nil, &permanentError{err: err}
+	}
+	s.setProgress(ctx, job.ID, progressRendered)
+
+	return &file, nil
+}
+
+// renewLease keeps the job claimed until ctx is done.
+func (s *Service) renewLease(ctx context.Context, id string) {
+	ticker := time.NewTicker(leaseDuration / 3)
+	defer ticker.Stop()
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".report_jobs SET lease_expires_at = NOW() + make_interval(secs => $2)
+		WHERE id = $1 AND status = 'running'`, s.schema)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.db.Exec(ctx, query, id, leaseDuration.Seconds()); err != nil && ctx.Err() == nil {
+				log.Printf("report jobs: failed to renew lease of job %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// setProgress records progress. It is informational, so failures are only
+// logged.
+func (s *Service) setProgress(ctx context.Context, id string, progress int) {
+	query := fmt.Sprintf(`UPDATE "%s".report_jobs SET progress = $2 WHERE id = $1 AND status = 'running'`, s.schema)
+	if _, err := s.db.Exec(ctx, query, id, progress); err != nil && ctx.Err() == nil {
+		log.Printf("report jobs: failed to update progress of job %s: %v", id, err)
+	}
+}
+
+func (s *Service) complete(ctx context.Context, id string, file *export.File) error {
+	query := fmt.Sprintf(`
+		UPDATE "%s".report_jobs
+		SET status = 'succeeded', progress = 100, result = $2, content_type = $3, file_name = $4,
+			last_error = NULL, lease_expires_at = NULL, finished_at = NOW()
+		WHERE id = $1 AND status = 'running'`, s.schema)
+	_, err := s.db.Exec(ctx, query, id, file.Data, file.ContentType, file.Name)
+	return err
+}
+
+// fail queues the job for a retry with exponential backoff, or fails it when
+// the error is permanent or its attempts are used up.
+func (s *Service) fail(ctx context.Context, id string, cause error) error {
+	var permanent *permanentError
+	query := fmt.Sprintf(`
+		UPDATE "%s".report_jobs
+		SET status = CASE WHEN $3::BOOLEAN OR attempts >= max_attempts THEN 'failed' ELSE 'queued' END,
+			finished_at = CASE WHEN $3::BOOLEAN OR attempts >= max_attempts THEN NOW() END,
+			run_at = NOW() + make_interval(secs => $4 * POWER(2, attempts - 1)),
+			last_error = $2, lease_expires_at = NULL
+		WHERE id = $1 AND status = 'running'`, s.schema)
+	_, err := s.db.Exec(ctx, query, id, cause.Error(), errors.As(cause, &permanent), retryBackoff.Seconds())
+	return err
+}
@@ -0,0 +1,99 @@
+package journal
+
+import (
+	"errors"
+	"net/http"
+
+	"financial-reporting-system/internal/periods"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// PostEntry handles POST /api/journal-entries
+func (h *Handler) PostEntry(c *gin.Context) {
+	var req PostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.service.Post(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetEntry handles GET /api/journal-entries/:id
+func (h *Handler) GetEntry(c *gin.Context) {
+	entry, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// ReverseEntry handles POST /api/journal-entries/:id/reverse
+func (h *Handler) ReverseEntry(c *gin.Context) {
+	var req ReverseRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	entry, err := h.service.Reverse(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// RejectModification handles PUT and DELETE /api/journal-entries/:id.
+// Posted entries are immutable and must be corrected with a reversal.
+func (h *Handler) RejectModification(c *gin.Context) {
+	c.Header("Allow", "GET")
+	c.JSON(http.StatusMethodNotAllowed, gin.H{"error": ErrImmutable.Error()})
+}
+
+func writeError(c *gin.Context, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message})
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrAlreadyReversed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrImmutable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, periods.ErrPeriodClosed), errors.Is(err, periods.ErrPeriodSoftClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,754 @@
+package journal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/fx"
+	"financial-reporting-system/internal/periods"
+	"financial-reporting-system/internal/reports"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ValidationError is returned when a journal entry is rejected before or while
+// it is written. Its message is safe to show to API clients.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+var (
+	// ErrEntryNotFound is returned when a journal entry ID does not exist.
+	ErrEntryNotFound = errors.New("journal entry not found")
+	// ErrAlreadyReversed is returned when an entry already has a reversal.
+	ErrAlreadyReversed = errors.New("journal entry has already been reversed")
+	// ErrImmutable is returned for attempts to edit or delete a posted entry.
+	ErrImmutable = errors.New("posted journal entries cannot be edited or deleted; post a reversal instead")
+)
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+var transactionTypes = map[string]bool{
+	"sale":       true,
+	"purchase":   true,
+	"payment":    true,
+	"receipt":    true,
+	"adjustment": true,
+}
+
+type Service struct {
+	db      *pgxpool.Pool
+	periods *periods.Service
+	reports *reports.Service
+	schema  string
+}
+
+func NewService(db *pgxpool.Pool, periods *periods.Service, reports *reports.Service, schema string) *Service {
+	return &Service{
+		db:      db,
+		periods: periods,
+		reports: reports,
+		schema:  schema,
+	}
+}
+
+type LineRequest struct {
+	AccountID   string  `json:"account_id" binding:"required"`
+	CategoryID  string  `json:"category_id"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+	Description string  `json:"description"`
+}
+
+// PostRequest is a journal entry in Currency, which defaults to the base
+// currency of Ledger. Ledger defaults to the default ledger. Line amounts are
+// in Currency and are converted to the ledger's base currency when posted.
+type PostRequest struct {
+	TransactionDate string        `json:"transaction_date" binding:"required"`
+	ReferenceNumber string        `json:"reference_number"`
+	Description     string        `json:"description"`
+	TransactionType string        `json:"transaction_type" binding:"required"`
+	CustomerID      string        `json:"customer_id"`
+	Ledger          string        `json:"ledger"`
+	Currency        string        `json:"currency"`
+	Lines           []LineRequest `json:"lines" binding:"required"`
+}
+
+// Line amounts are in the ledger's base currency. Original amounts are set
+// only when the entry was posted in another currency.
+type Line struct {
+	ID             string   `json:"id"`
+	AccountID      string   `json:"account_id"`
+	CategoryID     string   `json:"category_id,omitempty"`
+	Debit          float64  `json:"debit"`
+	Credit         float64  `json:"credit"`
+	OriginalDebit  *float64 `json:"original_debit,omitempty"`
+	OriginalCredit *float64 `json:"original_credit,omitempty"`
+	Description    string   `json:"description,omitempty"`
+}
+
+type Entry struct {
+	ID              string    `json:"id"`
+	TransactionDate string    `json:"transaction_date"`
+	ReferenceNumber string    `json:"reference_number,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	TransactionType string    `json:"transaction_type"`
+	CustomerID      string    `json:"customer_id,omitempty"`
+	Ledger          string    `json:"ledger"`
+	Currency        string    `json:"currency"`
+	FxRate          float64   `json:"fx_rate"`
+	TotalAmount     float64   `json:"total_amount"`
+	OriginalAmount  *float64  `json:"original_amount,omitempty"`
+	ReversalOf      string    `json:"reversal_of,omitempty"`
+	ReversedBy      string    `json:"reversed_by,omitempty"`
+	Lines           []Line    `json:"lines"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ReverseRequest struct {
+	TransactionDate string `json:"transaction_date"`
+	ReferenceNumber string `json:"reference_number"`
+	Description     string `json:"description"`
+}
+
+// postedLine is a validated line with amounts held in cents. debit and credit
+// are in the ledger's base currency once converted; the original amounts are
+// in the entry's currency.
+type postedLine struct {
+	accountID      uuid.UUID
+	categoryID     *uuid.UUID
+	debit          int64
+	credit         int64
+	originalDebit  int64
+	originalCredit int64
+	description    string
+}
+
+// Post validates a journal entry and writes its transactions header and
+// transaction_items in a single database transaction. total_amount is the sum
+// of the debit lines in the ledger's base currency; any client-supplied total
+// is ignored. Entries in another currency are converted at the rate on the
+// transaction date and keep their original amounts alongside.
+func (s *Service) Post(ctx context.Context, req PostRequest) (*Entry, error) {
+	date, lines, customerID, err := validate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.periods.CheckPosting(ctx, date, req.TransactionType); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.checkReferences(ctx, tx, lines, customerID); err != nil {
+		return nil, err
+	}
+
+	ledger, baseCurrency, err := s.resolveLedger(ctx, tx, req.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	currency := baseCurrency
+	if req.Currency != "" {
+		if currency, err = fx.NormalizeCurrency(req.Currency); err != nil {
+			return nil, invalid("currency: %v", err)
+		}
+	}
+	rate, err := s.rate(ctx, tx, currency, baseCurrency, date)
+	if err != nil {
+		return nil, err
+	}
+	foreign := currency != baseCurrency
+	if foreign {
+		if err := convertLines(lines, rate); err != nil {
+			return nil, err
+		}
+	}
+
+	var total, originalTotal int64
+	for _, line := range lines {
+		total += line.debit
+		originalTotal += line.originalDebit
+	}
+
+	entry := &Entry{
+		TransactionDate: date.Format("2006-01-02"),
+		ReferenceNumber: req.ReferenceNumber,
+		Description:     req.Description,
+		TransactionType: req.TransactionType,
+		Ledger:          ledger,
+		Currency:        currency,
+		FxRate:          rate,
+		TotalAmount:     fromCents(total),
+		Lines:           make([]Line, 0, len(lines)),
+	}
+	if customerID != nil {
+		entry.CustomerID = customerID.String()
+	}
+	var originalAmount *string
+	if foreign {
+		entry.OriginalAmount = centsPtr(originalTotal)
+		amount := formatCents(originalTotal)
+		originalAmount = &amount
+	}
+
+	headerQuery := fmt.Sprintf(`
+		INSERT INTO "%s".transactions (transaction_date, reference_number, description, transaction_type, customer_id, total_amount,
+			ledger_code, currency, fx_rate, original_amount)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`, s.schema)
+	err = tx.QueryRow(ctx, headerQuery, date, req.ReferenceNumber, req.Description, req.TransactionType, customerID, formatCents(total),
+		ledger, currency, rate, originalAmount).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	lineQuery := fmt.Sprintf(`
+		INSERT INTO "%s".transaction_items (transaction_id, account_id, category_id, debit, credit, description,
+			currency, original_debit, original_credit)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+		RETURNING id`, s.schema)
+	for _, line := range lines {
+		posted := Line{
+			AccountID:   line.accountID.String(),
+			Debit:       fromCents(line.debit),
+			Credit:      fromCents(line.credit),
+			Description: line.description,
+		}
+		if line.categoryID != nil {
+			posted.CategoryID = line.categoryID.String()
+		}
+		var originalDebit, originalCredit *string
+		if foreign {
+			posted.OriginalDebit = centsPtr(line.originalDebit)
+			posted.OriginalCredit = centsPtr(line.originalCredit)
+			debit, credit := formatCents(line.originalDebit), formatCents(line.originalCredit)
+			originalDebit, originalCredit = &debit, &credit
+		}
+
+		err := tx.QueryRow(ctx, lineQuery, entry.ID, line.accountID, line.categoryID,
+			formatCents(line.debit), formatCents(line.credit), line.description,
+			currency, originalDebit, originalCredit).Scan(&posted.ID)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		entry.Lines = append(entry.Lines, posted)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, translateError(err)
+	}
+	// Other API processes evict theirs when the database notifies them
+	s.reports.InvalidateDates(date, date)
+
+	return entry, nil
+}
+
+// Get loads a posted journal entry with its lines and reversal links.
+func (s *Service) Get(ctx context.Context, id string) (*Entry, error) {
+	entryID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrEntryNotFound
+	}
+
+	return s.load(ctx, s.db, entryID, false)
+}
+
+// Reverse posts a mirror 'adjustment' transaction that swaps the debits and
+// credits of every line of the original and links back to it through
+// reversal_of. The reversal may be dated in a later period than the original
+// but never before it. Defaults to the original's date when no date is given.
+func (s *Service) Reverse(ctx context.Context, id string, req ReverseRequest) (*Entry, error) {
+	entryID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrEntryNotFound
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the original so concurrent reversals serialize on it
+	original, err := s.load(ctx, tx, entryID, true)
+	if err != nil {
+		return nil, err
+	}
+	if original.ReversedBy != "" {
+		return nil, ErrAlreadyReversed
+	}
+
+	date, err := time.Parse("2006-01-02", original.TransactionDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transaction date: %w", err)
+	}
+	originalDate := date
+	if req.TransactionDate != "" {
+		reversalDate, err := time.Parse("2006-01-02", req.TransactionDate)
+		if err != nil {
+			return nil, invalid("transaction_date must be formatted as YYYY-MM-DD")
+		}
+		if reversalDate.Before(date) {
+			return nil, invalid("a reversal cannot be dated before the original entry (%s)", original.TransactionDate)
+		}
+		date = reversalDate
+	}
+
+	if err := s.periods.CheckPosting(ctx, date, "adjustment"); err != nil {
+		return nil, err
+	}
+
+	reference := req.ReferenceNumber
+	if reference == "" && original.ReferenceNumber != "" {
+		reference = "REV-" + original.ReferenceNumber
+	}
+	description := req.Description
+	if description == "" {
+		description = "Reversal of " + original.ID
+		if original.Description != "" {
+			description = "Reversal of " + original.Description
+		}
+	}
+
+	var customerID *string
+	if original.CustomerID != "" {
+		customerID = &original.CustomerID
+	}
+
+	reversal := &Entry{
+		TransactionDate: date.Format("2006-01-02"),
+		ReferenceNumber: reference,
+		Description:     description,
+		TransactionType: "adjustment",
+		CustomerID:      original.CustomerID,
+		Ledger:          original.Ledger,
+		Currency:        original.Currency,
+		FxRate:          original.FxRate,
+		TotalAmount:     original.TotalAmount,
+		OriginalAmount:  original.OriginalAmount,
+		ReversalOf:      original.ID,
+		Lines:           make([]Line, 0, len(original.Lines)),
+	}
+
+	headerQuery := fmt.Sprintf(`
+		INSERT INTO "%s".transactions (transaction_date, reference_number, description, transaction_type, customer_id, total_amount, reversal_of,
+			ledger_code, currency, fx_rate, original_amount)
+		VALUES ($1, NULLIF($2, ''), $3, 'adjustment', $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`, s.schema)
+	err = tx.QueryRow(ctx, headerQuery, date, reference, description, customerID, original.TotalAmount, entryID,
+		original.Ledger, original.Currency, original.FxRate, original.OriginalAmount).
+		Scan(&reversal.ID, &reversal.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation on reversal_of
+			return nil, ErrAlreadyReversed
+		}
+		return nil, translateError(err)
+	}
+
+	lineQuery := fmt.Sprintf(`
+		INSERT INTO "%s".transaction_items (transaction_id, account_id, category_id, debit, credit, description,
+			currency, original_debit, original_credit)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, NULLIF($6, ''), $7, $8, $9)
+		RETURNING id`, s.schema)
+	for _, line := range original.Lines {
+		mirrored := Line{
+			AccountID:      line.AccountID,
+			CategoryID:     line.CategoryID,
+			Debit:          line.Credit,
+			Credit:         line.Debit,
+			OriginalDebit:  line.OriginalCredit,
+			OriginalCredit: line.OriginalDebit,
+			Description:    line.Description,
+		}
+		err := tx.QueryRow(ctx, lineQuery, reversal.ID, mirrored.AccountID, mirrored.CategoryID,
+			mirrored.Debit, mirrored.Credit, mirrored.Description,
+			original.Currency, mirrored.OriginalDebit, mirrored.OriginalCredit).Scan(&mirrored.ID)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		reversal.Lines = append(reversal.Lines, mirrored)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, translateError(err)
+	}
+	// The original's ledger line now links to the reversal too
+	s.reports.InvalidateDates(originalDate, date)
+
+	return reversal, nil
+}
+
+// querier is satisfied by both the pool and an open transaction.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func (s *Service) load(ctx context.Context, q querier, id uuid.UUID, forUpdate bool) (*Entry, error) {
+	lock := ""
+	if forUpdate {
+		lock = "FOR UPDATE OF t"
+	}
+
+	headerQuery := fmt.Sprintf(`
+		SELECT t.id, t.transaction_date, t.reference_number, t.description, t.transaction_type,
+			t.customer_id, t.ledger_code, t.currency, t.fx_rate, t.total_amount, t.original_amount,
+			t.reversal_of, r.id, t.created_at
+		FROM "%s".transactions t
+		LEFT JOIN "%s".transactions r ON r.reversal_of = t.id
+		WHERE t.id = $1
+		%s`, s.schema, s.schema, lock)
+
+	entry := &Entry{}
+	var date time.Time
+	var reference, description, customerID, reversalOf, reversedBy sql.NullString
+	err := q.QueryRow(ctx, headerQuery, id).Scan(&entry.ID, &date, &reference, &description, &entry.TransactionType,
+		&customerID, &entry.Ledger, &entry.Currency, &entry.FxRate, &entry.TotalAmount, &entry.OriginalAmount,
+		&reversalOf, &reversedBy, &entry.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEntryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load journal entry: %w", err)
+	}
+	entry.TransactionDate = date.Format("2006-01-02")
+	entry.ReferenceNumber = reference.String
+	entry.Description = description.String
+	entry.CustomerID = customerID.String
+	entry.ReversalOf = reversalOf.String
+	entry.ReversedBy = reversedBy.String
+
+	linesQuery := fmt.Sprintf(`
+		SELECT id, account_id, category_id, debit, credit, original_debit, original_credit, description
+		FROM "%s".transaction_items
+		WHERE transaction_id = $1
+		ORDER BY created_at, id`, s.schema)
+	rows, err := q.Query(ctx, linesQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load journal entry lines: %w", err)
+	}
+	defer rows.Close()
+
+	entry.Lines = []Line{}
+	for rows.Next() {
+		var line Line
+		var categoryID, lineDescription sql.NullString
+		if err := rows.Scan(&line.ID, &line.AccountID, &categoryID, &line.Debit, &line.Credit,
+			&line.OriginalDebit, &line.OriginalCredit, &lineDescription); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		line.CategoryID = categoryID.String
+		line.Description = lineDescription.String
+		entry.Lines = append(entry.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return entry, nil
+}
+
+// validate checks everything that can be checked without the database and
+// converts amounts to cents.
+func validate(req PostRequest) (time.Time, []postedLine, *uuid.UUID, error) {
+	date, err := time.Parse("2006-01-02", req.TransactionDate)
+	if err != nil {
+		return time.Time{}, nil, nil, invalid("transaction_date must be formatted as YYYY-MM-DD")
+	}
+
+	if !transactionTypes[req.TransactionType] {
+		return time.Time{}, nil, nil, invalid("transaction_type must be one of sale, purchase, payment, receipt, adjustment")
+	}
+
+	var customerID *uuid.UUID
+	if req.CustomerID != "" {
+		id, err := uuid.Parse(req.CustomerID)
+		if err != nil {
+			return time.Time{}, nil, nil, invalid("customer_id is not a valid UUID")
+		}
+		customerID = &id
+	}
+
+	if len(req.Lines) < 2 {
+		return time.Time{}, nil, nil, invalid("a journal entry needs at least two lines")
+	}
+
+	lines := make([]postedLine, 0, len(req.Lines))
+	var totalDebit, totalCredit int64
+	for i, l := range req.Lines {
+		n := i + 1
+
+		accountID, err := uuid.Parse(l.AccountID)
+		if err != nil {
+			return time.Time{}, nil, nil, invalid("line %d: account_id is not a valid UUID", n)
+		}
+
+		var categoryID *uuid.UUID
+		if l.CategoryID != "" {
+			id, err := uuid.Parse(l.CategoryID)
+			if err != nil {
+				return time.Time{}, nil, nil, invalid("line %d: category_id is not a valid UUID", n)
+			}
+			categoryID = &id
+		}
+
+		debit, ok := toCents(l.Debit)
+		if !ok {
+			return time.Time{}, nil, nil, invalid("line %d: debit must be a non-negative amount with at most two decimals", n)
+		}
+		credit, ok := toCents(l.Credit)
+		if !ok {
+			return time.Time{}, nil, nil, invalid("line %d: credit must be a non-negative amount with at most two decimals", n)
+		}
+
+		// Mirrors the check_debit_credit constraint on transaction_items
+		if (debit > 0) == (credit > 0) {
+			return time.Time{}, nil, nil, invalid("line %d: exactly one of debit or credit must be greater than zero", n)
+		}
+
+		totalDebit += debit
+		totalCredit += credit
+		lines = append(lines, postedLine{
+			accountID:      accountID,
+			categoryID:     categoryID,
+			debit:          debit,
+			credit:         credit,
+			originalDebit:  debit,
+			originalCredit: credit,
+			description:    l.Description,
+		})
+	}
+
+	if totalDebit != totalCredit {
+		return time.Time{}, nil, nil, invalid("total debits (%s) must equal total credits (%s)", formatCents(totalDebit), formatCents(totalCredit))
+	}
+
+	return date, lines, customerID, nil
+}
+
+// resolveLedger returns the code and base currency of a ledger, or of the
+// default ledger when code is empty.
+func (s *Service) resolveLedger(ctx context.Context, tx pgx.Tx, code string) (string, string, error) {
+	query := fmt.Sprintf(`SELECT code, base_currency FROM "%s".ledgers WHERE code = $1`, s.schema)
+	args := []interface{}{code}
+	if code == "" {
+		query = fmt.Sprintf(`SELECT code, base_currency FROM "%s".ledgers WHERE is_default`, s.schema)
+		args = nil
+	}
+
+	var ledger, baseCurrency string
+	err := tx.QueryRow(ctx, query, args...).Scan(&ledger, &baseCurrency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", invalid("unknown ledger: %s", code)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load ledger: %w", err)
+	}
+	return ledger, baseCurrency, nil
+}
+
+// rate returns the exchange rate from currency to the ledger's base currency
+// on date, as fn_fx_rate resolves it.
+func (s *Service) rate(ctx context.Context, tx pgx.Tx, currency, baseCurrency string, date time.Time) (float64, error) {
+	if currency == baseCurrency {
+		return 1, nil
+	}
+
+	var rate float64
+	query := fmt.Sprintf(`SELECT "%s".fn_fx_rate($1, $2, $3)`, s.schema)
+	if err := tx.QueryRow(ctx, query, currency, baseCurrency, date).Scan(&rate); err != nil {
+		if fx.IsMissingRate(err) {
+			return 0, invalid("no exchange rate from %s to %s on or before %s", currency, baseCurrency, date.Format("2006-01-02"))
+		}
+		return 0, fmt.Errorf("failed to load exchange rate: %w", err)
+	}
+	return rate, nil
+}
+
+// convertLines converts every line to the base currency at rate. Rounding each
+// line to the cent can leave the converted entry off by a few cents; the
+// difference is added to the largest line on the short side so the entry
+// still balances.
+func convertLines(lines []postedLine, rate float64) error {
+	var totalDebit, totalCredit int64
+	largestDebit, largestCredit := -1, -1
+	for i := range lines {
+		line := &lines[i]
+		line.debit = int64(math.Round(float64(line.originalDebit) * rate))
+		line.credit = int64(math.Round(float64(line.originalCredit) * rate))
+		if line.debit == 0 && line.credit == 0 {
+			return invalid("line %d: amount converts to zero in the ledger's base currency", i+1)
+		}
+
+		totalDebit += line.debit
+		totalCredit += line.credit
+		if line.debit > 0 && (largestDebit < 0 || line.debit > lines[largestDebit].debit) {
+			largestDebit = i
+		}
+		if line.credit > 0 && (largestCredit < 0 || line.credit > lines[largestCredit].credit) {
+			largestCredit = i
+		}
+	}
+
+	switch {
+	case totalDebit < totalCredit:
+		lines[largestDebit].debit += totalCredit - totalDebit
+	case totalCredit < totalDebit:
+		lines[largestCredit].credit += totalDebit - totalCredit
+	}
+	return nil
+}
+
+// checkReferences verifies that every account, category and customer exists so
+// the client gets a precise error instead of a foreign key violation.
+func (s *Service) checkReferences(ctx context.Context, tx pgx.Tx, lines []postedLine, customerID *uuid.UUID) error {
+	var accountIDs, categoryIDs []uuid.UUID
+	for _, line := range lines {
+		accountIDs = append(accountIDs, line.accountID)
+		if line.categoryID != nil {
+			categoryIDs = append(categoryIDs, *line.categoryID)
+		}
+	}
+
+	missing, err := s.missingIDs(ctx, tx, "accounts", accountIDs)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return invalid("unknown account_id: %s", strings.Join(missing, ", "))
+	}
+
+	missing, err = s.missingIDs(ctx, tx, "categories", categoryIDs)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return invalid("unknown category_id: %s", strings.Join(missing, ", "))
+	}
+
+	if customerID != nil {
+		missing, err = s.missingIDs(ctx, tx, "customers", []uuid.UUID{*customerID})
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return invalid("unknown customer_id: %s", customerID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) missingIDs(ctx context.Context, tx pgx.Tx, table string, ids []uuid.UUID) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT id FROM "%s".%s WHERE id = ANY($1)`, s.schema, table)
+	rows, err := tx.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	found := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	var missing []string
+	seen := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		if !found[id] && !seen[id] {
+			missing = append(missing, id.String())
+			seen[id] = true
+		}
+	}
+	return missing, nil
+}
+
+// translateError turns constraint violations into validation errors so raw
+// pgx messages never reach the client.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return fmt.Errorf("failed to post journal entry: %w", err)
+	}
+
+	switch pgErr.Code {
+	case "FR001": // raised by fn_prevent_posted_changes
+		return ErrImmutable
+	case "FR002": // raised by fn_check_period_open
+		return periods.ErrPeriodClosed
+	case "FR003": // raised by fn_fx_rate
+		return invalid("%s", pgErr.Message)
+	case "23514": // check_violation
+		if pgErr.ConstraintName == "check_debit_credit" {
+			return invalid("each line must have either a debit or a credit amount, not both")
+		}
+		return invalid("journal entry violates constraint %s", pgErr.ConstraintName)
+	case "23503": // foreign_key_violation
+		return invalid("journal entry references an account, category or customer that does not exist")
+	case "22003": // numeric_value_out_of_range
+		return invalid("amount is too large")
+	}
+
+	return fmt.Errorf("failed to post journal entry: %w", err)
+}
+
+// toCents converts a non-negative amount with at most two decimals to cents.
+// The shortest decimal form of the float is what the client sent, so it is
+// used to reject fractions of a cent rather than a rounding tolerance.
+func toCents(amount float64) (int64, bool) {
+	if amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, false
+	}
+	text := strconv.FormatFloat(amount, 'f', -1, 64)
+	if dot := strings.IndexByte(text, '.'); dot >= 0 && len(text)-dot-1 > 2 {
+		return 0, false
+	}
+	return int64(math.Round(amount * 100)), true
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+func centsPtr(cents int64) *float64 {
+	amount := fromCents(cents)
+	return &amount
+}
+
+func formatCents(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
@@ -0,0 +1,341 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a minimal SMTP server on a local port. It offers AUTH PLAIN
+// when it has a username, and answers the command lines in reject with the
+// reply given there instead of accepting them.
+type fakeSMTP struct {
+	listener           net.Listener
+	username, password string
+	reject             map[string]string
+
+	mu       sync.Mutex
+	messages []received
+}
+
+// received is one message accepted by fakeSMTP.
+type received struct {
+	from string
+	to   []string
+	data []byte
+}
+
+func newFakeSMTP(t *testing.T, username, password string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: listener, username: username, password: password, reject: map[string]string{}}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+// mailer returns an SMTPMailer for this server.
+func (s *fakeSMTP) mailer(username, password string) *SMTPMailer {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return NewSMTPMailer(host, port, username, password, "reports@example.com")
+}
+
+func (s *fakeSMTP) received() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]received(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(textproto.NewConn(conn))
+	}
+}
+
+func (s *fakeSMTP) handle(conn *textproto.Conn) {
+	defer conn.Close()
+	conn.PrintfLine("220 fake ESMTP")
+
+	var message received
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		if reply, ok := s.reject[line]; ok {
+			conn.PrintfLine("%s", reply)
+			continue
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if s.username != "" {
+				conn.PrintfLine("250-fake")
+				conn.PrintfLine("250 AUTH PLAIN")
+			} else {
+				conn.PrintfLine("250 fake")
+			}
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			if string(credentials) == "\x00"+s.username+"\x00"+s.password {
+				conn.PrintfLine("235 2.7.0 authenticated")
+			} else {
+				conn.PrintfLine("535 5.7.8 authentication failed")
+			}
+		case "*":
+			conn.PrintfLine("501 5.7.0 authentication canceled")
+		case "MAIL":
+			message = received{from: strings.TrimPrefix(arg, "FROM:")}
+			conn.PrintfLine("250 2.1.0 ok")
+		case "RCPT":
+			message.to = append(message.to, strings.TrimPrefix(arg, "TO:"))
+			conn.PrintfLine("250 2.1.5 ok")
+		case "DATA":
+			conn.PrintfLine("354 go ahead")
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			conn.PrintfLine("250 2.0.0 queued")
+		case "RSET", "NOOP":
+			conn.PrintfLine("250 ok")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("502 5.5.2 command not implemented")
+		}
+	}
+}
+
+func TestSendMIME(t *testing.T) {
+	server := newFakeSMTP(t, "", "")
+	pdf := bytes.Repeat([]byte("%PDF-1.4\n\x00\xff binary "), 40)
+	msg := Message{
+		To:      []string{"cfo@example.com", "controller@example.com"},
+		Subject: "Résumé Q1 – profit and loss",
+		Body:    "Attached is the report.\r\n",
+		Attachments: []Attachment{
+			{Name: "profit-and-loss.pdf", ContentType: "application/pdf", Data: pdf},
+			{Name: "summary 2024.csv", ContentType: "text/csv", Data: []byte("Account,Amount\r\nRevenue,1000.00\r\n")},
+		},
+	}
+	if err := server.mailer("", "").Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.from != "<reports@example.com>" {
+		t.Errorf("envelope sender = %s", got.from)
+	}
+	if strings.Join(got.to, ",") != "<cfo@example.com>,<controller@example.com>" {
+		t.Errorf("envelope recipients = %v", got.to)
+	}
+	for _, line := range strings.Split(string(got.data), "\n") {
+		if len(line) > 78 {
+			t.Errorf("line longer than 78 characters: %q", line)
+		}
+	}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	header := parsed.Header
+	if header.Get("From") != "reports@example.com" || header.Get("To") != "cfo@example.com, controller@example.com" {
+		t.Errorf("From/To = %q/%q", header.Get("From"), header.Get("To"))
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	if _, err := header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if header.Get("MIME-Version") != "1.0" {
+		t.Errorf("MIME-Version = %q", header.Get("MIME-Version"))
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q (%v)", header.Get("Content-Type"), err)
+	}
+
+	type part struct {
+		contentType, disposition, filename string
+		data                               []byte
+	}
+	var parts []part
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		p, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRawPart: %v", err)
+		}
+		if encoding := p.Header.Get("Content-Transfer-Encoding"); encoding != "base64" {
+			t.Errorf("Content-Transfer-Encoding = %q", encoding)
+		}
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		if err != nil {
+			t.Fatalf("decoding part: %v", err)
+		}
+		disposition, _, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+		parts = append(parts, part{p.Header.Get("Content-Type"), disposition, p.FileName(), data})
+	}
+
+	want := []part{
+		{`text/plain; charset="utf-8"`, "", "", []byte(msg.Body)},
+		{"application/pdf", "attachment", "profit-and-loss.pdf", pdf},
+		{"text/csv", "attachment", "summary 2024.csv", msg.Attachments[1].Data},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("message has %d parts, want %d", len(parts), len(want))
+	}
+	for i := range want {
+		if parts[i].contentType != want[i].contentType || parts[i].disposition != want[i].disposition ||
+			parts[i].filename != want[i].filename || !bytes.Equal(parts[i].data, want[i].data) {
+			t.Errorf("part %d = %s %s %q (%d bytes), want %s %s %q (%d bytes)", i,
+				parts[i].contentType, parts[i].disposition, parts[i].filename, len(parts[i].data),
+				want[i].contentType, want[i].disposition, want[i].filename, len(want[i].data))
+		}
+	}
+}
+
+func TestSendAuth(t *testing.T) {
+	server := newFakeSMTP(t, "mailer", "s3cret")
+	msg := Message{To: []string{"cfo@example.com"}, Subject: "Report"}
+
+	if err := server.mailer("mailer", "s3cret").Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send with valid credentials: %v", err)
+	}
+
+	err := server.mailer("mailer", "wrong").Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "SMTP authentication failed") {
+		t.Fatalf("Send with invalid credentials = %v, want an authentication error", err)
+	}
+	if !IsPermanent(err) {
+		t.Errorf("IsPermanent(%v) = false", err)
+	}
+	if n := len(server.received()); n != 1 {
+		t.Errorf("server received %d messages, want 1", n)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		reject    map[string]string
+		to        []string
+		want      string
+		code      int
+		permanent bool
+	}{
+		{
+			name:      "rejected sender",
+			reject:    map[string]string{"MAIL FROM:<reports@example.com>": "550 5.7.1 sender refused"},
+			want:      "SMTP server rejected sender",
+			code:      550,
+			permanent: true,
+		},
+		{
+			name:      "unknown recipient",
+			reject:    map[string]string{"RCPT TO:<nobody@example.com>": "550 5.1.1 no such user"},
+			to:        []string{"cfo@example.com", "nobody@example.com"},
+			want:      "SMTP server rejected recipient nobody@example.com",
+			code:      550,
+			permanent: true,
+		},
+		{
+			name:   "mailbox busy",
+			reject: map[string]string{"RCPT TO:<cfo@example.com>": "450 4.2.1 mailbox busy"},
+			want:   "SMTP server rejected recipient cfo@example.com",
+			code:   450,
+		},
+		{
+			name:   "out of storage",
+			reject: map[string]string{"DATA": "452 4.3.1 insufficient storage"},
+			want:   "SMTP DATA failed",
+			code:   452,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTP(t, "", "")
+			server.reject = tt.reject
+			to := tt.to
+			if to == nil {
+				to = []string{"cfo@example.com"}
+			}
+
+			err := server.mailer("", "").Send(context.Background(), Message{To: to, Subject: "Report"})
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("Send = %v, want an error starting with %q", err, tt.want)
+			}
+			var reply *textproto.Error
+			if !errors.As(err, &reply) || reply.Code != tt.code {
+				t.Errorf("Send = %v, want SMTP reply %d", err, tt.code)
+			}
+			if IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, !tt.permanent, tt.permanent)
+			}
+			if n := len(server.received()); n != 0 {
+				t.Errorf("server received %d messages, want none", n)
+			}
+		})
+	}
+}
+
+func TestSendWithoutServer(t *testing.T) {
+	msg := Message{To: []string{"cfo@example.com"}, Subject: "Report"}
+
+	err := NewSMTPMailer("", "25", "", "", "reports@example.com").Send(context.Background(), msg)
+	if !errors.Is(err, ErrNotConfigured) || !IsPermanent(err) {
+		t.Errorf("Send without a host = %v, want the permanent ErrNotConfigured", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = NewSMTPMailer(host, port, "", "", "reports@example.com").Send(ctx, msg)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to connect to SMTP server") {
+		t.Fatalf("Send to a closed port = %v, want a connection error", err)
+	}
+	if IsPermanent(err) {
+		t.Errorf("IsPermanent(%v) = true, want a retryable error", err)
+	}
+}
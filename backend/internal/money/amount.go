@@ -0,0 +1,142 @@
+// Package money carries DECIMAL(15, 2) amounts from the database to the API
+// without passing through floating point.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Amount is an exact amount with two decimal places, held in cents. Amounts
+// add and subtract exactly with the usual operators.
+type Amount int64
+
+// ErrInvalidAmount is returned when text is not a decimal with at most two places.
+var ErrInvalidAmount = errors.New("amount must be a decimal number with at most two decimal places")
+
+func FromCents(cents int64) Amount {
+	return Amount(cents)
+}
+
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Float64 is for ratios such as percentages; never sum the results.
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// String formats the amount with exactly two decimals, such as "-1234.50".
+func (a Amount) String() string {
+	cents := int64(a)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+	}
+	whole, frac := cents/100, cents%100
+	if whole < 0 {
+		whole = -whole
+	}
+	if frac < 0 {
+		frac = -frac
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, whole, frac)
+}
+
+// Parse reads a decimal such as "1234.5" or "-0.05". More than two decimal
+// places are rejected rather than rounded.
+func Parse(text string) (Amount, error) {
+	text = strings.TrimSpace(text)
+	negative := strings.HasPrefix(text, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	whole, frac, hasDot := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || len(frac) > 2 || hasDot && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	for _, part := range []string{whole, frac} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, ErrInvalidAmount
+			}
+		}
+	}
+
+	frac += strings.Repeat("0", 2-len(frac))
+	cents, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	if negative {
+		cents = -cents
+	}
+	return Amount(cents), nil
+}
+
+// MarshalJSON writes the amount as a fixed-scale JSON number. Use Strings to
+// write amounts as JSON strings instead.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts both the number and the string form.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if len(text) < 2 || !strings.HasSuffix(text, `"`) {
+			return fmt.Errorf("money: %q: %w", text, ErrInvalidAmount)
+		}
+		text = text[1 : len(text)-1]
+	}
+	amount, err := Parse(text)
+	if err != nil {
+		return fmt.Errorf("money: %q: %w", text, err)
+	}
+	*a = amount
+	return nil
+}
+
+// ScanNumeric lets pgx scan NUMERIC and DECIMAL columns into an Amount. Values
+// with more than two decimal places are rounded half away from zero. Scan
+// nullable columns into *Amount.
+func (a *Amount) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		return errors.New("money: cannot scan NULL into Amount")
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return errors.New("money: cannot scan NaN or infinity into Amount")
+	}
+
+	cents := new(big.Int).Set(n.Int)
+	ten := big.NewInt(10)
+	if shift := int64(n.Exp) + 2; shift >= 0 {
+		cents.Mul(cents, new(big.Int).Exp(ten, big.NewInt(shift), nil))
+	} else {
+		divisor := new(big.Int).Exp(ten, big.NewInt(-shift), nil)
+		remainder := new(big.Int)
+		cents.QuoRem(cents, divisor, remainder)
+		if remainder.Abs(remainder).Mul(remainder, big.NewInt(2)).Cmp(divisor) >= 0 {
+			cents.Add(cents, big.NewInt(int64(n.Int.Sign())))
+		}
+	}
+
+	if !cents.IsInt64() {
+		return errors.New("money: value out of range for Amount")
+	}
+	*a = Amount(cents.Int64())
+	return nil
+}
+
+// NumericValue lets an Amount be passed as a NUMERIC query argument.
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -2, Valid: true}, nil
+}
@@ -0,0 +1,241 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Format selects how amounts are written to JSON.
+type Format string
+
+const (
+	// FormatNumber writes amounts as fixed-scale numbers such as 1234.50.
+	FormatNumber Format = "number"
+	// FormatString writes amounts as strings such as "1234.50", for clients
+	// that would otherwise parse them into floating point.
+	FormatString Format = "string"
+)
+
+// FormatParam is the query parameter that selects the amount format.
+const FormatParam = "amounts"
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case FormatNumber, FormatString:
+		return Format(value), nil
+	}
+	return "", fmt.Errorf("%s must be %s or %s", FormatParam, FormatNumber, FormatString)
+}
+
+// ValidateFormat rejects requests with an unknown amounts parameter before
+// any report is computed.
+func ValidateFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := ParseFormat(c.DefaultQuery(FormatParam, string(FormatNumber))); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// JSON writes v in the amount format requested by the amounts parameter,
+// defaulting to numbers.
+func JSON(c *gin.Context, status int, v interface{}) {
+	if format, _ := ParseFormat(c.Query(FormatParam)); format == FormatString {
+		v = Strings(v)
+	}
+	c.JSON(status, v)
+}
+
+// Strings returns a copy of v that encodes to the same JSON, except that every
+// Amount is written as a string. v itself is never modified, so cached reports
+// can be shared between requests that ask for different formats.
+func Strings(v interface{}) interface{} {
+	return stringify(reflect.ValueOf(v))
+}
+
+var (
+	amountType    = reflect.TypeOf(Amount(0))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func stringify(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == amountType {
+		return Amount(v.Int()).String()
+	}
+	if !holdsAmounts(v.Type()) {
+		return v.Interface()
+	}
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		return stringify(v.Elem())
+	}
+	if v.Type().Implements(marshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = stringify(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = stringify(iter.Value())
+		}
+		return entries
+	case reflect.Struct:
+		return stringifyStruct(v)
+	}
+	return v.Interface()
+}
+
+// object is a JSON object that keeps struct field order.
+type object []field
+
+type field struct {
+	name  string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// stringifyStruct follows the encoding/json field rules this codebase relies
+// on: json tag names, "-" and omitempty, and untagged embedded structs.
+func stringifyStruct(v reflect.Value) object {
+	var fields object
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+
+		if sf.Anonymous && name == "" {
+			embedded := fv
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, stringifyStruct(embedded)...)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		fields = append(fields, field{name: name, value: stringify(fv)})
+	}
+	return fields
+}
+
+// isEmptyValue matches encoding/json's omitempty rule.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+var amountTypes sync.Map // reflect.Type -> bool
+
+// holdsAmounts reports whether values of t can contain an Amount, so values
+// that cannot are passed to encoding/json untouched.
+func holdsAmounts(t reflect.Type) bool {
+	if cached, ok := amountTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	result := searchAmounts(t, make(map[reflect.Type]bool))
+	amountTypes.Store(t, result)
+	return result
+}
+
+func searchAmounts(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == amountType || t.Kind() == reflect.Interface {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return searchAmounts(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if searchAmounts(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package periods
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListPeriods handles GET /api/periods
+func (h *Handler) ListPeriods(c *gin.Context) {
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil || year < 1900 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year must be a four-digit year"})
+		return
+	}
+
+	periods, err := h.service.List(c.Request.Context(), year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": periods})
+}
+
+// GetPeriod handles GET /api/periods/:period
+func (h *Handler) GetPeriod(c *gin.Context) {
+	detail, err := h.service.Get(c.Request.Context(), c.Param("period"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// SoftClosePeriod handles POST /api/periods/:period/soft-close
+func (h *Handler) SoftClosePeriod(c *gin.Context) {
+	detail, err := h.service.SoftClose(c.Request.Context(), c.Param("period"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// ClosePeriod handles POST /api/periods/:period/close
+func (h *Handler) ClosePeriod(c *gin.Context) {
+	detail, err := h.service.Close(c.Request.Context(), c.Param("period"), c.GetString("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// ReopenPeriod handles POST /api/periods/:period/reopen
+func (h *Handler) ReopenPeriod(c *gin.Context) {
+	detail, err := h.service.Reopen(c.Request.Context(), c.Param("period"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrPeriodClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
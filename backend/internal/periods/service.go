@@ -0,0 +1,334 @@
+package periods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"financial-reporting-system/internal/money"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	StatusOpen       = "open"
+	StatusSoftClosed = "soft_closed"
+	StatusClosed     = "closed"
+)
+
+var (
+	// ErrInvalidPeriod is returned for period keys that are not YYYY-MM.
+	ErrInvalidPeriod = errors.New("period must be formatted as YYYY-MM")
+	// ErrPeriodClosed is returned when a closed period is modified or posted into.
+	ErrPeriodClosed = errors.New("accounting period is closed")
+	// ErrPeriodSoftClosed is returned when a non-adjustment is posted into a soft-closed period.
+	ErrPeriodSoftClosed = errors.New("accounting period is soft-closed; only adjustments may be posted")
+)
+
+type Service struct {
+	db     *pgxpool.Pool
+	schema string
+}
+
+func NewService(db *pgxpool.Pool, schema string) *Service {
+	return &Service{
+		db:     db,
+		schema: schema,
+	}
+}
+
+type Period struct {
+	Period           string        `json:"period"`
+	PeriodStart      string        `json:"period_start"`
+	PeriodEnd        string        `json:"period_end"`
+	Status           string        `json:"status"`
+	NetIncome        *money.Amount `json:"net_income,omitempty"`
+	RetainedEarnings *money.Amount `json:"retained_earnings,omitempty"`
+	ClosedAt         *time.Time    `json:"closed_at,omitempty"`
+	ClosedBy         string        `json:"closed_by,omitempty"`
+}
+
+type Balance struct {
+	AccountID      string       `json:"account_id"`
+	AccountCode    string       `json:"account_code"`
+	AccountName    string       `json:"account_name"`
+	AccountType    string       `json:"account_type"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+	PeriodDebit    money.Amount `json:"period_debit"`
+	PeriodCredit   money.Amount `json:"period_credit"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+}
+
+type PeriodDetail struct {
+	Period
+	Balances []Balance `json:"balances"`
+}
+
+// ParsePeriod converts a YYYY-MM key into the first and last day of the month.
+func ParsePeriod(key string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", key)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+	return start, start.AddDate(0, 1, -1), nil
+}
+
+func newPeriod(start time.Time) Period {
+	return Period{
+		Period:      start.Format("2006-01"),
+		PeriodStart: start.Format("2006-01-02"),
+		PeriodEnd:   start.AddDate(0, 1, -1).Format("2006-01-02"),
+		Status:      StatusOpen,
+	}
+}
+
+// List returns all twelve months of a calendar year. Months without a row in
+// accounting_periods are reported as open.
+func (s *Service) List(ctx context.Context, year int) ([]Period, error) {
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	query := fmt.Sprintf(`
+		SELECT period_start, status, net_income, retained_earnings, closed_at, COALESCE(closed_by, '')
+		FROM "%s".accounting_periods
+		WHERE period_start >= $1 AND period_start < $2`, s.schema)
+	rows, err := s.db.Query(ctx, query, first, first.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load periods: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]Period)
+	for rows.Next() {
+		var start time.Time
+		var status, closedBy string
+		var netIncome, retainedEarnings *money.Amount
+		var closedAt *time.Time
+		if err := rows.Scan(&start, &status, &netIncome, &retainedEarnings, &closedAt, &closedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		period := newPeriod(start)
+		period.Status = status
+		period.NetIncome = netIncome
+		period.RetainedEarnings = retainedEarnings
+		period.ClosedAt = closedAt
+		period.ClosedBy = closedBy
+		stored[period.Period] = period
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	periods := make([]Period, 0, 12)
+	for month := 0; month < 12; month++ {
+		period := newPeriod(first.AddDate(0, month, 0))
+		if row, ok := stored[period.Period]; ok {
+			period = row
+		}
+		periods = append(periods, period)
+	}
+
+	return periods, nil
+}
+
+// Get returns one period and, once it is closed, its trial balance snapshot.
+func (s *Service) Get(ctx context.Context, key string) (*PeriodDetail, error) {
+	start, _, err := ParsePeriod(key)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &PeriodDetail{Period: newPeriod(start), Balances: []Balance{}}
+
+	query := fmt.Sprintf(`
+		SELECT id, status, net_income, retained_earnings, closed_at, COALESCE(closed_by, '')
+		FROM "%s".accounting_periods
+		WHERE period_start = $1`, s.schema)
+	var id string
+	err = s.db.QueryRow(ctx, query, start).Scan(&id, &detail.Status, &detail.NetIncome,
+		&detail.RetainedEarnings, &detail.ClosedAt, &detail.ClosedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return detail, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load period: %w", err)
+	}
+
+	balancesQuery := fmt.Sprintf(`
+		SELECT a.id, a.code, a.name, a.type, pb.opening_balance, pb.period_debit, pb.period_credit, pb.closing_balance
+		FROM "%s".period_balances pb
+		INNER JOIN "%s".accounts a ON a.id = pb.account_id
+		WHERE pb.period_id = $1
+		ORDER BY a.code`, s.schema, s.schema)
+	rows, err := s.db.Query(ctx, balancesQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load period balances: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b Balance
+		err := rows.Scan(&b.AccountID, &b.AccountCode, &b.AccountName, &b.AccountType,
+			&b.OpeningBalance, &b.PeriodDebit, &b.PeriodCredit, &b.ClosingBalance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		detail.Balances = append(detail.Balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return detail, nil
+}
+
+// SoftClose restricts a period to adjustment postings.
+func (s *Service) SoftClose(ctx context.Context, key string) (*PeriodDetail, error) {
+	return s.setStatus(ctx, key, StatusSoftClosed)
+}
+
+// Reopen returns a soft-closed period to open. Closed periods stay closed.
+func (s *Service) Reopen(ctx context.Context, key string) (*PeriodDetail, error) {
+	return s.setStatus(ctx, key, StatusOpen)
+}
+
+func (s *Service) setStatus(ctx context.Context, key, status string) (*PeriodDetail, error) {
+	start, end, err := ParsePeriod(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, current, err := s.lockPeriod(ctx, tx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if current == StatusClosed {
+		return nil, ErrPeriodClosed
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".accounting_periods
+		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE period_start = $1`, s.schema)
+	if _, err := tx.Exec(ctx, query, start, status); err != nil {
+		return nil, fmt.Errorf("failed to update period: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit period: %w", err)
+	}
+
+	return s.Get(ctx, key)
+}
+
+// Close locks a period, snapshots its trial balance into period_balances and
+// records net income for the month and the retained earnings carried forward
+// into equity (all revenue less expenses up to the period end).
+func (s *Service) Close(ctx context.Context, key, closedBy string) (*PeriodDetail, error) {
+	start, end, err := ParsePeriod(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Hold off new postings until the snapshot is committed
+	lockQuery := fmt.Sprintf(`LOCK TABLE "%s".transactions IN SHARE ROW EXCLUSIVE MODE`, s.schema)
+	if _, err := tx.Exec(ctx, lockQuery); err != nil {
+		return nil, fmt.Errorf("failed to lock transactions: %w", err)
+	}
+
+	id, current, err := s.lockPeriod(ctx, tx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if current == StatusClosed {
+		return nil, ErrPeriodClosed
+	}
+
+	snapshotQuery := fmt.Sprintf(`
+		INSERT INTO "%s".period_balances (period_id, account_id, opening_balance, period_debit, period_credit, closing_balance)
+		SELECT $1, tb.account_id, tb.opening_balance, tb.period_debit, tb.period_credit, tb.closing_balance
+		FROM "%s".sp_trial_balance($2, $3) tb`, s.schema, s.schema)
+	if _, err := tx.Exec(ctx, snapshotQuery, id, start, end); err != nil {
+		return nil, fmt.Errorf("failed to snapshot trial balance: %w", err)
+	}
+
+	// Balances are debit minus credit, so earnings are the negated sums
+	closeQuery := fmt.Sprintf(`
+		UPDATE "%s".accounting_periods p
+		SET status = 'closed',
+			net_income = e.net_income,
+			retained_earnings = e.retained_earnings,
+			closed_at = CURRENT_TIMESTAMP,
+			closed_by = $2,
+			updated_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT
+				-COALESCE(SUM(pb.period_debit - pb.period_credit), 0) AS net_income,
+				-COALESCE(SUM(pb.closing_balance), 0) AS retained_earnings
+			FROM "%s".period_balances pb
+			INNER JOIN "%s".accounts a ON a.id = pb.account_id
+			WHERE pb.period_id = $1
+				AND a.type IN ('revenue', 'expense')
+		) e
+		WHERE p.id = $1`, s.schema, s.schema, s.schema)
+	if _, err := tx.Exec(ctx, closeQuery, id, closedBy); err != nil {
+		return nil, fmt.Errorf("failed to close period: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit period: %w", err)
+	}
+
+	return s.Get(ctx, key)
+}
+
+// lockPeriod makes sure the period row exists and locks it for the rest of tx.
+func (s *Service) lockPeriod(ctx context.Context, tx pgx.Tx, start, end time.Time) (string, string, error) {
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO "%s".accounting_periods (period_start, period_end)
+		VALUES ($1, $2)
+		ON CONFLICT (period_start) DO NOTHING`, s.schema)
+	if _, err := tx.Exec(ctx, insertQuery, start, end); err != nil {
+		return "", "", fmt.Errorf("failed to create period: %w", err)
+	}
+
+	var id, status string
+	selectQuery := fmt.Sprintf(`SELECT id, status FROM "%s".accounting_periods WHERE period_start = $1 FOR UPDATE`, s.schema)
+	if err := tx.QueryRow(ctx, selectQuery, start).Scan(&id, &status); err != nil {
+		return "", "", fmt.Errorf("failed to lock period: %w", err)
+	}
+
+	return id, status, nil
+}
+
+// CheckPosting reports whether a transaction of the given type may be posted
+// on date. Closed periods lock every date up to their end; soft-closed periods
+// only accept adjustments.
+func (s *Service) CheckPosting(ctx context.Context, date time.Time, transactionType string) error {
+	var status string
+	query := fmt.Sprintf(`SELECT "%s".fn_period_status($1)`, s.schema)
+	if err := s.db.QueryRow(ctx, query, date).Scan(&status); err != nil {
+		return fmt.Errorf("failed to check accounting period: %w", err)
+	}
+
+	switch {
+	case status == StatusClosed:
+		return ErrPeriodClosed
+	case status == StatusSoftClosed && transactionType != "adjustment":
+		return ErrPeriodSoftClosed
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPeriod is returned for period expressions the calendar cannot resolve.
+var ErrInvalidPeriod = errors.New("invalid period")
+
+var (
+	// Matched against the lowercased expression
+	fiscalYearPattern    = regexp.MustCompile(`^fy(\d{4})$`)
+	fiscalQuarterPattern = regexp.MustCompile(`^(\d{4})-q([1-4])$`)
+	trailingPattern      = regexp.MustCompile(`^last_(\d{1,4})_(days|months)$`)
+)
+
+// DateRange is an inclusive range of calendar dates.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// FiscalCalendar resolves named report periods such as FY2025, 2025-Q3, YTD,
+// MTD, last_month, last_30_days and last_12_months. A fiscal year is named
+// after the calendar year in which it ends, so with a July start FY2025 runs
+// from 2024-07-01 to 2025-06-30. Trailing periods end today.
+type FiscalCalendar struct {
+	StartMonth time.Month
+}
+
+func NewFiscalCalendar(startMonth int) FiscalCalendar {
+	if startMonth < 1 || startMonth > 12 {
+		startMonth = 1
+	}
+	return FiscalCalendar{StartMonth: time.Month(startMonth)}
+}
+
+// Resolve turns a period expression into concrete dates relative to now.
+// Expressions are case-insensitive.
+func (fc FiscalCalendar) Resolve(expr string, now time.Time) (DateRange, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	name := strings.ToLower(expr)
+
+	if m := fiscalYearPattern.FindStringSubmatch(name); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		start := fc.fiscalYearStart(year)
+		return DateRange{Start: start, End: start.AddDate(1, 0, -1)}, nil
+	}
+
+	if m := fiscalQuarterPattern.FindStringSubmatch(name); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		quarter, _ := strconv.Atoi(m[2])
+		start := fc.fiscalYearStart(year).AddDate(0, 3*(quarter-1), 0)
+		return DateRange{Start: start, End: start.AddDate(0, 3, -1)}, nil
+	}
+
+	if m := trailingPattern.FindStringSubmatch(name); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 {
+			return DateRange{}, fmt.Errorf("%w %q: the number of %s must be positive", ErrInvalidPeriod, expr, m[2])
+		}
+		if m[2] == "days" {
+			return DateRange{Start: today.AddDate(0, 0, 1-n), End: today}, nil
+		}
+		// Clamped, so that on March 31 last_1_months starts on March 1
+		return DateRange{Start: shiftMonths(today, -n).AddDate(0, 0, 1), End: today}, nil
+	}
+
+	switch name {
+	case "ytd":
+		return DateRange{Start: fc.fiscalYearStart(fc.FiscalYear(today)), End: today}, nil
+	case "qtd":
+		start := fc.fiscalYearStart(fc.FiscalYear(today))
+		for !start.AddDate(0, 3, 0).After(today) {
+			start = start.AddDate(0, 3, 0)
+		}
+		return DateRange{Start: start, End: today}, nil
+	case "mtd":
+		return DateRange{Start: today.AddDate(0, 0, 1-today.Day()), End: today}, nil
+	case "last_month":
+		monthStart := today.AddDate(0, 0, 1-today.Day())
+		return DateRange{Start: monthStart.AddDate(0, -1, 0), End: monthStart.AddDate(0, 0, -1)}, nil
+	}
+
+	return DateRange{}, fmt.Errorf("%w %q: use FY<year>, <year>-Q<1-4>, YTD, QTD, MTD, last_month, last_<n>_days or last_<n>_months", ErrInvalidPeriod, expr)
+}
+
+// FiscalYear returns the name of the fiscal year containing date.
+func (fc FiscalCalendar) FiscalYear(date time.Time) int {
+	if fc.StartMonth == time.January || date.Month() < fc.StartMonth {
+		return date.Year()
+	}
+	return date.Year() + 1
+}
+
+// fiscalYearStart returns the first day of the named fiscal year.
+func (fc FiscalCalendar) fiscalYearStart(year int) time.Time {
+	if fc.StartMonth == time.January {
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(year-1, fc.StartMonth, 1, 0, 0, 0, 0, time.UTC)
+}
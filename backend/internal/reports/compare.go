@@ -0,0 +1,252 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+// ErrInvalidCompareMode is returned for unsupported compare parameters.
+var ErrInvalidCompareMode = errors.New("compare must be previous_period or previous_year")
+
+// CompareMode selects the range a report is compared against.
+type CompareMode string
+
+const (
+	ComparePreviousPeriod CompareMode = "previous_period"
+	ComparePreviousYear   CompareMode = "previous_year"
+)
+
+func ParseCompareMode(value string) (CompareMode, error) {
+	switch mode := CompareMode(value); mode {
+	case ComparePreviousPeriod, ComparePreviousYear:
+		return mode, nil
+	}
+	return "", ErrInvalidCompareMode
+}
+
+// PreviousRange returns the range the given one is compared against. Whole
+// calendar months shift by their number of months, so March compares with
+// February; any other range compares with the same number of days immediately
+// before it. previous_year shifts both ends back one year, keeping month ends
+// (2024-02-29 becomes 2023-02-28).
+func (m CompareMode) PreviousRange(startDate, endDate time.Time) DateRange {
+	if m == ComparePreviousYear {
+		return DateRange{Start: shiftMonths(startDate, -12), End: shiftMonths(endDate, -12)}
+	}
+
+	if months, ok := wholeMonths(startDate, endDate); ok {
+		start := startDate.AddDate(0, -months, 0)
+		return DateRange{Start: start, End: startDate.AddDate(0, 0, -1)}
+	}
+
+	days := int(endDate.Sub(startDate).Hours()/24) + 1
+	end := startDate.AddDate(0, 0, -1)
+	return DateRange{Start: end.AddDate(0, 0, 1-days), End: end}
+}
+
+// wholeMonths reports how many calendar months the range spans when it starts
+// on the first of a month and ends on the last day of a month.
+func wholeMonths(startDate, endDate time.Time) (int, bool) {
+	if startDate.Day() != 1 || endDate.AddDate(0, 0, 1).Day() != 1 {
+		return 0, false
+	}
+	return (endDate.Year()-startDate.Year())*12 + int(endDate.Month()-startDate.Month()) + 1, true
+}
+
+// shiftMonths moves date by months, clamping to the last day of the target month.
+func shiftMonths(date time.Time, months int) time.Time {
+	first := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location()).AddDate(0, months, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// ComparedRow pairs a report row with the matching row of the comparison
+// range. A row missing on one side is filled with zero amounts.
+type ComparedRow[T any] struct {
+	Current   T            `json:"current"`
+	Previous  T            `json:"previous"`
+	Change    money.Amount `json:"change"`
+	ChangePct *float64     `json:"change_pct"`
+}
+
+// ComparisonResponse wraps a report run over two ranges.
+type ComparisonResponse[T any] struct {
+	Compare           CompareMode      `json:"compare"`
+	StartDate         string           `json:"start_date"`
+	EndDate           string           `json:"end_date"`
+	PreviousStartDate string           `json:"previous_start_date"`
+	PreviousEndDate   string           `json:"previous_end_date"`
+	Data              []ComparedRow[T] `json:"data"`
+	ReportMeta
+}
+
+// comparedMetric describes how rows of one report are matched and compared.
+type comparedMetric[T any] struct {
+	key    func(T) string
+	amount func(T) money.Amount
+	// blank returns a copy of the row with only its identifying fields set.
+	blank func(T) T
+	// currentOnly leaves out rows only present in the previous range, for
+	// ranked lists whose rows are chosen by the current range.
+	currentOnly bool
+}
+
+// compareRows aligns current and previous rows by key. Current rows keep their
+// order, followed by rows only present in the previous range unless
+// metric.currentOnly is set.
+func compareRows[T any](current, previous []T, metric comparedMetric[T]) []ComparedRow[T] {
+	previousByKey := make(map[string]T, len(previous))
+	for _, row := range previous {
+		previousByKey[metric.key(row)] = row
+	}
+
+	compared := make([]ComparedRow[T], 0, len(current)+len(previous))
+	seen := make(map[string]bool, len(current))
+	for _, row := range current {
+		key := metric.key(row)
+		seen[key] = true
+		prev, ok := previousByKey[key]
+		if !ok {
+			prev = metric.blank(row)
+		}
+		compared = append(compared, newComparedRow(row, prev, metric))
+	}
+
+	for _, row := range previous {
+		if !metric.currentOnly && !seen[metric.key(row)] {
+			compared = append(compared, newComparedRow(metric.blank(row), row, metric))
+		}
+	}
+
+	return compared
+}
+
+func newComparedRow[T any](current, previous T, metric comparedMetric[T]) ComparedRow[T] {
+	cur, prev := metric.amount(current), metric.amount(previous)
+	row := ComparedRow[T]{
+		Current:  current,
+		Previous: previous,
+		Change:   cur - prev,
+	}
+	// A change against nothing has no meaningful percentage
+	if prev != 0 {
+		pct := math.Round((cur-prev).Float64()/math.Abs(prev.Float64())*10000) / 100
+		row.ChangePct = &pct
+	}
+	return row
+}
+
+// runComparison runs a report for the current range with run and for the
+// comparison range with runPrevious concurrently, and aligns the results.
+func runComparison[T any](
+	ctx context.Context,
+	startDate, endDate time.Time,
+	mode CompareMode,
+	run, runPrevious func(ctx context.Context, startDate, endDate time.Time) ([]T, ReportMeta, error),
+	metric comparedMetric[T],
+) (*ComparisonResponse[T], error) {
+	start := time.Now()
+	previousRange := mode.PreviousRange(startDate, endDate)
+
+	type result struct {
+		rows []T
+		meta ReportMeta
+		err  error
+	}
+	previousChan := make(chan result, 1)
+	go func() {
+		rows, meta, err := runPrevious(ctx, previousRange.Start, previousRange.End)
+		previousChan <- result{rows: rows, meta: meta, err: err}
+	}()
+
+	current, currentMeta, err := run(ctx, startDate, endDate)
+	previous := <-previousChan
+	if err != nil {
+		return nil, err
+	}
+	if previous.err != nil {
+		return nil, fmt.Errorf("comparison range: %w", previous.err)
+	}
+
+	return &ComparisonResponse[T]{
+		Compare:           mode,
+		StartDate:         startDate.Format("2006-01-02"),
+		EndDate:           endDate.Format("2006-01-02"),
+		PreviousStartDate: previousRange.Start.Format("2006-01-02"),
+		PreviousEndDate:   previousRange.End.Format("2006-01-02"),
+		Data:              compareRows(current, previous.rows, metric),
+		ReportMeta:        derivedMeta(start, currentMeta, previous.meta),
+	}, nil
+}
+
+func (s *Service) CompareProfitLoss(ctx context.Context, startDate, endDate time.Time, currency string, mode CompareMode) (*ComparisonResponse[ProfitLossRow], error) {
+	run := func(ctx context.Context, startDate, endDate time.Time) ([]ProfitLossRow, ReportMeta, error) {
+		result, err := s.GetProfitLoss(ctx, startDate, endDate, currency)
+		if err != nil {
+			return nil, ReportMeta{}, err
+		}
+		return result.Data, result.ReportMeta, nil
+	}
+
+	return runComparison(ctx, startDate, endDate, mode, run, run, comparedMetric[ProfitLossRow]{
+		key:    func(r ProfitLossRow) string { return r.CategoryType + ":" + r.CategoryName },
+		amount: func(r ProfitLossRow) money.Amount { return r.TotalAmount },
+		blank: func(r ProfitLossRow) ProfitLossRow {
+			return ProfitLossRow{CategoryName: r.CategoryName, CategoryType: r.CategoryType}
+		},
+	})
+}
+
+func (s *Service) CompareRevenueByCategory(ctx context.Context, startDate, endDate time.Time, currency string, mode CompareMode) (*ComparisonResponse[RevenueByCategoryRow], error) {
+	run := func(ctx context.Context, startDate, endDate time.Time) ([]RevenueByCategoryRow, ReportMeta, error) {
+		result, err := s.GetRevenueByCategory(ctx, startDate, endDate, currency)
+		if err != nil {
+			return nil, ReportMeta{}, err
+		}
+		return result.Data, result.ReportMeta, nil
+	}
+
+	return runComparison(ctx, startDate, endDate, mode, run, run, comparedMetric[RevenueByCategoryRow]{
+		key:    func(r RevenueByCategoryRow) string { return r.CategoryName },
+		amount: func(r RevenueByCategoryRow) money.Amount { return r.RevenueAmount },
+		blank: func(r RevenueByCategoryRow) RevenueByCategoryRow {
+			return RevenueByCategoryRow{CategoryName: r.CategoryName}
+		},
+	})
+}
+
+// CompareTopCustomers lists the top customers of the current range, each with
+// its revenue in the comparison range whatever its rank there, so a customer
+// ranked lower before is not reported as new. Customers who dropped out of
+// the top list are not listed.
+func (s *Service) CompareTopCustomers(ctx context.Context, startDate, endDate time.Time, limit int, currency string, mode CompareMode) (*ComparisonResponse[TopCustomerRow], error) {
+	top := func(limit int) func(ctx context.Context, startDate, endDate time.Time) ([]TopCustomerRow, ReportMeta, error) {
+		return func(ctx context.Context, startDate, endDate time.Time) ([]TopCustomerRow, ReportMeta, error) {
+			result, err := s.GetTopCustomers(ctx, startDate, endDate, limit, currency)
+			if err != nil {
+				return nil, ReportMeta{}, err
+			}
+			return result.Data, result.ReportMeta, nil
+		}
+	}
+
+	// Every customer of the comparison range is fetched, as the current top
+	// customers may rank anywhere in it
+	return runComparison(ctx, startDate, endDate, mode, top(limit), top(0), comparedMetric[TopCustomerRow]{
+		key:    func(r TopCustomerRow) string { return r.CustomerID + ":" + r.CustomerName },
+		amount: func(r TopCustomerRow) money.Amount { return r.TotalRevenue },
+		blank: func(r TopCustomerRow) TopCustomerRow {
+			return TopCustomerRow{CustomerID: r.CustomerID, CustomerName: r.CustomerName}
+		},
+		currentOnly: true,
+	})
+}
@@ -0,0 +1,81 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+// reportingCurrency is the reporting_currency argument of the report stored
+// procedures. NULL reports in the default ledger's base currency.
+func reportingCurrency(currency string) interface{} {
+	if currency == "" {
+		return nil
+	}
+	return currency
+}
+
+type FXRevaluationRow struct {
+	CustomerID         string       `json:"customer_id"`
+	CustomerName       string       `json:"customer_name"`
+	LedgerCode         string       `json:"ledger_code"`
+	BaseCurrency       string       `json:"base_currency"`
+	Currency           string       `json:"currency"`
+	OpenAmount         money.Amount `json:"open_amount"`
+	BookedAmount       money.Amount `json:"booked_amount"`
+	RevaluationRate    float64      `json:"revaluation_rate"`
+	RevaluedAmount     money.Amount `json:"revalued_amount"`
+	UnrealizedGainLoss money.Amount `json:"unrealized_gain_loss"`
+}
+
+type FXRevaluationResponse struct {
+	AsOf string             `json:"as_of"`
+	Data []FXRevaluationRow `json:"data"`
+	// Totals are per ledger base currency, as ledgers cannot be summed across currencies
+	TotalGainLoss map[string]money.Amount `json:"total_gain_loss"`
+	ReportMeta
+}
+
+// GetFXRevaluation revalues open foreign-currency receivables at the rate on
+// asOf and reports the unrealized gain or loss against the base amounts booked
+// at each transaction's rate. A positive amount is a gain.
+func (s *Service) GetFXRevaluation(ctx context.Context, asOf time.Time) (*FXRevaluationResponse, error) {
+	cacheKey := reportKey("fx_revaluation", time.Time{}, asOf)
+	// Revaluation depends on exchange rates, which can be corrected at any time.
+	return cachedReport(ctx, s, cacheKey, time.Time{}, func(ctx context.Context) (*FXRevaluationResponse, error) {
+		return s.computeFXRevaluation(ctx, asOf)
+	})
+}
+
+func (s *Service) computeFXRevaluation(ctx context.Context, asOf time.Time) (*FXRevaluationResponse, error) {
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_fx_revaluation($1)`, s.schema)
+	rows, err := s.db.Query(ctx, query, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	response := &FXRevaluationResponse{
+		AsOf:          asOf.Format("2006-01-02"),
+		Data:          []FXRevaluationRow{},
+		TotalGainLoss: make(map[string]money.Amount),
+	}
+	for rows.Next() {
+		var row FXRevaluationRow
+		err := rows.Scan(&row.CustomerID, &row.CustomerName, &row.LedgerCode, &row.BaseCurrency, &row.Currency,
+			&row.OpenAmount, &row.BookedAmount, &row.RevaluationRate, &row.RevaluedAmount, &row.UnrealizedGainLoss)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		response.Data = append(response.Data, row)
+		response.TotalGainLoss[row.BaseCurrency] += row.UnrealizedGainLoss
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,627 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/money"
+)
+
+// tableRow is implemented by report rows that export as one spreadsheet row,
+// so comparisons can export any of them.
+type tableRow interface {
+	columns() []string
+	cells() []interface{}
+}
+
+// rangeMeta is the metadata of reports over a date range.
+func rangeMeta(title, startDate, endDate, currency string) []export.Field {
+	meta := []export.Field{
+		{Label: "Report", Value: title},
+		{Label: "Start Date", Value: startDate},
+		{Label: "End Date", Value: endDate},
+	}
+	return withCurrency(meta, currency)
+}
+
+// asOfMeta is the metadata of point-in-time reports.
+func asOfMeta(title, asOf, currency string) []export.Field {
+	meta := []export.Field{
+		{Label: "Report", Value: title},
+		{Label: "As Of", Value: asOf},
+	}
+	return withCurrency(meta, currency)
+}
+
+func withCurrency(meta []export.Field, currency string) []export.Field {
+	if currency != "" {
+		meta = append(meta, export.Field{Label: "Currency", Value: currency})
+	}
+	return meta
+}
+
+// fileName joins the parts of an export file name, skipping empty ones.
+func fileName(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "_")
+}
+
+func (r ProfitLossRow) columns() []string {
+	return []string{"Category", "Type", "Amount", "Transactions", "Reversals"}
+}
+
+func (r ProfitLossRow) cells() []interface{} {
+	return []interface{}{r.CategoryName, r.CategoryType, r.TotalAmount, r.TransactionCount, r.ReversalCount}
+}
+
+func (r RevenueByCategoryRow) columns() []string {
+	return []string{"Category", "Revenue", "Transactions", "Average Transaction"}
+}
+
+func (r RevenueByCategoryRow) cells() []interface{} {
+	return []interface{}{r.CategoryName, r.RevenueAmount, r.TransactionCount, r.AverageTransaction}
+}
+
+func (r TopCustomerRow) columns() []string {
+	return []string{"Customer ID", "Customer", "Revenue", "Transactions", "Average Transaction"}
+}
+
+func (r TopCustomerRow) cells() []interface{} {
+	return []interface{}{r.CustomerID, r.CustomerName, r.TotalRevenue, r.TransactionCount, r.AverageTransaction}
+}
+
+// tableSheet exports rows that all share one layout.
+func tableSheet[T tableRow](name string, meta []export.Field, rows []T) export.Sheet {
+	var zero T
+	sheet := export.Sheet{Name: name, Meta: meta, Columns: zero.columns()}
+	for _, row := range rows {
+		sheet.AddRow(row.cells()...)
+	}
+	return sheet
+}
+
+// Workbook adds a sheet of the reversal links of each category when there
+// are any.
+func (r *ProfitLossResponse) Workbook() export.Workbook {
+	meta := rangeMeta("Profit and Loss", r.StartDate, r.EndDate, r.Currency)
+	sheets := []export.Sheet{tableSheet("Profit and Loss", meta, r.Data)}
+	if reversals := profitLossReversals(r.Data); len(reversals.Rows) > 0 {
+		reversals.Meta = meta
+		sheets = append(sheets, reversals)
+	}
+	return export.Workbook{
+		Name:   fileName("profit-loss", r.StartDate, r.EndDate, r.Currency),
+		Sheets: sheets,
+	}
+}
+
+func profitLossReversals(rows []ProfitLossRow) export.Sheet {
+	sheet := export.Sheet{
+		Name:    "Reversals",
+		Columns: []string{"Category", "Type", "Transaction ID", "Reversal Of", "Reversed By"},
+	}
+	for _, row := range rows {
+		for _, link := range row.Reversals {
+			sheet.AddRow(row.CategoryName, row.CategoryType, link.TransactionID, link.ReversalOf, link.ReversedBy)
+		}
+	}
+	return sheet
+}
+
+func (r *RevenueByCategoryResponse) Workbook() export.Workbook {
+	return export.Workbook{
+		Name:   fileName("revenue-category", r.StartDate, r.EndDate, r.Currency),
+		Sheets: []export.Sheet{tableSheet("Revenue by Category", rangeMeta("Revenue by Category", r.StartDate, r.EndDate, r.Currency), r.Data)},
+	}
+}
+
+func (r *TopCustomersResponse) Workbook() export.Workbook {
+	return export.Workbook{
+		Name:   fileName("top-customers", r.StartDate, r.EndDate, r.Currency),
+		Sheets: []export.Sheet{tableSheet("Top Customers", rangeMeta("Top Customers", r.StartDate, r.EndDate, r.Currency), r.Data)},
+	}
+}
+
+// Workbook exports each compared row as the current row, the previous row
+// and the change between them.
+func (r *ComparisonResponse[T]) Workbook() export.Workbook {
+	sheet := export.Sheet{Name: "Comparison"}
+	if len(r.Data) > 0 {
+		if row, ok := any(r.Data[0].Current).(tableRow); ok {
+			columns := row.columns()
+			sheet.Columns = append(sheet.Columns, columns...)
+			for _, column := range columns {
+				sheet.Columns = append(sheet.Columns, "Previous "+column)
+			}
+			sheet.Columns = append(sheet.Columns, "Change", "Change %")
+		}
+	}
+	sheet.Meta = []export.Field{
+		{Label: "Report", Value: "Comparison (" + string(r.Compare) + ")"},
+		{Label: "Start Date", Value: r.StartDate},
+		{Label: "End Date", Value: r.EndDate},
+		{Label: "Previous Start Date", Value: r.PreviousStartDate},
+		{Label: "Previous End Date", Value: r.PreviousEndDate},
+	}
+
+	for _, compared := range r.Data {
+		current, ok := any(compared.Current).(tableRow)
+		if !ok {
+			continue
+		}
+		previous := any(compared.Previous).(tableRow)
+		cells := append(current.cells(), previous.cells()...)
+		sheet.AddRow(append(cells, compared.Change, compared.ChangePct)...)
+	}
+
+	return export.Workbook{
+		Name:   fileName("comparison", string(r.Compare), r.StartDate, r.EndDate),
+		Sheets: []export.Sheet{sheet},
+	}
+}
+
+func (r *ARAgingResponse) Workbook() export.Workbook {
+	meta := append(asOfMeta("AR Aging", r.AsOf, r.Currency), export.Field{Label: "Terms Days", Value: fmt.Sprint(r.TermsDays)})
+	sheet := export.Sheet{
+		Name: "AR Aging",
+		Meta: meta,
+		Columns: []string{"Customer ID", "Customer", "Current", "1-30 Days", "31-60 Days", "61-90 Days",
+			"Over 90 Days", "Total Open", "Unapplied Credit"},
+	}
+	for _, row := range r.Data {
+		sheet.AddRow(row.CustomerID, row.CustomerName, row.Current, row.Days1To30, row.Days31To60, row.Days61To90,
+			row.Over90, row.TotalOpen, row.UnappliedCredit)
+	}
+	t := r.Totals
+	sheet.AddRow("", "Total", t.Current, t.Days1To30, t.Days31To60, t.Days61To90, t.Over90, t.TotalOpen, t.UnappliedCredit)
+
+	return export.Workbook{Name: fileName("ar-aging", r.AsOf, r.Currency), Sheets: []export.Sheet{sheet}}
+}
+
+func (r *BalanceSheetResponse) Workbook() export.Workbook {
+	sheet := export.Sheet{
+		Name:    "Balance Sheet",
+		Meta:    asOfMeta("Balance Sheet", r.AsOf, r.Currency),
+		Columns: []string{"Section", "Account Code", "Account", "Type", "Balance"},
+	}
+	for _, section := range []struct {
+		name string
+		BalanceSheetSection
+	}{
+		{"Assets", r.Assets},
+		{"Liabilities", r.Liabilities},
+		{"Equity", r.Equity},
+	} {
+		for _, row := range section.Accounts {
+			sheet.AddRow(section.name, row.AccountCode, row.AccountName, row.AccountType, row.Balance)
+		}
+		sheet.AddRow(section.name, "", "Total "+section.name, "", section.Total)
+	}
+	sheet.AddRow("", "", "Total Liabilities and Equity", "", r.Check.TotalLiabilitiesAndEquity)
+	sheet.AddRow("", "", "Difference", "", r.Check.Difference)
+
+	return export.Workbook{Name: fileName("balance-sheet", r.AsOf, r.Currency), Sheets: []export.Sheet{sheet}}
+}
+
+func (r *TrialBalanceResponse) Workbook() export.Workbook {
+	sheet := export.Sheet{
+		Name:    "Trial Balance",
+		Meta:    rangeMeta("Trial Balance", r.StartDate, r.EndDate, r.Currency),
+		Columns: []string{"Account Code", "Account", "Type", "Opening Balance", "Debit", "Credit", "Closing Balance"},
+	}
+	for _, row := range r.Data {
+		sheet.AddRow(row.AccountCode, row.AccountName, row.AccountType, row.OpeningBalance, row.PeriodDebit,
+			row.PeriodCredit, row.ClosingBalance)
+	}
+	t := r.Totals
+	sheet.AddRow("", "Total", "", t.OpeningBalance, t.PeriodDebit, t.PeriodCredit, t.ClosingBalance)
+
+	return export.Workbook{Name: fileName("trial-balance", r.StartDate, r.EndDate, r.Currency), Sheets: []export.Sheet{sheet}}
+}
+
+// Workbook exports the current page of the ledger; the metadata row carries
+// the cursor of the next page.
+func (r *LedgerResponse) Workbook() export.Workbook {
+	meta := append(rangeMeta("General Ledger", r.StartDate, r.EndDate, r.Currency),
+		export.Field{Label: "Account", Value: r.Account.AccountCode + " " + r.Account.AccountName},
+		export.Field{Label: "Opening Balance", Value: r.Account.OpeningBalance.String()},
+		export.Field{Label: "Closing Balance", Value: r.Account.ClosingBalance.String()},
+	)
+	if r.HasMore {
+		meta = append(meta, export.Field{Label: "Next Cursor", Value: r.NextCursor})
+	}
+	sheet := export.Sheet{
+		Name: "Ledger " + r.Account.AccountCode,
+		Meta: meta,
+		Columns: []string{"Date", "Reference", "Description", "Debit", "Credit", "Running Balance",
+			"Transaction ID", "Reversal Of", "Reversed By"},
+	}
+	for _, line := range r.Lines {
+		sheet.AddRow(line.TransactionDate, line.ReferenceNumber, line.Description, line.Debit, line.Credit,
+			line.RunningBalance, line.TransactionID, line.ReversalOf, line.ReversedBy)
+	}
+
+	return export.Workbook{
+		Name:   fileName("ledger", r.Account.AccountCode, r.StartDate, r.EndDate, r.Currency),
+		Sheets: []export.Sheet{sheet},
+	}
+}
+
+// Workbook exports the direct and indirect methods as separate sheets.
+func (r *CashFlowResponse) Workbook() export.Workbook {
+	meta := append(rangeMeta("Cash Flow", r.StartDate, r.EndDate, r.Currency),
+		export.Field{Label: "Cash Account", Value: r.CashAccountCode},
+		export.Field{Label: "Opening Cash", Value: r.OpeningCash.String()},
+		export.Field{Label: "Closing Cash", Value: r.ClosingCash.String()},
+	)
+
+	direct := export.Sheet{
+		Name:    "Cash Flow (Direct)",
+		Meta:    meta,
+		Columns: []string{"Activity", "Transaction Type", "Receipts", "Payments", "Net"},
+	}
+	for _, row := range r.Direct.Rows {
+		direct.AddRow(row.Activity, row.TransactionType, row.Receipts, row.Payments, row.Net)
+	}
+	addCashFlowTotals(&direct, r.Direct.Totals, 5)
+
+	indirect := export.Sheet{
+		Name:    "Cash Flow (Indirect)",
+		Meta:    meta,
+		Columns: []string{"Activity", "Account Code", "Account", "Type", "Amount"},
+	}
+	indirect.AddRow("operating", "", "Net Income", "", r.Indirect.NetIncome)
+	for _, row := range r.Indirect.Adjustments {
+		indirect.AddRow(row.Activity, row.AccountCode, row.AccountName, row.AccountType, row.Amount)
+	}
+	addCashFlowTotals(&indirect, r.Indirect.Totals, 5)
+
+	return export.Workbook{
+		Name:   fileName("cash-flow", r.CashAccountCode, r.StartDate, r.EndDate, r.Currency),
+		Sheets: []export.Sheet{direct, indirect},
+	}
+}
+
+// addCashFlowTotals appends one total row per activity with the amount in the
+// last of width columns.
+func addCashFlowTotals(sheet *export.Sheet, totals CashFlowTotals, width int) {
+	for _, total := range []struct {
+		label  string
+		amount interface{}
+	}{
+		{"Total Operating", totals.Operating},
+		{"Total Investing", totals.Investing},
+		{"Total Financing", totals.Financing},
+		{"Net Change", totals.NetChange},
+	} {
+		row := make([]interface{}, width)
+		row[0] = total.label
+		row[width-1] = total.amount
+		sheet.AddRow(row...)
+	}
+}
+
+// Workbook exports the series in long form, one row per series and bucket.
+func (r *TimeSeriesResponse) Workbook() export.Workbook {
+	meta := append(rangeMeta("Time Series", r.StartDate, r.EndDate, r.Currency),
+		export.Field{Label: "Granularity", Value: r.Granularity},
+		export.Field{Label: "Metric", Value: r.Metric},
+	)
+	sheet := export.Sheet{
+		Name:    "Time Series",
+		Meta:    meta,
+		Columns: []string{"Series", "Bucket Start", "Bucket End", "Value"},
+	}
+	for _, series := range r.Series {
+		for _, point := range series.Points {
+			sheet.AddRow(series.Name, point.BucketStart, point.BucketEnd, point.Value)
+		}
+	}
+
+	return export.Workbook{
+		Name:   fileName("timeseries", r.Metric, r.Granularity, r.StartDate, r.EndDate, r.Currency),
+		Sheets: []export.Sheet{sheet},
+	}
+}
+
+func (r *FXRevaluationResponse) Workbook() export.Workbook {
+	sheet := export.Sheet{
+		Name: "FX Revaluation",
+		Meta: asOfMeta("FX Revaluation", r.AsOf, ""),
+		Columns: []string{"Customer ID", "Customer", "Ledger", "Base Currency", "Currency", "Open Amount",
+			"Booked Amount", "Revaluation Rate", "Revalued Amount", "Unrealized Gain/Loss"},
+	}
+	for _, row := range r.Data {
+		sheet.AddRow(row.CustomerID, row.CustomerName, row.LedgerCode, row.BaseCurrency, row.Currency, row.OpenAmount,
+			row.BookedAmount, row.RevaluationRate, row.RevaluedAmount, row.UnrealizedGainLoss)
+	}
+
+	currencies := make([]string, 0, len(r.TotalGainLoss))
+	for currency := range r.TotalGainLoss {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		sheet.AddRow("", "Total", "", currency, "", nil, nil, nil, nil, r.TotalGainLoss[currency])
+	}
+
+	return export.Workbook{Name: fileName("fx-revaluation", r.AsOf), Sheets: []export.Sheet{sheet}}
+}
+
+// treeRows flattens a tree depth first. Account names are indented by depth
+// so the hierarchy stays readable in a spreadsheet.
+func treeRows[T rollup[T]](nodes []*AccountNode[T], visit func(node *AccountNode[T], name string)) {
+	for _, node := range nodes {
+		visit(node, strings.Repeat("  ", node.Depth)+node.AccountName)
+		treeRows(node.Children, visit)
+	}
+}
+
+func (r *BalanceSheetTreeResponse) Workbook() export.Workbook {
+	sheet := export.Sheet{
+		Name:    "Balance Sheet",
+		Meta:    asOfMeta("Balance Sheet", r.AsOf, r.Currency),
+		Columns: []string{"Section", "Account Code", "Account", "Depth", "Own Balance", "Rolled Up Balance"},
+	}
+	for _, section := range []struct {
+		name  string
+		nodes []*AccountNode[AccountBalance]
+	}{
+		{"Assets", r.Assets},
+		{"Liabilities", r.Liabilities},
+		{"Equity", r.Equity},
+	} {
+		treeRows(section.nodes, func(node *AccountNode[AccountBalance], name string) {
+			sheet.AddRow(section.name, node.AccountCode, name, node.Depth, node.Own.Balance, node.RolledUp.Balance)
+		})
+	}
+
+	return export.Workbook{Name: fileName("balance-sheet", r.AsOf, r.Currency), Sheets: []export.Sheet{sheet}}
+}
+
+func (r *TrialBalanceTreeResponse) Workbook() export.Workbook {
+	sheet := export.Sheet{
+		Name: "Trial Balance",
+		Meta: rangeMeta("Trial Balance", r.StartDate, r.EndDate, r.Currency),
+		Columns: []string{"Account Code", "Account", "Depth", "Opening Balance", "Debit", "Credit",
+			"Closing Balance"},
+	}
+	treeRows(r.Data, func(node *AccountNode[TrialBalanceTotals], name string) {
+		t := node.RolledUp
+		sheet.AddRow(node.AccountCode, name, node.Depth, t.OpeningBalance, t.PeriodDebit, t.PeriodCredit, t.ClosingBalance)
+	})
+	t := r.Totals
+	sheet.AddRow("", "Total", nil, t.OpeningBalance, t.PeriodDebit, t.PeriodCredit, t.ClosingBalance)
+
+	return export.Workbook{Name: fileName("trial-balance", r.StartDate, r.EndDate, r.Currency), Sheets: []export.Sheet{sheet}}
+}
+
+// parallelReports is the response of the parallel endpoint. It exports as one
+// workbook with a sheet per report.
+type parallelReports map[string]interface{}
+
+func (r parallelReports) Workbook() export.Workbook {
+	keys := make([]string, 0, len(r))
+	for key := range r {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	workbook := export.Workbook{Name: "reports"}
+	for _, key := range keys {
+		report, ok := r[key].(export.Exportable)
+		if !ok {
+			continue
+		}
+		exported := report.Workbook()
+		workbook.Sheets = append(workbook.Sheets, exported.Sheets...)
+		if workbook.Name == "reports" {
+			// Every report covers the same range; reuse it for the file name
+			_, dates, _ := strings.Cut(exported.Name, "_")
+			workbook.Name = fileName("reports", dates)
+		}
+	}
+	return workbook
+}
+
+// amountHeading heads the amount column of a statement with its currency.
+func amountHeading(currency string) string {
+	if currency == "" {
+		return "Amount"
+	}
+	return "Amount (" + currency + ")"
+}
+
+// Statement lays the profit and loss out as revenue and expense sections with
+// their subtotals, followed by net profit.
+func (r *ProfitLossResponse) Statement() export.Statement {
+	table := export.Table{Columns: []string{"", amountHeading(r.Currency)}}
+	var revenue, expenses money.Amount
+	for _, section := range []struct {
+		title        string
+		categoryType string
+		total        *money.Amount
+	}{
+		{"Revenue", "revenue", &revenue},
+		{"Expenses", "expense", &expenses},
+	} {
+		table.Lines = append(table.Lines, export.Line{Style: export.LineHeading, Label: section.title})
+		for _, row := range r.Data {
+			if row.CategoryType != section.categoryType {
+				continue
+			}
+			table.Lines = append(table.Lines, export.Line{Label: row.CategoryName, Indent: 1, Values: []interface{}{row.TotalAmount}})
+			*section.total += row.TotalAmount
+		}
+		table.Lines = append(table.Lines, export.Line{
+			Style:  export.LineSubtotal,
+			Label:  "Total " + section.title,
+			Values: []interface{}{*section.total},
+		})
+	}
+	table.Lines = append(table.Lines, export.Line{Style: export.LineTotal, Label: "Net Profit", Values: []interface{}{revenue - expenses}})
+	tables := []export.Table{table}
+
+	// Reversed and reversing entries are listed after the statement
+	links := export.Table{Title: "Reversals", Columns: []string{"Transaction", "Link", "Linked Transaction"}}
+	for _, row := range r.Data {
+		for _, link := range row.Reversals {
+			if link.ReversalOf != "" {
+				links.Lines = append(links.Lines, export.Line{
+					Label:  link.TransactionID,
+					Values: []interface{}{"reverses", link.ReversalOf},
+				})
+			}
+			if link.ReversedBy != "" {
+				links.Lines = append(links.Lines, export.Line{
+					Label:  link.TransactionID,
+					Values: []interface{}{"reversed by", link.ReversedBy},
+				})
+			}
+		}
+	}
+	if len(links.Lines) > 0 {
+		tables = append(tables, links)
+	}
+
+	return export.Statement{
+		Name:   fileName("profit-loss", r.StartDate, r.EndDate, r.Currency),
+		Title:  "Profit and Loss",
+		Period: export.PeriodLabel(r.StartDate, r.EndDate),
+		Tables: tables,
+	}
+}
+
+// Statement lays the balance sheet out by section, closing with total assets
+// against total liabilities and equity.
+func (r *BalanceSheetResponse) Statement() export.Statement {
+	table := export.Table{Columns: []string{"", amountHeading(r.Currency)}}
+	for _, section := range []struct {
+		name string
+		BalanceSheetSection
+	}{
+		{"Assets", r.Assets},
+		{"Liabilities", r.Liabilities},
+		{"Equity", r.Equity},
+	} {
+		table.Lines = append(table.Lines, export.Line{Style: export.LineHeading, Label: section.name})
+		for _, row := range section.Accounts {
+			table.Lines = append(table.Lines, export.Line{Label: accountLabel(row.AccountCode, row.AccountName), Indent: 1, Values: []interface{}{row.Balance}})
+		}
+		table.Lines = append(table.Lines, export.Line{
+			Style:  sectionTotalStyle(section.name),
+			Label:  "Total " + section.name,
+			Values: []interface{}{section.Total},
+		})
+	}
+	table.Lines = append(table.Lines, export.Line{
+		Style:  export.LineTotal,
+		Label:  "Total Liabilities and Equity",
+		Values: []interface{}{r.Check.TotalLiabilitiesAndEquity},
+	})
+
+	return export.Statement{
+		Name:   fileName("balance-sheet", r.AsOf, r.Currency),
+		Title:  "Balance Sheet",
+		Period: export.AsOfLabel(r.AsOf),
+		Notes:  balanceNotes(r.Check),
+		Tables: []export.Table{table},
+	}
+}
+
+// Statement lays the balance sheet out like the flat one, with each parent
+// account heading its children and followed by their total.
+func (r *BalanceSheetTreeResponse) Statement() export.Statement {
+	table := export.Table{Columns: []string{"", amountHeading(r.Currency)}}
+	for _, section := range []struct {
+		name  string
+		nodes []*AccountNode[AccountBalance]
+		total money.Amount
+	}{
+		{"Assets", r.Assets, r.Check.TotalAssets},
+		{"Liabilities", r.Liabilities, sumBalances(r.Liabilities)},
+		{"Equity", r.Equity, sumBalances(r.Equity)},
+	} {
+		table.Lines = append(table.Lines, export.Line{Style: export.LineHeading, Label: section.name})
+		table.Lines = appendTreeLines(table.Lines, section.nodes, 1)
+		table.Lines = append(table.Lines, export.Line{
+			Style:  sectionTotalStyle(section.name),
+			Label:  "Total " + section.name,
+			Values: []interface{}{section.total},
+		})
+	}
+	table.Lines = append(table.Lines, export.Line{
+		Style:  export.LineTotal,
+		Label:  "Total Liabilities and Equity",
+		Values: []interface{}{r.Check.TotalLiabilitiesAndEquity},
+	})
+
+	return export.Statement{
+		Name:   fileName("balance-sheet", r.AsOf, r.Currency),
+		Title:  "Balance Sheet",
+		Period: export.AsOfLabel(r.AsOf),
+		Notes:  balanceNotes(r.Check),
+		Tables: []export.Table{table},
+	}
+}
+
+func appendTreeLines(lines []export.Line, nodes []*AccountNode[AccountBalance], indent int) []export.Line {
+	for _, node := range nodes {
+		label := accountLabel(node.AccountCode, node.AccountName)
+		if len(node.Children) == 0 {
+			lines = append(lines, export.Line{Label: label, Indent: indent, Values: []interface{}{node.RolledUp.Balance}})
+			continue
+		}
+		lines = append(lines, export.Line{Style: export.LineHeading, Label: label, Indent: indent})
+		// Postings made to the parent account itself
+		if node.Own.Balance != 0 {
+			lines = append(lines, export.Line{Label: label, Indent: indent + 1, Values: []interface{}{node.Own.Balance}})
+		}
+		lines = appendTreeLines(lines, node.Children, indent+1)
+		lines = append(lines, export.Line{
+			Style:  export.LineSubtotal,
+			Label:  "Total " + node.AccountName,
+			Indent: indent,
+			Values: []interface{}{node.RolledUp.Balance},
+		})
+	}
+	return lines
+}
+
+func sumBalances(nodes []*AccountNode[AccountBalance]) money.Amount {
+	var total money.Amount
+	for _, node := range nodes {
+		total += node.RolledUp.Balance
+	}
+	return total
+}
+
+// sectionTotalStyle double-underlines total assets, which balances against
+// total liabilities and equity.
+func sectionTotalStyle(section string) export.LineStyle {
+	if section == "Assets" {
+		return export.LineTotal
+	}
+	return export.LineSubtotal
+}
+
+func accountLabel(code, name string) string {
+	if code == "" {
+		return name
+	}
+	return code + " " + name
+}
+
+func balanceNotes(check BalanceSheetCheck) []string {
+	if check.Balanced {
+		return nil
+	}
+	return []string{"Out of balance by " + check.Difference.String()}
+}
@@ -1,115 +1,166 @@
 package reports
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
+	"net/url"
 	"time"
 
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/fx"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type Handler struct {
-	service *Service
+	runner   *Runner
+	renderer *export.Renderer
 }
 
-func NewHandler(service *Service) *Handler {
+func NewHandler(runner *Runner, renderer *export.Renderer) *Handler {
 	return &Handler{
-		service: service,
+		runner:   runner,
+		renderer: renderer,
 	}
 }
 
 // GetProfitLoss handles GET /api/reports/profit-loss
 func (h *Handler) GetProfitLoss(c *gin.Context) {
-	startDate, endDate, err := parseDateRange(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	result, err := h.service.GetProfitLoss(c.Request.Context(), startDate, endDate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, result)
+	h.respond(c, TypeProfitLoss, c.Request.URL.Query())
 }
 
 // GetRevenueByCategory handles GET /api/reports/revenue-category
 func (h *Handler) GetRevenueByCategory(c *gin.Context) {
-	startDate, endDate, err := parseDateRange(c)
+	h.respond(c, TypeRevenueByCategory, c.Request.URL.Query())
+}
+
+// GetTopCustomers handles GET /api/reports/top-customers
+func (h *Handler) GetTopCustomers(c *gin.Context) {
+	h.respond(c, TypeTopCustomers, c.Request.URL.Query())
+}
+
+// GetARAging handles GET /api/reports/ar-aging
+func (h *Handler) GetARAging(c *gin.Context) {
+	h.respond(c, TypeARAging, c.Request.URL.Query())
+}
+
+// GetMultipleReportsParallel handles GET /api/reports/parallel (demo endpoint)
+func (h *Handler) GetMultipleReportsParallel(c *gin.Context) {
+	h.respond(c, TypeParallel, c.Request.URL.Query())
+}
+
+// GetBalanceSheet handles GET /api/reports/balance-sheet
+func (h *Handler) GetBalanceSheet(c *gin.Context) {
+	h.respond(c, TypeBalanceSheet, c.Request.URL.Query())
+}
+
+// GetTrialBalance handles GET /api/reports/trial-balance
+func (h *Handler) GetTrialBalance(c *gin.Context) {
+	h.respond(c, TypeTrialBalance, c.Request.URL.Query())
+}
+
+// GetLedger handles GET /api/reports/ledger/:account_code
+func (h *Handler) GetLedger(c *gin.Context) {
+	params := c.Request.URL.Query()
+	params.Set("account_code", c.Param("account_code"))
+	h.respond(c, TypeLedger, params)
+}
+
+// GetCashFlow handles GET /api/reports/cash-flow
+func (h *Handler) GetCashFlow(c *gin.Context) {
+	h.respond(c, TypeCashFlow, c.Request.URL.Query())
+}
+
+// GetTimeSeries handles GET /api/reports/timeseries
+func (h *Handler) GetTimeSeries(c *gin.Context) {
+	h.respond(c, TypeTimeSeries, c.Request.URL.Query())
+}
+
+// GetFXRevaluation handles GET /api/reports/fx-revaluation
+func (h *Handler) GetFXRevaluation(c *gin.Context) {
+	h.respond(c, TypeFXRevaluation, c.Request.URL.Query())
+}
+
+// respond computes a report and writes it in the format the request asks for.
+func (h *Handler) respond(c *gin.Context, reportType string, params url.Values) {
+	run, err := h.runner.Prepare(reportType, params, time.Now())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		WriteError(c, err)
 		return
 	}
 
-	result, err := h.service.GetRevenueByCategory(c.Request.Context(), startDate, endDate)
+	result, err := run(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		WriteError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	h.renderer.Respond(c, http.StatusOK, result)
 }
 
-// GetTopCustomers handles GET /api/reports/top-customers
-func (h *Handler) GetTopCustomers(c *gin.Context) {
-	startDate, endDate, err := parseDateRange(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 || limit > 100 {
-		limit = 10
+// InvalidateCache handles POST /api/admin/cache/invalidate. Without a body
+// every cached report is evicted.
+func (h *Handler) InvalidateCache(c *gin.Context) {
+	var req InvalidateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	result, err := h.service.GetTopCustomers(c.Request.Context(), startDate, endDate, limit)
+	from, err := parseOptionalDate(req.From)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date format, use YYYY-MM-DD"})
 		return
 	}
-
-	c.JSON(http.StatusOK, result)
-}
-
-// GetMultipleReportsParallel handles GET /api/reports/parallel (demo endpoint)
-func (h *Handler) GetMultipleReportsParallel(c *gin.Context) {
-	startDate, endDate, err := parseDateRange(c)
+	through, err := parseOptionalDate(req.Through)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid through date format, use YYYY-MM-DD"})
+		return
+	}
+	if !from.IsZero() && !through.IsZero() && from.After(through) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after through"})
 		return
 	}
 
-	result, err := h.service.GetMultipleReportsParallel(c.Request.Context(), startDate, endDate)
+	evicted, err := h.runner.service.Invalidate(c.Request.Context(), from, through)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "evicted": evicted})
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, gin.H{"evicted": evicted})
 }
 
-func parseDateRange(c *gin.Context) (time.Time, time.Time, error) {
-	startDateStr := c.DefaultQuery("start_date", time.Now().AddDate(0, -1, 0).Format("2006-01-02"))
-	endDateStr := c.DefaultQuery("end_date", time.Now().Format("2006-01-02"))
-
-	startDate, err := time.Parse("2006-01-02", startDateStr)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
-	}
-
-	endDate, err := time.Parse("2006-01-02", endDateStr)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
+// WriteError maps report errors to their status: invalid parameters and
+// cursors are 400, unknown accounts 404, broken account hierarchies and
+// conversions without an exchange rate 422, and anything else 500. Handlers
+// outside this package that run reports use it too.
+func WriteError(c *gin.Context, err error) {
+	var paramErr *ParamError
+	switch {
+	case errors.As(err, &paramErr), errors.Is(err, ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrAccountCycle), errors.Is(err, ErrOrphanedAccount):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
 	}
 
-	if startDate.After(endDate) {
-		return time.Time{}, time.Time{}, err
+	if fx.IsMissingRate(err) {
+		var pgErr *pgconn.PgError
+		message := fx.ErrMissingRate.Error()
+		if errors.As(err, &pgErr) {
+			message = pgErr.Message
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": message})
+		return
 	}
 
-	return startDate, endDate, nil
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
-
@@ -0,0 +1,208 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// invalidationChannel is notified by fn_notify_report_cache whenever
+	// transactions are written, and by Invalidate.
+	invalidationChannel = "report_cache"
+	// listenRetryDelay is how long to wait before listening again after the
+	// connection was lost.
+	listenRetryDelay = 5 * time.Second
+)
+
+// reportKey is the cache key of a report whose figures depend on the
+// transactions dated from through through, followed by its other parameters.
+// Reports that carry balances forward depend on every transaction up to
+// through and pass a zero from. Invalidation matches keys by these dates.
+func reportKey(report string, from, through time.Time, params ...interface{}) string {
+	parts := []string{report, "", through.Format("2006-01-02")}
+	if !from.IsZero() {
+		parts[1] = from.Format("2006-01-02")
+	}
+	for _, param := range params {
+		parts = append(parts, fmt.Sprint(param))
+	}
+	return strings.Join(parts, ":")
+}
+
+// keyCovers reports whether the report cached under key depends on any
+// transaction dated from from through through; a zero bound is open. Keys
+// that cannot be read are assumed to.
+func keyCovers(key string, from, through time.Time) bool {
+	parts := strings.SplitN(key, ":", 4)
+	if len(parts) < 3 {
+		return true
+	}
+	keyThrough, err := time.Parse("2006-01-02", parts[2])
+	if err != nil {
+		return true
+	}
+	if !from.IsZero() && keyThrough.Before(from) {
+		return false
+	}
+	if parts[1] == "" || through.IsZero() {
+		return true
+	}
+	keyFrom, err := time.Parse("2006-01-02", parts[1])
+	return err != nil || !keyFrom.After(through)
+}
+
+// InvalidateRequest purges cached reports depending on transactions dated
+// from From through Through (YYYY-MM-DD). Either may be left out for an open
+// bound.
+type InvalidateRequest struct {
+	From    string `json:"from"`
+	Through string `json:"through"`
+}
+
+// InvalidateDates evicts every cached report that depends on a transaction
+// dated from from through through, and returns how many it evicted. Zero
+// bounds are open, so two zero times evict every report. Reports being
+// computed meanwhile are not cached when they finish, as they may predate the
+// change.
+func (s *Service) InvalidateDates(from, through time.Time) int {
+	// Reports computed from here on see the change, so only the epoch bump
+	// needs the lock; lookups are not held up by a slow shared backend.
+	s.invalidation.Lock()
+	s.epoch++
+	s.invalidation.Unlock()
+
+	return s.cache.DeleteFunc(func(key string) bool {
+		return keyCovers(key, from, through)
+	})
+}
+
+// Invalidate evicts the cached reports of InvalidateDates here and notifies
+// every other API process to do the same with its own.
+func (s *Service) Invalidate(ctx context.Context, from, through time.Time) (int, error) {
+	evicted := s.InvalidateDates(from, through)
+
+	payload, err := json.Marshal(invalidation{Schema: s.schema, From: formatDate(from), Through: formatDate(through)})
+	if err != nil {
+		return evicted, err
+	}
+	if _, err := s.db.Exec(ctx, `SELECT pg_notify($1, $2)`, invalidationChannel, string(payload)); err != nil {
+		return evicted, fmt.Errorf("failed to notify other processes: %w", err)
+	}
+	return evicted, nil
+}
+
+// storeUnlessInvalidated runs store unless the cache was invalidated since
+// epoch was read.
+func (s *Service) storeUnlessInvalidated(epoch uint64, store func()) {
+	s.invalidation.RLock()
+	defer s.invalidation.RUnlock()
+	if s.epoch == epoch {
+		store()
+	}
+}
+
+func (s *Service) cacheEpoch() uint64 {
+	s.invalidation.RLock()
+	defer s.invalidation.RUnlock()
+	return s.epoch
+}
+
+// invalidation is the payload of a notification on invalidationChannel. Empty
+// dates are open bounds.
+type invalidation struct {
+	Schema  string `json:"schema"`
+	From    string `json:"from"`
+	Through string `json:"through"`
+}
+
+// StartInvalidation evicts cached reports as transactions are written, by any
+// API process or directly in the database, until ctx is canceled.
+func (s *Service) StartInvalidation(ctx context.Context) {
+	s.listening.Add(1)
+	go func() {
+		defer s.listening.Done()
+		s.listen(ctx)
+	}()
+}
+
+// WaitInvalidation blocks until the listener started by StartInvalidation has
+// stopped after its context was canceled.
+func (s *Service) WaitInvalidation() {
+	s.listening.Wait()
+}
+
+func (s *Service) listen(ctx context.Context) {
+	for reconnect := false; ; reconnect = true {
+		err := s.listenOnce(ctx, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("report cache: stopped receiving change notifications, retrying in %s: %v", listenRetryDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// listenOnce listens on a connection of its own until it fails. After a
+// reconnect every report is evicted, as changes made meanwhile are unknown.
+func (s *Service) listenOnce(ctx context.Context, reconnect bool) error {
+	pooled, err := s.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+invalidationChannel); err != nil {
+		return err
+	}
+	if reconnect {
+		s.InvalidateDates(time.Time{}, time.Time{})
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg invalidation
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			log.Printf("report cache: unreadable change notification %q, evicting every report", notification.Payload)
+			s.InvalidateDates(time.Time{}, time.Time{})
+			continue
+		}
+		if msg.Schema != s.schema {
+			continue
+		}
+		from, fromErr := parseOptionalDate(msg.From)
+		through, throughErr := parseOptionalDate(msg.Through)
+		if fromErr != nil || throughErr != nil {
+			from, through = time.Time{}, time.Time{}
+		}
+		s.InvalidateDates(from, through)
+	}
+}
+
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+func parseOptionalDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,151 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"financial-reporting-system/internal/cache"
+)
+
+// ReportMeta describes how one response was produced. Cached responses are
+// shared by every request and never modified: each request gets its own copy
+// with its own ReportMeta.
+type ReportMeta struct {
+	// ExecutionTimeMs is how long this request took.
+	ExecutionTimeMs int64 `json:"execution_time_ms"`
+	Cached          bool  `json:"cached"`
+	// CachedAt and ExpiresAt bound how stale cached figures are.
+	CachedAt  *time.Time `json:"cached_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ComputeTimeMs is how long computing the figures took, whether now or
+	// when they were cached.
+	ComputeTimeMs int64 `json:"compute_time_ms"`
+}
+
+func (m *ReportMeta) reportMeta() *ReportMeta {
+	return m
+}
+
+// metaResponse is implemented by responses that embed ReportMeta.
+type metaResponse interface {
+	reportMeta() *ReportMeta
+}
+
+// cachedReport returns a copy of the response cached under key, or computes
+// and caches it. Concurrent misses of the same key share one computation,
+// which is only canceled once every caller waiting for it is. A report whose
+// figures cannot change once its last day is closed passes that day as
+// through, and is cached for longer when it is; others pass the zero time.
+func cachedReport[T any, P interface {
+	*T
+	metaResponse
+}](ctx context.Context, s *Service, key string, through time.Time, compute func(ctx context.Context) (P, error)) (P, error) {
+	start := time.Now()
+	if cached, found := fromCache[T, P](s.cache, key, start); found {
+		return cached, nil
+	}
+
+	shared, err := s.inflight.Do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		computeStart := time.Now()
+		epoch := s.cacheEpoch()
+		// The period is checked first, so figures computed while it was
+		// still open are never kept for longer.
+		ttl := s.cacheTTL(ctx, through)
+		response, err := compute(ctx)
+		if err != nil {
+			return nil, err
+		}
+		elapsed := time.Since(computeStart).Milliseconds()
+		*response.reportMeta() = ReportMeta{ExecutionTimeMs: elapsed, ComputeTimeMs: elapsed}
+		s.storeUnlessInvalidated(epoch, func() {
+			cacheResponse(s.cache, key, response, ttl)
+		})
+		return response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := P(new(T))
+	*response = *shared.(P)
+	response.reportMeta().ExecutionTimeMs = time.Since(start).Milliseconds()
+	return response, nil
+}
+
+// fromCache returns a copy of the response cached under key, with the
+// metadata of a request that started at start.
+func fromCache[T any, P interface {
+	*T
+	metaResponse
+}](c cache.Backend, key string, start time.Time) (P, bool) {
+	response := P(new(T))
+	entry, found := c.Get(key, response)
+	if !found {
+		return nil, false
+	}
+
+	meta := response.reportMeta()
+	meta.Cached = true
+	meta.CachedAt, meta.ExpiresAt = &entry.CachedAt, &entry.ExpiresAt
+	meta.ExecutionTimeMs = time.Since(start).Milliseconds()
+	return response, true
+}
+
+// cacheResponse caches a copy of a computed response, so that the caller's
+// response is its own.
+func cacheResponse[T any, P interface {
+	*T
+	metaResponse
+}](c cache.Backend, key string, response P, ttl time.Duration) {
+	stored := P(new(T))
+	*stored = *response
+	c.SetWithTTL(key, stored, ttl)
+}
+
+// cacheTTL is how long a report covering up to through is cached: longer when
+// through lies in a closed accounting period, as nothing can be posted there
+// any more and closed periods are never reopened.
+func (s *Service) cacheTTL(ctx context.Context, through time.Time) time.Duration {
+	if through.IsZero() || s.closedPeriodTTL <= s.cache.TTL() {
+		return s.cache.TTL()
+	}
+
+	var status string
+	query := fmt.Sprintf(`SELECT "%s".fn_period_status($1)`, s.schema)
+	if err := s.db.QueryRow(ctx, query, through).Scan(&status); err != nil || status != "closed" {
+		return s.cache.TTL()
+	}
+	return s.closedPeriodTTL
+}
+
+// lockedThrough is the through argument of cachedReport for a report up to
+// endDate. Reports converted to another currency can change with exchange
+// rates, so they get the zero time.
+func lockedThrough(endDate time.Time, currency string) time.Time {
+	if currency != "" {
+		return time.Time{}
+	}
+	return endDate
+}
+
+// derivedMeta is the metadata of a response built from others since start. It
+// is cached only when all of its sources were, and is as stale as the oldest
+// cached source.
+func derivedMeta(start time.Time, sources ...ReportMeta) ReportMeta {
+	meta := ReportMeta{
+		ExecutionTimeMs: time.Since(start).Milliseconds(),
+		Cached:          len(sources) > 0,
+	}
+	for _, source := range sources {
+		meta.Cached = meta.Cached && source.Cached
+		meta.ComputeTimeMs += source.ComputeTimeMs
+		if source.CachedAt != nil && (meta.CachedAt == nil || source.CachedAt.Before(*meta.CachedAt)) {
+			meta.CachedAt = source.CachedAt
+		}
+		if source.ExpiresAt != nil && (meta.ExpiresAt == nil || source.ExpiresAt.Before(*meta.ExpiresAt)) {
+			meta.ExpiresAt = source.ExpiresAt
+		}
+	}
+	return meta
+}
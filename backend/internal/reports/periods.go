@@ -0,0 +1,71 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"financial-reporting-system/internal/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// closedPeriod is the most recent closed accounting period relevant to a report.
+type closedPeriod struct {
+	ID               string
+	PeriodEnd        time.Time
+	RetainedEarnings money.Amount
+}
+
+// closedPeriodFor returns the closed period that exactly covers startDate to
+// endDate, or nil when the range is not a closed month.
+func (s *Service) closedPeriodFor(ctx context.Context, startDate, endDate time.Time) (*closedPeriod, error) {
+	query := fmt.Sprintf(`
+		SELECT id, period_end, COALESCE(retained_earnings, 0)
+		FROM "%s".accounting_periods
+		WHERE status = 'closed' AND period_start = $1 AND period_end = $2`, s.schema)
+	return s.scanClosedPeriod(ctx, query, startDate, endDate)
+}
+
+// latestClosedPeriod returns the last closed period ending on or before asOf,
+// or nil when nothing has been closed yet.
+func (s *Service) latestClosedPeriod(ctx context.Context, asOf time.Time) (*closedPeriod, error) {
+	query := fmt.Sprintf(`
+		SELECT id, period_end, COALESCE(retained_earnings, 0)
+		FROM "%s".accounting_periods
+		WHERE status = 'closed' AND period_end <= $1
+		ORDER BY period_end DESC
+		LIMIT 1`, s.schema)
+	return s.scanClosedPeriod(ctx, query, asOf)
+}
+
+func (s *Service) scanClosedPeriod(ctx context.Context, query string, args ...interface{}) (*closedPeriod, error) {
+	var period closedPeriod
+	err := s.db.QueryRow(ctx, query, args...).Scan(&period.ID, &period.PeriodEnd, &period.RetainedEarnings)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load accounting period: %w", err)
+	}
+	return &period, nil
+}
+
+// convertedRetainedEarnings recomputes the retained earnings of a closed period
+// in a reporting currency, converting every transaction up to periodEnd at the
+// rate on its own date. The amount stored at close is in the base currency.
+func (s *Service) convertedRetainedEarnings(ctx context.Context, periodEnd time.Time, currency string) (money.Amount, error) {
+	query := fmt.Sprintf(`
+		SELECT COALESCE(SUM(ti.credit - ti.debit), 0)::DECIMAL(15, 2)
+		FROM "%[1]s".fn_converted_items($2) ti
+		INNER JOIN "%[1]s".transactions t ON ti.transaction_id = t.id
+		INNER JOIN "%[1]s".accounts a ON ti.account_id = a.id
+		WHERE a.type IN ('revenue', 'expense') AND t.transaction_date <= $1`, s.schema)
+
+	var retained money.Amount
+	if err := s.db.QueryRow(ctx, query, periodEnd, currency).Scan(&retained); err != nil {
+		return 0, fmt.Errorf("failed to convert retained earnings: %w", err)
+	}
+	return retained, nil
+}
@@ -0,0 +1,440 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"financial-reporting-system/internal/fx"
+)
+
+// Report types accepted by Runner, named after their endpoints.
+const (
+	TypeProfitLoss        = "profit-loss"
+	TypeRevenueByCategory = "revenue-category"
+	TypeTopCustomers      = "top-customers"
+	TypeARAging           = "ar-aging"
+	TypeParallel          = "parallel"
+	TypeBalanceSheet      = "balance-sheet"
+	TypeTrialBalance      = "trial-balance"
+	TypeLedger            = "ledger"
+	TypeCashFlow          = "cash-flow"
+	TypeTimeSeries        = "timeseries"
+	TypeFXRevaluation     = "fx-revaluation"
+)
+
+// ParamError is returned by Prepare for unknown report types and invalid
+// parameters. Its message is safe to show to API clients.
+type ParamError struct {
+	Err error
+}
+
+func (e *ParamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// ReportFunc computes a prepared report.
+type ReportFunc func(ctx context.Context) (interface{}, error)
+
+// PrepareFunc validates report parameters and returns the report to compute.
+// Named periods and default dates are resolved relative to now.
+type PrepareFunc func(params url.Values, now time.Time) (ReportFunc, error)
+
+// Runner runs any report from its type and parameters, for the report
+// endpoints and for report jobs alike.
+type Runner struct {
+	service  *Service
+	calendar FiscalCalendar
+	reports  map[string]PrepareFunc
+}
+
+func NewRunner(service *Service, calendar FiscalCalendar) *Runner {
+	r := &Runner{service: service, calendar: calendar}
+	r.reports = map[string]PrepareFunc{
+		TypeProfitLoss:        r.prepareProfitLoss,
+		TypeRevenueByCategory: r.prepareRevenueByCategory,
+		TypeTopCustomers:      r.prepareTopCustomers,
+		TypeARAging:           r.prepareARAging,
+		TypeParallel:          r.prepareParallel,
+		TypeBalanceSheet:      r.prepareBalanceSheet,
+		TypeTrialBalance:      r.prepareTrialBalance,
+		TypeLedger:            r.prepareLedger,
+		TypeCashFlow:          r.prepareCashFlow,
+		TypeTimeSeries:        r.prepareTimeSeries,
+		TypeFXRevaluation:     r.prepareFXRevaluation,
+	}
+	return r
+}
+
+// Register adds a report type implemented outside this package. It must be
+// called before the runner is used.
+func (r *Runner) Register(reportType string, prepare PrepareFunc) {
+	r.reports[reportType] = prepare
+}
+
+// Types lists the report types Prepare accepts.
+func (r *Runner) Types() []string {
+	types := make([]string, 0, len(r.reports))
+	for reportType := range r.reports {
+		types = append(types, reportType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Prepare validates params, named like the query parameters of the report's
+// endpoint, and returns the report to compute. The ledger takes its account
+// code as the account_code parameter. Named periods such as last_month and
+// default dates are resolved relative to now.
+func (r *Runner) Prepare(reportType string, params url.Values, now time.Time) (ReportFunc, error) {
+	prepare, ok := r.reports[reportType]
+	if !ok {
+		return nil, &ParamError{Err: fmt.Errorf("unknown report type %q", reportType)}
+	}
+	run, err := prepare(params, now)
+	if err != nil {
+		return nil, &ParamError{Err: err}
+	}
+	return run, nil
+}
+
+func (r *Runner) prepareProfitLoss(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, currency, compare, err := r.parseComparableRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (interface{}, error) {
+		if compare != "" {
+			return r.service.CompareProfitLoss(ctx, startDate, endDate, currency, compare)
+		}
+		return r.service.GetProfitLoss(ctx, startDate, endDate, currency)
+	}, nil
+}
+
+func (r *Runner) prepareRevenueByCategory(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, currency, compare, err := r.parseComparableRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (interface{}, error) {
+		if compare != "" {
+			return r.service.CompareRevenueByCategory(ctx, startDate, endDate, currency, compare)
+		}
+		return r.service.GetRevenueByCategory(ctx, startDate, endDate, currency)
+	}, nil
+}
+
+func (r *Runner) prepareTopCustomers(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, currency, compare, err := r.parseComparableRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := strconv.Atoi(param(params, "limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		if compare != "" {
+			return r.service.CompareTopCustomers(ctx, startDate, endDate, limit, currency, compare)
+		}
+		return r.service.GetTopCustomers(ctx, startDate, endDate, limit, currency)
+	}, nil
+}
+
+func (r *Runner) prepareARAging(params url.Values, now time.Time) (ReportFunc, error) {
+	asOf, err := r.parseAsOfDate(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	termsDays, err := strconv.Atoi(param(params, "terms_days", strconv.Itoa(DefaultPaymentTermsDays)))
+	if err != nil || termsDays < 0 {
+		return nil, errors.New("terms_days must be a non-negative integer")
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		return r.service.GetARAging(ctx, asOf, termsDays, currency)
+	}, nil
+}
+
+func (r *Runner) prepareParallel(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, err := r.parseDateRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		result, err := r.service.GetMultipleReportsParallel(ctx, startDate, endDate, currency)
+		if err != nil {
+			return nil, err
+		}
+		return parallelReports(result), nil
+	}, nil
+}
+
+func (r *Runner) prepareBalanceSheet(params url.Values, now time.Time) (ReportFunc, error) {
+	asOf, err := r.parseAsOfDate(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, depth, err := parseTreeOptions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		if tree {
+			return r.service.GetBalanceSheetTree(ctx, asOf, depth, currency)
+		}
+		return r.service.GetBalanceSheet(ctx, asOf, currency)
+	}, nil
+}
+
+func (r *Runner) prepareTrialBalance(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, err := r.parseDateRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, depth, err := parseTreeOptions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		if tree {
+			return r.service.GetTrialBalanceTree(ctx, startDate, endDate, depth, currency)
+		}
+		return r.service.GetTrialBalance(ctx, startDate, endDate, currency)
+	}, nil
+}
+
+func (r *Runner) prepareLedger(params url.Values, now time.Time) (ReportFunc, error) {
+	accountCode := params.Get("account_code")
+	if accountCode == "" {
+		return nil, errors.New("account_code is required")
+	}
+
+	startDate, endDate, err := r.parseDateRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := strconv.Atoi(param(params, "limit", strconv.Itoa(defaultLedgerPageSize)))
+	if err != nil || limit < 1 || limit > maxLedgerPageSize {
+		limit = defaultLedgerPageSize
+	}
+	cursor := params.Get("cursor")
+
+	return func(ctx context.Context) (interface{}, error) {
+		return r.service.GetLedger(ctx, accountCode, startDate, endDate, cursor, limit, currency)
+	}, nil
+}
+
+func (r *Runner) prepareCashFlow(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, err := r.parseDateRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	cashAccount := param(params, "cash_account", DefaultCashAccountCode)
+
+	return func(ctx context.Context) (interface{}, error) {
+		return r.service.GetCashFlow(ctx, startDate, endDate, cashAccount, currency)
+	}, nil
+}
+
+func (r *Runner) prepareTimeSeries(params url.Values, now time.Time) (ReportFunc, error) {
+	startDate, endDate, err := r.parseDateRange(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := TimeSeriesOptions{
+		Granularity: param(params, "granularity", "month"),
+		Metric:      param(params, "metric", "revenue"),
+		Split:       params.Get("split"),
+		Currency:    currency,
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		return r.service.GetTimeSeries(ctx, startDate, endDate, opts)
+	}, nil
+}
+
+func (r *Runner) prepareFXRevaluation(params url.Values, now time.Time) (ReportFunc, error) {
+	asOf, err := r.parseAsOfDate(params, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		return r.service.GetFXRevaluation(ctx, asOf)
+	}, nil
+}
+
+// param returns the named parameter, or defaultValue when it is not set.
+func param(params url.Values, name, defaultValue string) string {
+	if value := params.Get(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// parseComparableRange reads the date range, currency and compare mode shared
+// by the reports that support period-over-period comparison.
+func (r *Runner) parseComparableRange(params url.Values, now time.Time) (time.Time, time.Time, string, CompareMode, error) {
+	startDate, endDate, err := r.parseDateRange(params, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", "", err
+	}
+
+	currency, err := parseCurrency(params)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", "", err
+	}
+
+	compare, err := parseCompareMode(params)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", "", err
+	}
+
+	return startDate, endDate, currency, compare, nil
+}
+
+// parseDateRange reads either a named period (see FiscalCalendar) or explicit
+// start_date/end_date parameters. Without either it defaults to the last month.
+func (r *Runner) parseDateRange(params url.Values, now time.Time) (time.Time, time.Time, error) {
+	if period := params.Get("period"); period != "" {
+		if params.Get("start_date") != "" || params.Get("end_date") != "" {
+			return time.Time{}, time.Time{}, errors.New("use either period or start_date/end_date, not both")
+		}
+		dates, err := r.calendar.Resolve(period, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return dates.Start, dates.End, nil
+	}
+
+	startDateStr := param(params, "start_date", now.AddDate(0, -1, 0).Format("2006-01-02"))
+	endDateStr := param(params, "end_date", now.Format("2006-01-02"))
+
+	startDate, err := time.Parse("2006-01-02", startDateStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	endDate, err := time.Parse("2006-01-02", endDateStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if startDate.After(endDate) {
+		return time.Time{}, time.Time{}, errors.New("start_date must not be after end_date")
+	}
+
+	return startDate, endDate, nil
+}
+
+// parseAsOfDate reads as_of, or the end of a named period for point-in-time
+// reports such as period=FY2025.
+func (r *Runner) parseAsOfDate(params url.Values, now time.Time) (time.Time, error) {
+	if period := params.Get("period"); period != "" {
+		if params.Get("as_of") != "" {
+			return time.Time{}, errors.New("use either period or as_of, not both")
+		}
+		dates, err := r.calendar.Resolve(period, now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return dates.End, nil
+	}
+
+	asOfStr := param(params, "as_of", now.Format("2006-01-02"))
+	return time.Parse("2006-01-02", asOfStr)
+}
+
+// parseCompareMode reads the optional compare parameter. An empty mode means
+// no comparison was requested.
+func parseCompareMode(params url.Values) (CompareMode, error) {
+	value := params.Get("compare")
+	if value == "" {
+		return "", nil
+	}
+	return ParseCompareMode(value)
+}
+
+// parseCurrency reads the optional reporting currency. An empty currency
+// reports in the default ledger's base currency.
+func parseCurrency(params url.Values) (string, error) {
+	value := params.Get("currency")
+	if value == "" {
+		return "", nil
+	}
+	return fx.NormalizeCurrency(value)
+}
+
+// parseTreeOptions reads tree=true and the optional depth used to collapse
+// account hierarchies. A depth of 0 returns the full tree.
+func parseTreeOptions(params url.Values) (bool, int, error) {
+	tree, err := strconv.ParseBool(param(params, "tree", "false"))
+	if err != nil {
+		return false, 0, fmt.Errorf("invalid tree parameter: %w", err)
+	}
+
+	depth, err := strconv.Atoi(param(params, "depth", "0"))
+	if err != nil || depth < 0 {
+		return false, 0, fmt.Errorf("depth must be a non-negative integer")
+	}
+
+	return tree, depth, nil
+}
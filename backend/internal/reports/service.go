@@ -3,87 +3,118 @@ package reports
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"financial-reporting-system/internal/cache"
+	"financial-reporting-system/internal/money"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Service struct {
 	db     *pgxpool.Pool
-	cache  *cache.Cache
+	cache  cache.Backend
 	schema string
+	// inflight collapses concurrent cache misses of the same report
+	inflight cache.Group
+	// closedPeriodTTL is how long reports within closed periods are cached
+	closedPeriodTTL time.Duration
+	// invalidation orders caching computed reports after evicting stale
+	// ones; epoch counts evictions
+	invalidation sync.RWMutex
+	epoch        uint64
+	// listening tracks the goroutine started by StartInvalidation
+	listening sync.WaitGroup
 }
 
-func NewService(db *pgxpool.Pool, cache *cache.Cache, schema string) *Service {
+func NewService(db *pgxpool.Pool, cache cache.Backend, schema string, closedPeriodTTL time.Duration) *Service {
 	log.Printf("Report Service initialized with schema: '%s'", schema)
 	return &Service{
-		db:     db,
-		cache:  cache,
-		schema: schema,
+		db:              db,
+		cache:           cache,
+		schema:          schema,
+		closedPeriodTTL: closedPeriodTTL,
 	}
 }
 
 type ProfitLossRow struct {
-	CategoryName   string  `json:"category_name"`
-	CategoryType   string  `json:"category_type"`
-	TotalAmount    float64 `json:"total_amount"`
-	TransactionCount int64 `json:"transaction_count"`
+	CategoryName     string       `json:"category_name"`
+	CategoryType     string       `json:"category_type"`
+	TotalAmount      money.Amount `json:"total_amount"`
+	TransactionCount int64        `json:"transaction_count"`
+	ReversalCount    int64        `json:"reversal_count"`
+	// Reversals are the category's transactions in range that reverse
+	// another or were reversed
+	Reversals []ReversalLink `json:"reversals,omitempty"`
+}
+
+// ReversalLink links a transaction to the one it reverses or the one that
+// reverses it, as on general ledger lines.
+type ReversalLink struct {
+	TransactionID string `json:"transaction_id"`
+	ReversalOf    string `json:"reversal_of,omitempty"`
+	ReversedBy    string `json:"reversed_by,omitempty"`
 }
 
 type ProfitLossResponse struct {
-	Data           []ProfitLossRow `json:"data"`
-	ExecutionTimeMs int64          `json:"execution_time_ms"`
-	Cached          bool           `json:"cached"`
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	Currency  string          `json:"currency,omitempty"`
+	Data      []ProfitLossRow `json:"data"`
+	ReportMeta
 }
 
 type RevenueByCategoryRow struct {
-	CategoryName      string  `json:"category_name"`
-	RevenueAmount     float64 `json:"revenue_amount"`
-	TransactionCount  int64   `json:"transaction_count"`
-	AverageTransaction float64 `json:"average_transaction"`
+	CategoryName       string       `json:"category_name"`
+	RevenueAmount      money.Amount `json:"revenue_amount"`
+	TransactionCount   int64        `json:"transaction_count"`
+	AverageTransaction money.Amount `json:"average_transaction"`
 }
 
 type RevenueByCategoryResponse struct {
-	Data           []RevenueByCategoryRow `json:"data"`
-	ExecutionTimeMs int64                `json:"execution_time_ms"`
-	Cached          bool                 `json:"cached"`
+	StartDate string                 `json:"start_date"`
+	EndDate   string                 `json:"end_date"`
+	Currency  string                 `json:"currency,omitempty"`
+	Data      []RevenueByCategoryRow `json:"data"`
+	ReportMeta
 }
 
 type TopCustomerRow struct {
-	CustomerID        string  `json:"customer_id"`
-	CustomerName      string  `json:"customer_name"`
-	TotalRevenue      float64 `json:"total_revenue"`
-	TransactionCount  int64   `json:"transaction_count"`
-	AverageTransaction float64 `json:"average_transaction"`
+	CustomerID         string       `json:"customer_id"`
+	CustomerName       string       `json:"customer_name"`
+	TotalRevenue       money.Amount `json:"total_revenue"`
+	TransactionCount   int64        `json:"transaction_count"`
+	AverageTransaction money.Amount `json:"average_transaction"`
 }
 
 type TopCustomersResponse struct {
-	Data           []TopCustomerRow `json:"data"`
-	ExecutionTimeMs int64          `json:"execution_time_ms"`
-	Cached          bool           `json:"cached"`
+	StartDate string           `json:"start_date"`
+	EndDate   string           `json:"end_date"`
+	Currency  string           `json:"currency,omitempty"`
+	Data      []TopCustomerRow `json:"data"`
+	ReportMeta
 }
 
-func (s *Service) GetProfitLoss(ctx context.Context, startDate, endDate time.Time) (*ProfitLossResponse, error) {
-	start := time.Now()
-	cacheKey := fmt.Sprintf("profit_loss:%s:%s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
-
-	// Check cache
-	if cached, found := s.cache.Get(cacheKey); found {
-		if data, ok := cached.(*ProfitLossResponse); ok {
-			data.Cached = true
-			data.ExecutionTimeMs = time.Since(start).Milliseconds()
-			return data, nil
-		}
-	}
+func (s *Service) GetProfitLoss(ctx context.Context, startDate, endDate time.Time, currency string) (*ProfitLossResponse, error) {
+	cacheKey := reportKey("profit_loss", startDate, endDate, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, currency), func(ctx context.Context) (*ProfitLossResponse, error) {
+		return s.computeProfitLoss(ctx, startDate, endDate, currency)
+	})
+}
 
+func (s *Service) computeProfitLoss(ctx context.Context, startDate, endDate time.Time, currency string) (*ProfitLossResponse, error) {
 	// Execute stored procedure with schema from config
-	query := fmt.Sprintf(`SELECT * FROM "%s".sp_profit_loss($1, $2)`, s.schema)
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_profit_loss($1, $2, $3)`, s.schema)
 	log.Printf("Executing query: %s with dates: %s to %s", query, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
-	rows, err := s.db.Query(ctx, query, startDate, endDate)
+	rows, err := s.db.Query(ctx, query, startDate, endDate, reportingCurrency(currency))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
 	}
@@ -92,7 +123,8 @@ func (s *Service) GetProfitLoss(ctx context.Context, startDate, endDate time.Tim
 	var results []ProfitLossRow
 	for rows.Next() {
 		var row ProfitLossRow
-		err := rows.Scan(&row.CategoryName, &row.CategoryType, &row.TotalAmount, &row.TransactionCount)
+		err := rows.Scan(&row.CategoryName, &row.CategoryType, &row.TotalAmount, &row.TransactionCount, &row.ReversalCount,
+			&row.Reversals)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -103,35 +135,27 @@ func (s *Service) GetProfitLoss(ctx context.Context, startDate, endDate time.Tim
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	executionTime := time.Since(start)
 	response := &ProfitLossResponse{
-		Data:            results,
-		ExecutionTimeMs: executionTime.Milliseconds(),
-		Cached:          false,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Currency:  currency,
+		Data:      results,
 	}
 
-	// Cache the result
-	s.cache.Set(cacheKey, response)
-
 	return response, nil
 }
 
-func (s *Service) GetRevenueByCategory(ctx context.Context, startDate, endDate time.Time) (*RevenueByCategoryResponse, error) {
-	start := time.Now()
-	cacheKey := fmt.Sprintf("revenue_category:%s:%s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
-
-	// Check cache
-	if cached, found := s.cache.Get(cacheKey); found {
-		if data, ok := cached.(*RevenueByCategoryResponse); ok {
-			data.Cached = true
-			data.ExecutionTimeMs = time.Since(start).Milliseconds()
-			return data, nil
-		}
-	}
+func (s *Service) GetRevenueByCategory(ctx context.Context, startDate, endDate time.Time, currency string) (*RevenueByCategoryResponse, error) {
+	cacheKey := reportKey("revenue_category", startDate, endDate, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, currency), func(ctx context.Context) (*RevenueByCategoryResponse, error) {
+		return s.computeRevenueByCategory(ctx, startDate, endDate, currency)
+	})
+}
 
+func (s *Service) computeRevenueByCategory(ctx context.Context, startDate, endDate time.Time, currency string) (*RevenueByCategoryResponse, error) {
 	// Execute stored procedure
-	query := fmt.Sprintf(`SELECT * FROM "%s".sp_revenue_by_category($1, $2)`, s.schema)
-	rows, err := s.db.Query(ctx, query, startDate, endDate)
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_revenue_by_category($1, $2, $3)`, s.schema)
+	rows, err := s.db.Query(ctx, query, startDate, endDate, reportingCurrency(currency))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
 	}
@@ -151,35 +175,34 @@ func (s *Service) GetRevenueByCategory(ctx context.Context, startDate, endDate t
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	executionTime := time.Since(start)
 	response := &RevenueByCategoryResponse{
-		Data:            results,
-		ExecutionTimeMs: executionTime.Milliseconds(),
-		Cached:          false,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Currency:  currency,
+		Data:      results,
 	}
 
-	// Cache the result
-	s.cache.Set(cacheKey, response)
-
 	return response, nil
 }
 
-func (s *Service) GetTopCustomers(ctx context.Context, startDate, endDate time.Time, limit int) (*TopCustomersResponse, error) {
-	start := time.Now()
-	cacheKey := fmt.Sprintf("top_customers:%s:%s:%d", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), limit)
-
-	// Check cache
-	if cached, found := s.cache.Get(cacheKey); found {
-		if data, ok := cached.(*TopCustomersResponse); ok {
-			data.Cached = true
-			data.ExecutionTimeMs = time.Since(start).Milliseconds()
-			return data, nil
-		}
-	}
+// GetTopCustomers returns the limit customers with the most revenue, or every
+// customer with revenue when limit is 0.
+func (s *Service) GetTopCustomers(ctx context.Context, startDate, endDate time.Time, limit int, currency string) (*TopCustomersResponse, error) {
+	cacheKey := reportKey("top_customers", startDate, endDate, limit, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, currency), func(ctx context.Context) (*TopCustomersResponse, error) {
+		return s.computeTopCustomers(ctx, startDate, endDate, limit, currency)
+	})
+}
 
+func (s *Service) computeTopCustomers(ctx context.Context, startDate, endDate time.Time, limit int, currency string) (*TopCustomersResponse, error) {
 	// Execute stored procedure
-	query := fmt.Sprintf(`SELECT * FROM "%s".sp_top_customers($1, $2, $3)`, s.schema)
-	rows, err := s.db.Query(ctx, query, startDate, endDate, limit)
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_top_customers($1, $2, $3, $4)`, s.schema)
+	// A NULL limit_count is no limit
+	var limitCount *int
+	if limit > 0 {
+		limitCount = &limit
+	}
+	rows, err := s.db.Query(ctx, query, startDate, endDate, limitCount, reportingCurrency(currency))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
 	}
@@ -203,21 +226,107 @@ func (s *Service) GetTopCustomers(ctx context.Context, startDate, endDate time.T
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	executionTime := time.Since(start)
 	response := &TopCustomersResponse{
-		Data:            results,
-		ExecutionTimeMs: executionTime.Milliseconds(),
-		Cached:          false,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Currency:  currency,
+		Data:      results,
 	}
 
-	// Cache the result
-	s.cache.Set(cacheKey, response)
+	return response, nil
+}
+
+// DefaultPaymentTermsDays is the number of days after a sale before it is past due.
+const DefaultPaymentTermsDays = 30
+
+type AgingBuckets struct {
+	Current    money.Amount `json:"current"`
+	Days1To30  money.Amount `json:"days_1_30"`
+	Days31To60 money.Amount `json:"days_31_60"`
+	Days61To90 money.Amount `json:"days_61_90"`
+	Over90     money.Amount `json:"days_over_90"`
+	TotalOpen  money.Amount `json:"total_open"`
+}
+
+func (b *AgingBuckets) add(other AgingBuckets) {
+	b.Current += other.Current
+	b.Days1To30 += other.Days1To30
+	b.Days31To60 += other.Days31To60
+	b.Days61To90 += other.Days61To90
+	b.Over90 += other.Over90
+	b.TotalOpen += other.TotalOpen
+}
+
+type ARAgingRow struct {
+	CustomerID   string `json:"customer_id"`
+	CustomerName string `json:"customer_name"`
+	AgingBuckets
+	UnappliedCredit money.Amount `json:"unapplied_credit"`
+}
+
+type ARAgingTotals struct {
+	AgingBuckets
+	UnappliedCredit money.Amount `json:"unapplied_credit"`
+}
+
+type ARAgingResponse struct {
+	AsOf      string        `json:"as_of"`
+	TermsDays int           `json:"terms_days"`
+	Currency  string        `json:"currency,omitempty"`
+	Data      []ARAgingRow  `json:"data"`
+	Totals    ARAgingTotals `json:"totals"`
+	ReportMeta
+}
+
+// GetARAging matches each customer's receipts against their sales, oldest
+// first, and buckets what remains open by days past due as of asOf.
+func (s *Service) GetARAging(ctx context.Context, asOf time.Time, termsDays int, currency string) (*ARAgingResponse, error) {
+	cacheKey := reportKey("ar_aging", time.Time{}, asOf, termsDays, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(asOf, currency), func(ctx context.Context) (*ARAgingResponse, error) {
+		return s.computeARAging(ctx, asOf, termsDays, currency)
+	})
+}
+
+func (s *Service) computeARAging(ctx context.Context, asOf time.Time, termsDays int, currency string) (*ARAgingResponse, error) {
+	// Execute stored procedure
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_ar_aging($1, $2, $3)`, s.schema)
+	rows, err := s.db.Query(ctx, query, asOf, termsDays, reportingCurrency(currency))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	results := []ARAgingRow{}
+	var totals ARAgingTotals
+	for rows.Next() {
+		var row ARAgingRow
+		err := rows.Scan(&row.CustomerID, &row.CustomerName, &row.Current, &row.Days1To30, &row.Days31To60,
+			&row.Days61To90, &row.Over90, &row.TotalOpen, &row.UnappliedCredit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		totals.add(row.AgingBuckets)
+		totals.UnappliedCredit += row.UnappliedCredit
+		results = append(results, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	response := &ARAgingResponse{
+		AsOf:      asOf.Format("2006-01-02"),
+		TermsDays: termsDays,
+		Currency:  currency,
+		Data:      results,
+		Totals:    totals,
+	}
 
 	return response, nil
 }
 
 // GetMultipleReportsParallel runs multiple reports in parallel using goroutines
-func (s *Service) GetMultipleReportsParallel(ctx context.Context, startDate, endDate time.Time) (map[string]interface{}, error) {
+func (s *Service) GetMultipleReportsParallel(ctx context.Context, startDate, endDate time.Time, currency string) (map[string]interface{}, error) {
 	start := time.Now()
 	type result struct {
 		name string
@@ -229,17 +338,17 @@ func (s *Service) GetMultipleReportsParallel(ctx context.Context, startDate, end
 
 	// Run reports in parallel
 	go func() {
-		data, err := s.GetProfitLoss(ctx, startDate, endDate)
+		data, err := s.GetProfitLoss(ctx, startDate, endDate, currency)
 		resultsChan <- result{name: "profit_loss", data: data, err: err}
 	}()
 
 	go func() {
-		data, err := s.GetRevenueByCategory(ctx, startDate, endDate)
+		data, err := s.GetRevenueByCategory(ctx, startDate, endDate, currency)
 		resultsChan <- result{name: "revenue_category", data: data, err: err}
 	}()
 
 	go func() {
-		data, err := s.GetTopCustomers(ctx, startDate, endDate, 10)
+		data, err := s.GetTopCustomers(ctx, startDate, endDate, 10, currency)
 		resultsChan <- result{name: "top_customers", data: data, err: err}
 	}()
 
@@ -254,8 +363,573 @@ func (s *Service) GetMultipleReportsParallel(ctx context.Context, startDate, end
 	}
 
 	executionTime := time.Since(start)
+	results["start_date"] = startDate.Format("2006-01-02")
+	results["end_date"] = endDate.Format("2006-01-02")
 	results["total_execution_time_ms"] = executionTime.Milliseconds()
 
 	return results, nil
 }
 
+type BalanceSheetRow struct {
+	AccountID   string       `json:"account_id"`
+	AccountCode string       `json:"account_code"`
+	AccountName string       `json:"account_name"`
+	AccountType string       `json:"account_type"`
+	Balance     money.Amount `json:"balance"`
+}
+
+type BalanceSheetSection struct {
+	Accounts []BalanceSheetRow `json:"accounts"`
+	Total    money.Amount      `json:"total"`
+}
+
+type BalanceSheetCheck struct {
+	TotalAssets               money.Amount `json:"total_assets"`
+	TotalLiabilitiesAndEquity money.Amount `json:"total_liabilities_and_equity"`
+	Difference                money.Amount `json:"difference"`
+	Balanced                  bool         `json:"balanced"`
+}
+
+type BalanceSheetResponse struct {
+	AsOf             string              `json:"as_of"`
+	Currency         string              `json:"currency,omitempty"`
+	Assets           BalanceSheetSection `json:"assets"`
+	Liabilities      BalanceSheetSection `json:"liabilities"`
+	Equity           BalanceSheetSection `json:"equity"`
+	RetainedEarnings money.Amount        `json:"retained_earnings"`
+	NetIncome        money.Amount        `json:"net_income"`
+	Check            BalanceSheetCheck   `json:"check"`
+	FromSnapshot     bool                `json:"from_snapshot"`
+	ReportMeta
+}
+
+// GetBalanceSheet builds a balance sheet from account balances up to asOf.
+// Revenue and expense accounts are not closed into equity, so their net is
+// reported inside the equity section: as retained earnings up to the last
+// closed period and as current-period net income after it. Balance sheets
+// dated on a closed period end are read from the close snapshot.
+func (s *Service) GetBalanceSheet(ctx context.Context, asOf time.Time, currency string) (*BalanceSheetResponse, error) {
+	cacheKey := reportKey("balance_sheet", time.Time{}, asOf, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(asOf, currency), func(ctx context.Context) (*BalanceSheetResponse, error) {
+		return s.computeBalanceSheet(ctx, asOf, currency)
+	})
+}
+
+func (s *Service) computeBalanceSheet(ctx context.Context, asOf time.Time, currency string) (*BalanceSheetResponse, error) {
+	closed, err := s.latestClosedPeriod(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Snapshots and retained earnings are kept in the base currency, so
+	// converted balance sheets recompute retained earnings at historical rates
+	if closed != nil && currency != "" {
+		closed.RetainedEarnings, err = s.convertedRetainedEarnings(ctx, closed.PeriodEnd, currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Serve period-end balance sheets from the close snapshot
+	var rows pgx.Rows
+	fromSnapshot := closed != nil && closed.PeriodEnd.Equal(asOf) && currency == ""
+	if fromSnapshot {
+		query := fmt.Sprintf(`
+			SELECT a.id, a.code, a.name, a.type,
+				CASE WHEN a.type IN ('asset', 'expense') THEN pb.closing_balance ELSE -pb.closing_balance END
+			FROM "%s".period_balances pb
+			INNER JOIN "%s".accounts a ON a.id = pb.account_id
+			WHERE pb.period_id = $1
+			ORDER BY a.code`, s.schema, s.schema)
+		rows, err = s.db.Query(ctx, query, closed.ID)
+	} else {
+		// Execute stored procedure
+		query := fmt.Sprintf(`SELECT * FROM "%s".sp_balance_sheet($1, $2)`, s.schema)
+		rows, err = s.db.Query(ctx, query, asOf, reportingCurrency(currency))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	response := &BalanceSheetResponse{
+		AsOf:         asOf.Format("2006-01-02"),
+		Currency:     currency,
+		FromSnapshot: fromSnapshot,
+		Assets:       BalanceSheetSection{Accounts: []BalanceSheetRow{}},
+		Liabilities:  BalanceSheetSection{Accounts: []BalanceSheetRow{}},
+		Equity:       BalanceSheetSection{Accounts: []BalanceSheetRow{}},
+	}
+	for rows.Next() {
+		var row BalanceSheetRow
+		err := rows.Scan(&row.AccountID, &row.AccountCode, &row.AccountName, &row.AccountType, &row.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		switch row.AccountType {
+		case "asset":
+			response.Assets.Accounts = append(response.Assets.Accounts, row)
+			response.Assets.Total += row.Balance
+		case "liability":
+			response.Liabilities.Accounts = append(response.Liabilities.Accounts, row)
+			response.Liabilities.Total += row.Balance
+		case "equity":
+			response.Equity.Accounts = append(response.Equity.Accounts, row)
+			response.Equity.Total += row.Balance
+		case "revenue":
+			response.NetIncome += row.Balance
+		case "expense":
+			response.NetIncome -= row.Balance
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	// Earnings up to the last close are carried forward as retained earnings;
+	// the remainder is current-period net income. Both are folded into equity.
+	if closed != nil {
+		response.RetainedEarnings = closed.RetainedEarnings
+		response.NetIncome -= closed.RetainedEarnings
+		response.Equity.Accounts = append(response.Equity.Accounts, BalanceSheetRow{
+			AccountName: "Retained Earnings",
+			AccountType: "equity",
+			Balance:     response.RetainedEarnings,
+		})
+	}
+	response.Equity.Accounts = append(response.Equity.Accounts, BalanceSheetRow{
+		AccountName: "Current Period Net Income",
+		AccountType: "equity",
+		Balance:     response.NetIncome,
+	})
+	response.Equity.Total += response.RetainedEarnings + response.NetIncome
+
+	response.Check = balanceSheetCheck(response.Assets.Total, response.Liabilities.Total+response.Equity.Total)
+
+	return response, nil
+}
+
+// balanceSheetCheck compares both sides of the accounting equation to the cent.
+func balanceSheetCheck(assets, liabilitiesAndEquity money.Amount) BalanceSheetCheck {
+	difference := assets - liabilitiesAndEquity
+	return BalanceSheetCheck{
+		TotalAssets:               assets,
+		TotalLiabilitiesAndEquity: liabilitiesAndEquity,
+		Difference:                difference,
+		Balanced:                  difference == 0,
+	}
+}
+
+type TrialBalanceRow struct {
+	AccountID      string       `json:"account_id"`
+	AccountCode    string       `json:"account_code"`
+	AccountName    string       `json:"account_name"`
+	AccountType    string       `json:"account_type"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+	PeriodDebit    money.Amount `json:"period_debit"`
+	PeriodCredit   money.Amount `json:"period_credit"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+}
+
+type TrialBalanceTotals struct {
+	OpeningBalance money.Amount `json:"opening_balance"`
+	PeriodDebit    money.Amount `json:"period_debit"`
+	PeriodCredit   money.Amount `json:"period_credit"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+}
+
+type TrialBalanceResponse struct {
+	StartDate    string             `json:"start_date"`
+	EndDate      string             `json:"end_date"`
+	Currency     string             `json:"currency,omitempty"`
+	Data         []TrialBalanceRow  `json:"data"`
+	Totals       TrialBalanceTotals `json:"totals"`
+	Difference   money.Amount       `json:"difference"`
+	Balanced     bool               `json:"balanced"`
+	FromSnapshot bool               `json:"from_snapshot"`
+	ReportMeta
+}
+
+// GetTrialBalance lists opening, period and closing balances per account.
+// The range is flagged as unbalanced when period debits and credits differ.
+// A range that is exactly one closed month is read from the close snapshot.
+func (s *Service) GetTrialBalance(ctx context.Context, startDate, endDate time.Time, currency string) (*TrialBalanceResponse, error) {
+	// Opening balances depend on every earlier transaction
+	cacheKey := reportKey("trial_balance", time.Time{}, endDate, startDate.Format("2006-01-02"), currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, currency), func(ctx context.Context) (*TrialBalanceResponse, error) {
+		return s.computeTrialBalance(ctx, startDate, endDate, currency)
+	})
+}
+
+func (s *Service) computeTrialBalance(ctx context.Context, startDate, endDate time.Time, currency string) (*TrialBalanceResponse, error) {
+	// Closed months are served from the close snapshot, which is kept in the
+	// base currency
+	var closed *closedPeriod
+	var err error
+	if currency == "" {
+		closed, err = s.closedPeriodFor(ctx, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rows pgx.Rows
+	if closed != nil {
+		query := fmt.Sprintf(`
+			SELECT a.id, a.code, a.name, a.type, pb.opening_balance, pb.period_debit, pb.period_credit, pb.closing_balance
+			FROM "%s".period_balances pb
+			INNER JOIN "%s".accounts a ON a.id = pb.account_id
+			WHERE pb.period_id = $1
+			ORDER BY a.code`, s.schema, s.schema)
+		rows, err = s.db.Query(ctx, query, closed.ID)
+	} else {
+		// Execute stored procedure
+		query := fmt.Sprintf(`SELECT * FROM "%s".sp_trial_balance($1, $2, $3)`, s.schema)
+		rows, err = s.db.Query(ctx, query, startDate, endDate, reportingCurrency(currency))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	results := []TrialBalanceRow{}
+	var totals TrialBalanceTotals
+	for rows.Next() {
+		var row TrialBalanceRow
+		err := rows.Scan(&row.AccountID, &row.AccountCode, &row.AccountName, &row.AccountType,
+			&row.OpeningBalance, &row.PeriodDebit, &row.PeriodCredit, &row.ClosingBalance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		totals.OpeningBalance += row.OpeningBalance
+		totals.PeriodDebit += row.PeriodDebit
+		totals.PeriodCredit += row.PeriodCredit
+		totals.ClosingBalance += row.ClosingBalance
+		results = append(results, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	difference := totals.PeriodDebit - totals.PeriodCredit
+	response := &TrialBalanceResponse{
+		StartDate:    startDate.Format("2006-01-02"),
+		EndDate:      endDate.Format("2006-01-02"),
+		Currency:     currency,
+		Data:         results,
+		Totals:       totals,
+		Difference:   difference,
+		Balanced:     difference == 0,
+		FromSnapshot: closed != nil,
+	}
+
+	return response, nil
+}
+
+// ErrAccountNotFound is returned when a report references an unknown account code.
+var ErrAccountNotFound = errors.New("account not found")
+
+const (
+	defaultLedgerPageSize = 100
+	maxLedgerPageSize     = 1000
+)
+
+type LedgerAccount struct {
+	AccountID      string       `json:"account_id"`
+	AccountCode    string       `json:"account_code"`
+	AccountName    string       `json:"account_name"`
+	AccountType    string       `json:"account_type"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+	PeriodDebit    money.Amount `json:"period_debit"`
+	PeriodCredit   money.Amount `json:"period_credit"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+}
+
+type LedgerLine struct {
+	ItemID          string       `json:"item_id"`
+	TransactionID   string       `json:"transaction_id"`
+	TransactionDate string       `json:"transaction_date"`
+	ReferenceNumber string       `json:"reference_number"`
+	Description     string       `json:"description"`
+	Debit           money.Amount `json:"debit"`
+	Credit          money.Amount `json:"credit"`
+	RunningBalance  money.Amount `json:"running_balance"`
+	ReversalOf      string       `json:"reversal_of,omitempty"`
+	ReversedBy      string       `json:"reversed_by,omitempty"`
+}
+
+type LedgerResponse struct {
+	Account    LedgerAccount `json:"account"`
+	StartDate  string        `json:"start_date"`
+	EndDate    string        `json:"end_date"`
+	Currency   string        `json:"currency,omitempty"`
+	Lines      []LedgerLine  `json:"lines"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	HasMore    bool          `json:"has_more"`
+	ReportMeta
+}
+
+// GetLedger returns one page of an account's general ledger with a running balance.
+// cursor is the opaque next_cursor from a previous page, or empty for the first page.
+func (s *Service) GetLedger(ctx context.Context, accountCode string, startDate, endDate time.Time, cursor string, limit int, currency string) (*LedgerResponse, error) {
+	// Opening and running balances depend on every earlier transaction
+	cacheKey := reportKey("ledger", time.Time{}, endDate, startDate.Format("2006-01-02"), accountCode, cursor, limit, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, currency), func(ctx context.Context) (*LedgerResponse, error) {
+		return s.computeLedger(ctx, accountCode, startDate, endDate, cursor, limit, currency)
+	})
+}
+
+func (s *Service) computeLedger(ctx context.Context, accountCode string, startDate, endDate time.Time, cursor string, limit int, currency string) (*LedgerResponse, error) {
+	after, err := decodeLedgerCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &LedgerResponse{
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Currency:  currency,
+		Lines:     []LedgerLine{},
+	}
+
+	// Account header with totals for the whole range
+	summaryQuery := fmt.Sprintf(`SELECT * FROM "%s".sp_account_ledger_summary($1, $2, $3, $4)`, s.schema)
+	acc := &response.Account
+	err = s.db.QueryRow(ctx, summaryQuery, accountCode, startDate, endDate, reportingCurrency(currency)).Scan(
+		&acc.AccountID, &acc.AccountCode, &acc.AccountName, &acc.AccountType,
+		&acc.OpeningBalance, &acc.PeriodDebit, &acc.PeriodCredit, &acc.ClosingBalance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+
+	// Fetch one extra line to know whether another page follows
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_general_ledger($1, $2, $3, $4, $5, $6, $7)`, s.schema)
+	rows, err := s.db.Query(ctx, query, accountCode, startDate, endDate, after.date, after.itemID, limit+1, reportingCurrency(currency))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line LedgerLine
+		var transactionDate time.Time
+		var reference, description, reversalOf, reversedBy sql.NullString
+		err := rows.Scan(&line.ItemID, &line.TransactionID, &transactionDate, &reference, &description,
+			&line.Debit, &line.Credit, &line.RunningBalance, &reversalOf, &reversedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		line.TransactionDate = transactionDate.Format("2006-01-02")
+		line.ReferenceNumber = reference.String
+		line.Description = description.String
+		line.ReversalOf = reversalOf.String
+		line.ReversedBy = reversedBy.String
+		response.Lines = append(response.Lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(response.Lines) > limit {
+		response.Lines = response.Lines[:limit]
+		last := response.Lines[limit-1]
+		response.HasMore = true
+		response.NextCursor = encodeLedgerCursor(last.TransactionDate, last.ItemID)
+	}
+
+	return response, nil
+}
+
+// ledgerCursor is the keyset position of the last line on a ledger page.
+// Both fields are nil for the first page.
+type ledgerCursor struct {
+	date   *time.Time
+	itemID *uuid.UUID
+}
+
+// ErrInvalidCursor is returned when a ledger cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func encodeLedgerCursor(transactionDate, itemID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(transactionDate + "|" + itemID))
+}
+
+func decodeLedgerCursor(cursor string) (ledgerCursor, error) {
+	if cursor == "" {
+		return ledgerCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ledgerCursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return ledgerCursor{}, ErrInvalidCursor
+	}
+
+	date, err := time.Parse("2006-01-02", parts[0])
+	if err != nil {
+		return ledgerCursor{}, ErrInvalidCursor
+	}
+	itemID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return ledgerCursor{}, ErrInvalidCursor
+	}
+
+	return ledgerCursor{date: &date, itemID: &itemID}, nil
+}
+
+// DefaultCashAccountCode is the seeded chart-of-accounts code for Cash.
+const DefaultCashAccountCode = "1000"
+
+type CashFlowTotals struct {
+	Operating money.Amount `json:"operating"`
+	Investing money.Amount `json:"investing"`
+	Financing money.Amount `json:"financing"`
+	NetChange money.Amount `json:"net_change"`
+}
+
+func (t *CashFlowTotals) add(activity string, amount money.Amount) {
+	switch activity {
+	case "operating":
+		t.Operating += amount
+	case "investing":
+		t.Investing += amount
+	case "financing":
+		t.Financing += amount
+	}
+	t.NetChange += amount
+}
+
+type CashFlowDirectRow struct {
+	Activity        string       `json:"activity"`
+	TransactionType string       `json:"transaction_type"`
+	Receipts        money.Amount `json:"receipts"`
+	Payments        money.Amount `json:"payments"`
+	Net             money.Amount `json:"net"`
+}
+
+type CashFlowDirect struct {
+	Rows   []CashFlowDirectRow `json:"rows"`
+	Totals CashFlowTotals      `json:"totals"`
+}
+
+type CashFlowIndirectRow struct {
+	Activity    string       `json:"activity"`
+	AccountCode string       `json:"account_code"`
+	AccountName string       `json:"account_name"`
+	AccountType string       `json:"account_type"`
+	Amount      money.Amount `json:"amount"`
+}
+
+type CashFlowIndirect struct {
+	NetIncome   money.Amount          `json:"net_income"`
+	Adjustments []CashFlowIndirectRow `json:"adjustments"`
+	Totals      CashFlowTotals        `json:"totals"`
+}
+
+type CashFlowResponse struct {
+	StartDate       string           `json:"start_date"`
+	EndDate         string           `json:"end_date"`
+	Currency        string           `json:"currency,omitempty"`
+	CashAccountCode string           `json:"cash_account_code"`
+	OpeningCash     money.Amount     `json:"opening_cash"`
+	ClosingCash     money.Amount     `json:"closing_cash"`
+	Direct          CashFlowDirect   `json:"direct"`
+	Indirect        CashFlowIndirect `json:"indirect"`
+	Reconciled      bool             `json:"reconciled"`
+	ReportMeta
+}
+
+// GetCashFlow builds direct and indirect cash flow statements for the cash
+// account. Both views are reconciled against the cash account's ledger balance.
+func (s *Service) GetCashFlow(ctx context.Context, startDate, endDate time.Time, cashAccountCode string, currency string) (*CashFlowResponse, error) {
+	// Opening cash depends on every earlier transaction
+	cacheKey := reportKey("cash_flow", time.Time{}, endDate, startDate.Format("2006-01-02"), cashAccountCode, currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, currency), func(ctx context.Context) (*CashFlowResponse, error) {
+		return s.computeCashFlow(ctx, startDate, endDate, cashAccountCode, currency)
+	})
+}
+
+func (s *Service) computeCashFlow(ctx context.Context, startDate, endDate time.Time, cashAccountCode string, currency string) (*CashFlowResponse, error) {
+	response := &CashFlowResponse{
+		StartDate:       startDate.Format("2006-01-02"),
+		EndDate:         endDate.Format("2006-01-02"),
+		Currency:        currency,
+		CashAccountCode: cashAccountCode,
+		Direct:          CashFlowDirect{Rows: []CashFlowDirectRow{}},
+		Indirect:        CashFlowIndirect{Adjustments: []CashFlowIndirectRow{}},
+	}
+
+	// Opening and closing cash come from the ledger itself
+	summaryQuery := fmt.Sprintf(`SELECT opening_balance, closing_balance FROM "%s".sp_account_ledger_summary($1, $2, $3, $4)`, s.schema)
+	err := s.db.QueryRow(ctx, summaryQuery, cashAccountCode, startDate, endDate, reportingCurrency(currency)).Scan(&response.OpeningCash, &response.ClosingCash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+
+	// Direct method: receipts and payments by activity and transaction type
+	directQuery := fmt.Sprintf(`SELECT * FROM "%s".sp_cash_flow_direct($1, $2, $3, $4)`, s.schema)
+	rows, err := s.db.Query(ctx, directQuery, startDate, endDate, cashAccountCode, reportingCurrency(currency))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row CashFlowDirectRow
+		if err := rows.Scan(&row.Activity, &row.TransactionType, &row.Receipts, &row.Payments); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row.Net = row.Receipts - row.Payments
+		response.Direct.Totals.add(row.Activity, row.Net)
+		response.Direct.Rows = append(response.Direct.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	// Indirect method: net income adjusted by balance sheet movements
+	indirectQuery := fmt.Sprintf(`SELECT * FROM "%s".sp_cash_flow_indirect($1, $2, $3, $4)`, s.schema)
+	rows, err = s.db.Query(ctx, indirectQuery, startDate, endDate, cashAccountCode, reportingCurrency(currency))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row CashFlowIndirectRow
+		if err := rows.Scan(&row.Activity, &row.AccountCode, &row.AccountName, &row.AccountType, &row.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		response.Indirect.Totals.add(row.Activity, row.Amount)
+		if row.AccountType == "revenue" || row.AccountType == "expense" {
+			response.Indirect.NetIncome += row.Amount
+			continue
+		}
+		response.Indirect.Adjustments = append(response.Indirect.Adjustments, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	ledgerChange := response.ClosingCash - response.OpeningCash
+	response.Reconciled = response.Direct.Totals.NetChange == ledgerChange &&
+		response.Indirect.Totals.NetChange == ledgerChange
+
+	return response, nil
+}
@@ -0,0 +1,135 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+// ErrInvalidTimeSeriesOption is returned for unsupported granularity, metric or split values.
+var ErrInvalidTimeSeriesOption = errors.New("invalid time series option")
+
+var (
+	timeSeriesGranularities = []string{"day", "week", "month", "quarter", "year"}
+	timeSeriesMetrics       = []string{"revenue", "expense", "net", "transaction_count"}
+	timeSeriesSplits        = []string{"category", "transaction_type"}
+)
+
+// TimeSeriesOptions selects how activity is bucketed. An empty Split returns a
+// single "total" series; an empty Currency reports in each ledger's base
+// currency.
+type TimeSeriesOptions struct {
+	Granularity string
+	Metric      string
+	Split       string
+	Currency    string
+}
+
+func (o TimeSeriesOptions) validate() error {
+	if !contains(timeSeriesGranularities, o.Granularity) {
+		return fmt.Errorf("%w: granularity must be one of %v", ErrInvalidTimeSeriesOption, timeSeriesGranularities)
+	}
+	if !contains(timeSeriesMetrics, o.Metric) {
+		return fmt.Errorf("%w: metric must be one of %v", ErrInvalidTimeSeriesOption, timeSeriesMetrics)
+	}
+	if o.Split != "" && !contains(timeSeriesSplits, o.Split) {
+		return fmt.Errorf("%w: split must be one of %v", ErrInvalidTimeSeriesOption, timeSeriesSplits)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type TimeSeriesPoint struct {
+	BucketStart string       `json:"bucket_start"`
+	BucketEnd   string       `json:"bucket_end"`
+	Value       money.Amount `json:"value"`
+}
+
+type TimeSeries struct {
+	Name   string            `json:"name"`
+	Points []TimeSeriesPoint `json:"points"`
+}
+
+type TimeSeriesResponse struct {
+	StartDate   string       `json:"start_date"`
+	EndDate     string       `json:"end_date"`
+	Granularity string       `json:"granularity"`
+	Metric      string       `json:"metric"`
+	Split       string       `json:"split,omitempty"`
+	Currency    string       `json:"currency,omitempty"`
+	Series      []TimeSeries `json:"series"`
+	ReportMeta
+}
+
+// GetTimeSeries returns one zero-filled series per split group, with buckets
+// covering startDate to endDate at the requested granularity.
+func (s *Service) GetTimeSeries(ctx context.Context, startDate, endDate time.Time, opts TimeSeriesOptions) (*TimeSeriesResponse, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	cacheKey := reportKey("timeseries", startDate, endDate, opts.Granularity, opts.Metric, opts.Split, opts.Currency)
+	return cachedReport(ctx, s, cacheKey, lockedThrough(endDate, opts.Currency), func(ctx context.Context) (*TimeSeriesResponse, error) {
+		return s.computeTimeSeries(ctx, startDate, endDate, opts)
+	})
+}
+
+func (s *Service) computeTimeSeries(ctx context.Context, startDate, endDate time.Time, opts TimeSeriesOptions) (*TimeSeriesResponse, error) {
+	var split *string
+	if opts.Split != "" {
+		split = &opts.Split
+	}
+
+	query := fmt.Sprintf(`SELECT * FROM "%s".sp_timeseries($1, $2, $3, $4, $5, $6)`, s.schema)
+	rows, err := s.db.Query(ctx, query, startDate, endDate, opts.Granularity, opts.Metric, split, reportingCurrency(opts.Currency))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute stored procedure: %w", err)
+	}
+	defer rows.Close()
+
+	// Rows arrive ordered by series, then bucket
+	series := []TimeSeries{}
+	for rows.Next() {
+		var name string
+		var bucketStart, bucketEnd time.Time
+		var point TimeSeriesPoint
+		if err := rows.Scan(&name, &bucketStart, &bucketEnd, &point.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		point.BucketStart = bucketStart.Format("2006-01-02")
+		point.BucketEnd = bucketEnd.Format("2006-01-02")
+
+		if len(series) == 0 || series[len(series)-1].Name != name {
+			series = append(series, TimeSeries{Name: name})
+		}
+		last := &series[len(series)-1]
+		last.Points = append(last.Points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	response := &TimeSeriesResponse{
+		StartDate:   startDate.Format("2006-01-02"),
+		EndDate:     endDate.Format("2006-01-02"),
+		Granularity: opts.Granularity,
+		Metric:      opts.Metric,
+		Split:       opts.Split,
+		Currency:    opts.Currency,
+		Series:      series,
+	}
+
+	return response, nil
+}
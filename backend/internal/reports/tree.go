@@ -0,0 +1,317 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"financial-reporting-system/internal/money"
+)
+
+var (
+	// ErrAccountCycle is returned when accounts.parent_id forms a loop.
+	ErrAccountCycle = errors.New("account hierarchy contains a cycle")
+	// ErrOrphanedAccount is returned when accounts.parent_id points at a missing account.
+	ErrOrphanedAccount = errors.New("account hierarchy references a missing parent")
+)
+
+// rollup is implemented by the per-account amounts a tree can aggregate.
+type rollup[T any] interface {
+	plus(other T) T
+}
+
+// AccountBalance is the amount carried by balance sheet tree nodes.
+type AccountBalance struct {
+	Balance money.Amount `json:"balance"`
+}
+
+func (a AccountBalance) plus(other AccountBalance) AccountBalance {
+	return AccountBalance{Balance: a.Balance + other.Balance}
+}
+
+func (t TrialBalanceTotals) plus(other TrialBalanceTotals) TrialBalanceTotals {
+	return TrialBalanceTotals{
+		OpeningBalance: t.OpeningBalance + other.OpeningBalance,
+		PeriodDebit:    t.PeriodDebit + other.PeriodDebit,
+		PeriodCredit:   t.PeriodCredit + other.PeriodCredit,
+		ClosingBalance: t.ClosingBalance + other.ClosingBalance,
+	}
+}
+
+// AccountNode is one account in a chart-of-accounts tree. Own holds the
+// account's own amounts and RolledUp adds those of every descendant, including
+// descendants hidden because the tree was collapsed to a maximum depth.
+type AccountNode[T rollup[T]] struct {
+	AccountID   string            `json:"account_id"`
+	AccountCode string            `json:"account_code"`
+	AccountName string            `json:"account_name"`
+	AccountType string            `json:"account_type"`
+	Depth       int               `json:"depth"`
+	Own         T                 `json:"own"`
+	RolledUp    T                 `json:"rolled_up"`
+	Children    []*AccountNode[T] `json:"children,omitempty"`
+}
+
+// chartAccount is a row of the chart of accounts.
+type chartAccount struct {
+	ID       string
+	Code     string
+	Name     string
+	Type     string
+	ParentID string
+}
+
+func (s *Service) getChartOfAccounts(ctx context.Context) ([]chartAccount, error) {
+	query := fmt.Sprintf(`SELECT id, code, name, type, parent_id FROM "%s".accounts ORDER BY code`, s.schema)
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart of accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []chartAccount
+	for rows.Next() {
+		var account chartAccount
+		var parentID sql.NullString
+		if err := rows.Scan(&account.ID, &account.Code, &account.Name, &account.Type, &parentID); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		account.ParentID = parentID.String
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// buildAccountTree arranges accounts into a forest using parent_id and rolls
+// amounts up from the leaves. Accounts missing from amounts contribute zero.
+// A maxDepth of 1 or more collapses nodes below that level into their
+// ancestor; 0 keeps the full tree.
+func buildAccountTree[T rollup[T]](accounts []chartAccount, amounts map[string]T, maxDepth int) ([]*AccountNode[T], error) {
+	byID := make(map[string]chartAccount, len(accounts))
+	for _, account := range accounts {
+		byID[account.ID] = account
+	}
+
+	children := make(map[string][]chartAccount)
+	var roots []chartAccount
+	for _, account := range accounts {
+		if account.ParentID == "" {
+			roots = append(roots, account)
+			continue
+		}
+		if _, ok := byID[account.ParentID]; !ok {
+			return nil, fmt.Errorf("%w: account %s has parent %s", ErrOrphanedAccount, account.Code, account.ParentID)
+		}
+		children[account.ParentID] = append(children[account.ParentID], account)
+	}
+
+	if err := checkAccountCycles(accounts, byID); err != nil {
+		return nil, err
+	}
+
+	var build func(account chartAccount, depth int) *AccountNode[T]
+	build = func(account chartAccount, depth int) *AccountNode[T] {
+		node := &AccountNode[T]{
+			AccountID:   account.ID,
+			AccountCode: account.Code,
+			AccountName: account.Name,
+			AccountType: account.Type,
+			Depth:       depth,
+			Own:         amounts[account.ID],
+		}
+		node.RolledUp = node.Own
+
+		kids := children[account.ID]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].Code < kids[j].Code })
+		for _, kid := range kids {
+			child := build(kid, depth+1)
+			node.RolledUp = node.RolledUp.plus(child.RolledUp)
+			if maxDepth == 0 || depth+1 < maxDepth {
+				node.Children = append(node.Children, child)
+			}
+		}
+		return node
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Code < roots[j].Code })
+	tree := make([]*AccountNode[T], 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, build(root, 0))
+	}
+
+	return tree, nil
+}
+
+// checkAccountCycles walks every account up to its root and fails on the
+// first account that is reached twice along the same path.
+func checkAccountCycles(accounts []chartAccount, byID map[string]chartAccount) error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(accounts))
+
+	for _, account := range accounts {
+		var path []string
+		id := account.ID
+		for id != "" && state[id] == unvisited {
+			state[id] = visiting
+			path = append(path, id)
+			id = byID[id].ParentID
+		}
+		if id != "" && state[id] == visiting {
+			return fmt.Errorf("%w: account %s is its own ancestor", ErrAccountCycle, byID[id].Code)
+		}
+		for _, visited := range path {
+			state[visited] = done
+		}
+	}
+
+	return nil
+}
+
+type BalanceSheetTreeResponse struct {
+	AsOf             string                         `json:"as_of"`
+	Currency         string                         `json:"currency,omitempty"`
+	Depth            int                            `json:"depth"`
+	Assets           []*AccountNode[AccountBalance] `json:"assets"`
+	Liabilities      []*AccountNode[AccountBalance] `json:"liabilities"`
+	Equity           []*AccountNode[AccountBalance] `json:"equity"`
+	RetainedEarnings money.Amount                   `json:"retained_earnings"`
+	NetIncome        money.Amount                   `json:"net_income"`
+	Check            BalanceSheetCheck              `json:"check"`
+	ReportMeta
+}
+
+// GetBalanceSheetTree returns the balance sheet with each section arranged by
+// accounts.parent_id. Retained earnings and current-period net income stay
+// roots of the equity section.
+func (s *Service) GetBalanceSheetTree(ctx context.Context, asOf time.Time, depth int, currency string) (*BalanceSheetTreeResponse, error) {
+	start := time.Now()
+
+	flat, err := s.GetBalanceSheet(ctx, asOf, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.getChartOfAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts := make(map[string]AccountBalance)
+	for _, section := range []BalanceSheetSection{flat.Assets, flat.Liabilities, flat.Equity} {
+		for _, row := range section.Accounts {
+			if row.AccountID != "" {
+				amounts[row.AccountID] = AccountBalance{Balance: row.Balance}
+			}
+		}
+	}
+
+	tree, err := buildAccountTree(accounts, amounts, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &BalanceSheetTreeResponse{
+		AsOf:             flat.AsOf,
+		Currency:         flat.Currency,
+		Depth:            depth,
+		Assets:           []*AccountNode[AccountBalance]{},
+		Liabilities:      []*AccountNode[AccountBalance]{},
+		Equity:           []*AccountNode[AccountBalance]{},
+		RetainedEarnings: flat.RetainedEarnings,
+		NetIncome:        flat.NetIncome,
+		Check:            flat.Check,
+	}
+	for _, node := range tree {
+		switch node.AccountType {
+		case "asset":
+			response.Assets = append(response.Assets, node)
+		case "liability":
+			response.Liabilities = append(response.Liabilities, node)
+		case "equity":
+			response.Equity = append(response.Equity, node)
+		}
+	}
+
+	// Retained earnings and net income have no account of their own
+	for _, row := range flat.Equity.Accounts {
+		if row.AccountID != "" {
+			continue
+		}
+		balance := AccountBalance{Balance: row.Balance}
+		response.Equity = append(response.Equity, &AccountNode[AccountBalance]{
+			AccountName: row.AccountName,
+			AccountType: row.AccountType,
+			Own:         balance,
+			RolledUp:    balance,
+		})
+	}
+
+	response.ReportMeta = derivedMeta(start, flat.ReportMeta)
+	return response, nil
+}
+
+type TrialBalanceTreeResponse struct {
+	StartDate  string                             `json:"start_date"`
+	EndDate    string                             `json:"end_date"`
+	Currency   string                             `json:"currency,omitempty"`
+	Depth      int                                `json:"depth"`
+	Data       []*AccountNode[TrialBalanceTotals] `json:"data"`
+	Totals     TrialBalanceTotals                 `json:"totals"`
+	Difference money.Amount                       `json:"difference"`
+	Balanced   bool                               `json:"balanced"`
+	ReportMeta
+}
+
+// GetTrialBalanceTree returns the trial balance arranged by accounts.parent_id.
+func (s *Service) GetTrialBalanceTree(ctx context.Context, startDate, endDate time.Time, depth int, currency string) (*TrialBalanceTreeResponse, error) {
+	start := time.Now()
+
+	flat, err := s.GetTrialBalance(ctx, startDate, endDate, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.getChartOfAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts := make(map[string]TrialBalanceTotals, len(flat.Data))
+	for _, row := range flat.Data {
+		amounts[row.AccountID] = TrialBalanceTotals{
+			OpeningBalance: row.OpeningBalance,
+			PeriodDebit:    row.PeriodDebit,
+			PeriodCredit:   row.PeriodCredit,
+			ClosingBalance: row.ClosingBalance,
+		}
+	}
+
+	tree, err := buildAccountTree(accounts, amounts, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TrialBalanceTreeResponse{
+		StartDate:  flat.StartDate,
+		EndDate:    flat.EndDate,
+		Currency:   flat.Currency,
+		Depth:      depth,
+		Data:       tree,
+		Totals:     flat.Totals,
+		Difference: flat.Difference,
+		Balanced:   flat.Balanced,
+		ReportMeta: derivedMeta(start, flat.ReportMeta),
+	}, nil
+}
@@ -0,0 +1,164 @@
+package savedreports
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/reports"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service  *Service
+	renderer *export.Renderer
+}
+
+func NewHandler(service *Service, renderer *export.Renderer) *Handler {
+	return &Handler{
+		service:  service,
+		renderer: renderer,
+	}
+}
+
+// ShareRequest shares a saved report with another user.
+type ShareRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// ListSavedReports handles GET /api/saved-reports
+func (h *Handler) ListSavedReports(c *gin.Context) {
+	savedReports, err := h.service.List(c.Request.Context(), c.GetString("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": savedReports})
+}
+
+// GetSavedReport handles GET /api/saved-reports/:id
+func (h *Handler) GetSavedReport(c *gin.Context) {
+	report, err := h.service.Get(c.Request.Context(), c.Param("id"), c.GetString("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateSavedReport handles POST /api/saved-reports
+func (h *Handler) CreateSavedReport(c *gin.Context) {
+	var req SavedReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.Create(c.Request.Context(), req, c.GetString("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// UpdateSavedReport handles PUT /api/saved-reports/:id
+func (h *Handler) UpdateSavedReport(c *gin.Context) {
+	var req SavedReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.Update(c.Request.Context(), c.Param("id"), req, c.GetString("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// DeleteSavedReport handles DELETE /api/saved-reports/:id
+func (h *Handler) DeleteSavedReport(c *gin.Context) {
+	if err := h.service.Delete(c.Request.Context(), c.Param("id"), c.GetString("username")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ShareSavedReport handles POST /api/saved-reports/:id/shares
+func (h *Handler) ShareSavedReport(c *gin.Context) {
+	var req ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.Share(c.Request.Context(), c.Param("id"), c.GetString("username"), req.Username)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// UnshareSavedReport handles DELETE /api/saved-reports/:id/shares/:username
+func (h *Handler) UnshareSavedReport(c *gin.Context) {
+	err := h.service.Unshare(c.Request.Context(), c.Param("id"), c.GetString("username"), c.Param("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RunSavedReport handles GET /api/saved-reports/:id/run
+func (h *Handler) RunSavedReport(c *gin.Context) {
+	run, err := h.service.Prepare(c.Request.Context(), c.Param("id"), c.GetString("username"), time.Now())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	result, err := run(c.Request.Context())
+	if err != nil {
+		reports.WriteError(c, err)
+		return
+	}
+
+	h.renderer.Respond(c, http.StatusOK, result)
+}
+
+// writeError maps saved report errors to their status, and report errors as
+// the report endpoints do.
+func writeError(c *gin.Context, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message})
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrSavedReportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDuplicateName):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	reports.WriteError(c, err)
+}
@@ -0,0 +1,347 @@
+// Package savedreports keeps per-user report definitions that can be run
+// again in one request and shared read-only with other users.
+package savedreports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"financial-reporting-system/internal/reports"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Access levels of a saved report for the requesting user.
+const (
+	AccessOwner = "owner"
+	AccessRead  = "read"
+)
+
+// ValidationError is returned when a saved report is rejected. Its message is
+// safe to show to API clients.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+var (
+	// ErrSavedReportNotFound is returned when a saved report does not exist or
+	// is neither owned by nor shared with the user.
+	ErrSavedReportNotFound = errors.New("saved report not found")
+	// ErrReadOnly is returned when a user changes a report shared with them.
+	ErrReadOnly = errors.New("saved report is shared read-only; only its owner can change it")
+	// ErrDuplicateName is returned when the owner already has a saved report with the name.
+	ErrDuplicateName = errors.New("a saved report with this name already exists")
+)
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+type Service struct {
+	db     *pgxpool.Pool
+	runner *reports.Runner
+	schema string
+}
+
+func NewService(db *pgxpool.Pool, runner *reports.Runner, schema string) *Service {
+	return &Service{
+		db:     db,
+		runner: runner,
+		schema: schema,
+	}
+}
+
+// SavedReportRequest creates or replaces a saved report. Period is any named
+// period such as last_30_days and is resolved on every run; Filters are the
+// other query parameters of the report's endpoint.
+type SavedReportRequest struct {
+	Name       string            `json:"name" binding:"required"`
+	ReportType string            `json:"report_type" binding:"required"`
+	Period     string            `json:"period"`
+	Filters    map[string]string `json:"filters"`
+	Limit      *int              `json:"limit"`
+}
+
+type SavedReport struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	ReportType string            `json:"report_type"`
+	Period     string            `json:"period,omitempty"`
+	Filters    map[string]string `json:"filters"`
+	Limit      *int              `json:"limit,omitempty"`
+	Owner      string            `json:"owner"`
+	Access     string            `json:"access"`
+	// SharedWith is only shown to the owner.
+	SharedWith []string  `json:"shared_with,omitempty"`
+	RunURL     string    `json:"run_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// selectSavedReport loads the saved reports visible to the user in $1: their
+// own and those shared with them.
+const selectSavedReport = `
+	SELECT r.id, r.name, r.report_type, COALESCE(r.period, ''), r.filters, r.row_limit, r.owner,
+		CASE WHEN r.owner = $1 THEN ARRAY(
+			SELECT s.username FROM "%[1]s".saved_report_shares s
+			WHERE s.saved_report_id = r.id
+			ORDER BY s.username
+		) ELSE '{}' END,
+		r.created_at, r.updated_at
+	FROM "%[1]s".saved_reports r
+	WHERE (r.owner = $1 OR EXISTS (
+		SELECT 1 FROM "%[1]s".saved_report_shares s
+		WHERE s.saved_report_id = r.id AND s.username = $1
+	))`
+
+// List returns the user's saved reports followed by those shared with them,
+// each ordered by name.
+func (s *Service) List(ctx context.Context, username string) ([]SavedReport, error) {
+	query := fmt.Sprintf(selectSavedReport, s.schema) + `
+	ORDER BY r.owner <> $1, r.name, r.owner`
+	rows, err := s.db.Query(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved reports: %w", err)
+	}
+	defer rows.Close()
+
+	savedReports := []SavedReport{}
+	for rows.Next() {
+		report, err := scanSavedReport(rows, username)
+		if err != nil {
+			return nil, err
+		}
+		savedReports = append(savedReports, *report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return savedReports, nil
+}
+
+func (s *Service) Get(ctx context.Context, id, username string) (*SavedReport, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrSavedReportNotFound
+	}
+
+	query := fmt.Sprintf(selectSavedReport, s.schema) + `
+	AND r.id = $2`
+	report, err := scanSavedReport(s.db.QueryRow(ctx, query, username, id), username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSavedReportNotFound
+	}
+	return report, err
+}
+
+func (s *Service) Create(ctx context.Context, req SavedReportRequest, username string) (*SavedReport, error) {
+	if err := s.validate(&req, time.Now()); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO "%s".saved_reports (owner, name, report_type, period, filters, row_limit)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		RETURNING id`, s.schema)
+	var id string
+	err := s.db.QueryRow(ctx, query, username, req.Name, req.ReportType, req.Period, req.Filters, req.Limit).Scan(&id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return s.Get(ctx, id, username)
+}
+
+// Update replaces every field of a saved report the user owns. Shares are
+// kept.
+func (s *Service) Update(ctx context.Context, id string, req SavedReportRequest, username string) (*SavedReport, error) {
+	if err := s.authorize(ctx, id, username); err != nil {
+		return nil, err
+	}
+	if err := s.validate(&req, time.Now()); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".saved_reports
+		SET name = $3, report_type = $4, period = NULLIF($5, ''), filters = $6, row_limit = $7,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND owner = $2`, s.schema)
+	tag, err := s.db.Exec(ctx, query, id, username, req.Name, req.ReportType, req.Period, req.Filters, req.Limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrSavedReportNotFound
+	}
+
+	return s.Get(ctx, id, username)
+}
+
+// Delete removes a saved report the user owns, with its shares.
+func (s *Service) Delete(ctx context.Context, id, username string) error {
+	if err := s.authorize(ctx, id, username); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`DELETE FROM "%s".saved_reports WHERE id = $1 AND owner = $2`, s.schema)
+	tag, err := s.db.Exec(ctx, query, id, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved report: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSavedReportNotFound
+	}
+	return nil
+}
+
+// Share lets another user run a saved report the user owns. Sharing twice
+// with the same user is a no-op.
+func (s *Service) Share(ctx context.Context, id, username, shareWith string) (*SavedReport, error) {
+	if err := s.authorize(ctx, id, username); err != nil {
+		return nil, err
+	}
+
+	shareWith = strings.TrimSpace(shareWith)
+	if shareWith == username {
+		return nil, invalid("a saved report cannot be shared with its owner")
+	}
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM "%s".users WHERE username = $1)`, s.schema)
+	if err := s.db.QueryRow(ctx, query, shareWith).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if !exists {
+		return nil, invalid("unknown user %q", shareWith)
+	}
+
+	insert := fmt.Sprintf(`
+		INSERT INTO "%s".saved_report_shares (saved_report_id, username)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, s.schema)
+	if _, err := s.db.Exec(ctx, insert, id, shareWith); err != nil {
+		return nil, fmt.Errorf("failed to share saved report: %w", err)
+	}
+
+	return s.Get(ctx, id, username)
+}
+
+// Unshare stops sharing a saved report the user owns with another user.
+func (s *Service) Unshare(ctx context.Context, id, username, sharedWith string) error {
+	if err := s.authorize(ctx, id, username); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		DELETE FROM "%s".saved_report_shares
+		WHERE saved_report_id = $1 AND username = $2`, s.schema)
+	tag, err := s.db.Exec(ctx, query, id, sharedWith)
+	if err != nil {
+		return fmt.Errorf("failed to unshare saved report: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return invalid("saved report is not shared with %q", sharedWith)
+	}
+	return nil
+}
+
+// Prepare loads a saved report visible to the user and prepares its report,
+// resolving its period relative to now.
+func (s *Service) Prepare(ctx context.Context, id, username string, now time.Time) (reports.ReportFunc, error) {
+	report, err := s.Get(ctx, id, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.runner.Prepare(report.ReportType, params(report.Period, report.Filters, report.Limit), now)
+}
+
+// authorize checks that the user owns the saved report.
+func (s *Service) authorize(ctx context.Context, id, username string) error {
+	report, err := s.Get(ctx, id, username)
+	if err != nil {
+		return err
+	}
+	if report.Access != AccessOwner {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// validate normalizes req and checks that its report can be prepared.
+func (s *Service) validate(req *SavedReportRequest, now time.Time) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Period = strings.TrimSpace(req.Period)
+	if req.Name == "" {
+		return invalid("name is required")
+	}
+	if req.Filters == nil {
+		req.Filters = map[string]string{}
+	}
+	for _, name := range []string{"period", "limit"} {
+		if _, ok := req.Filters[name]; ok {
+			return invalid("set %s as a field of its own, not as a filter", name)
+		}
+	}
+	if req.Limit != nil && *req.Limit < 1 {
+		return invalid("limit must be positive")
+	}
+
+	if _, err := s.runner.Prepare(req.ReportType, params(req.Period, req.Filters, req.Limit), now); err != nil {
+		return invalid("%s", err.Error())
+	}
+	return nil
+}
+
+// params builds the report parameters of a saved report.
+func params(period string, filters map[string]string, limit *int) url.Values {
+	values := make(url.Values, len(filters)+2)
+	for name, value := range filters {
+		values.Set(name, value)
+	}
+	if period != "" {
+		values.Set("period", period)
+	}
+	if limit != nil {
+		values.Set("limit", strconv.Itoa(*limit))
+	}
+	return values
+}
+
+func scanSavedReport(row pgx.Row, username string) (*SavedReport, error) {
+	var report SavedReport
+	err := row.Scan(&report.ID, &report.Name, &report.ReportType, &report.Period, &report.Filters, &report.Limit,
+		&report.Owner, &report.SharedWith, &report.CreatedAt, &report.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan saved report: %w", err)
+	}
+
+	report.Access = AccessRead
+	if report.Owner == username {
+		report.Access = AccessOwner
+	}
+	report.RunURL = "/api/saved-reports/" + report.ID + "/run"
+	return &report, nil
+}
+
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicateName
+	}
+	return fmt.Errorf("failed to save report: %w", err)
+}
@@ -0,0 +1,119 @@
+package schedules
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// runs returns the first n runs of expr after the wall-clock time after in loc.
+func runs(t *testing.T, expr, loc, after string, n int) []string {
+	t.Helper()
+	cron, err := parseCron(expr)
+	if err != nil {
+		t.Fatalf("parseCron(%q): %v", expr, err)
+	}
+	location, err := time.LoadLocation(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := time.ParseInLocation("2006-01-02 15:04", after, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i := 0; i < n; i++ {
+		next = cron.Next(next, location)
+		if next.IsZero() {
+			break
+		}
+		got = append(got, next.Format("2006-01-02 15:04 MST"))
+	}
+	return got
+}
+
+func checkRuns(t *testing.T, expr, loc, after string, want ...string) {
+	t.Helper()
+	got := runs(t, expr, loc, after, len(want))
+	if len(got) != len(want) {
+		t.Fatalf("%q after %s: got %v, want %v", expr, after, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%q after %s: got %v, want %v", expr, after, got, want)
+		}
+	}
+}
+
+func TestCronDayFields(t *testing.T) {
+	// Both day fields restricted: the 13th or any Friday
+	checkRuns(t, "0 9 13 * FRI", "UTC", "2024-09-01 00:00",
+		"2024-09-06 09:00 UTC", "2024-09-13 09:00 UTC", "2024-09-20 09:00 UTC",
+		"2024-09-27 09:00 UTC", "2024-10-04 09:00 UTC", "2024-10-11 09:00 UTC",
+		"2024-10-13 09:00 UTC", "2024-10-18 09:00 UTC")
+	// Only one restricted: that one alone decides
+	checkRuns(t, "0 9 * * FRI", "UTC", "2024-09-01 00:00",
+		"2024-09-06 09:00 UTC", "2024-09-13 09:00 UTC")
+	checkRuns(t, "0 9 13 * *", "UTC", "2024-09-01 00:00",
+		"2024-09-13 09:00 UTC", "2024-10-13 09:00 UTC")
+	// A field starting with * counts as unrestricted even with a step, so
+	// this is Mondays on odd days
+	checkRuns(t, "0 9 */2 * MON", "UTC", "2024-09-01 00:00",
+		"2024-09-09 09:00 UTC", "2024-09-23 09:00 UTC", "2024-10-07 09:00 UTC")
+	// Sunday is 0 or 7
+	checkRuns(t, "0 0 * * 7", "UTC", "2024-09-01 00:00",
+		"2024-09-08 00:00 UTC", "2024-09-15 00:00 UTC")
+	checkRuns(t, "@weekly", "UTC", "2024-09-01 00:00",
+		"2024-09-08 00:00 UTC")
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	if got := runs(t, "0 0 30 2 *", "UTC", "2024-01-01 00:00", 1); len(got) != 0 {
+		t.Errorf("30 February runs at %v", got)
+	}
+}
+
+func TestCronDaylightSavingGap(t *testing.T) {
+	// In New York clocks jump from 02:00 EST to 03:00 EDT on 10 March 2024,
+	// so 02:30 does not exist that day and is skipped
+	checkRuns(t, "30 2 * * *", "America/New_York", "2024-03-09 12:00",
+		"2024-03-11 02:30 EDT", "2024-03-12 02:30 EDT")
+	checkRuns(t, "0 * * * *", "America/New_York", "2024-03-10 00:30",
+		"2024-03-10 01:00 EST", "2024-03-10 03:00 EDT", "2024-03-10 04:00 EDT")
+	checkRuns(t, "0 3 * * *", "America/New_York", "2024-03-09 12:00",
+		"2024-03-10 03:00 EDT", "2024-03-11 03:00 EDT")
+}
+
+func TestCronDaylightSavingOverlap(t *testing.T) {
+	// In New York clocks fall back from 02:00 EDT to 01:00 EST on 3 November
+	// 2024, so 01:00-01:59 happens twice; each time runs only the first time
+	checkRuns(t, "30 1 * * *", "America/New_York", "2024-11-02 12:00",
+		"2024-11-03 01:30 EDT", "2024-11-04 01:30 EST")
+	checkRuns(t, "0 * * * *", "America/New_York", "2024-11-03 00:30",
+		"2024-11-03 01:00 EDT", "2024-11-03 02:00 EST", "2024-11-03 03:00 EST")
+	checkRuns(t, "*/30 1 * * *", "America/New_York", "2024-11-03 00:45",
+		"2024-11-03 01:00 EDT", "2024-11-03 01:30 EDT", "2024-11-04 01:00 EST")
+	// Half-hour shifts, as on Lord Howe Island, are handled the same way
+	checkRuns(t, "45 1 * * *", "Australia/Lord_Howe", "2024-04-06 12:00",
+		"2024-04-07 01:45 +11", "2024-04-08 01:45 +1030")
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"@fortnightly",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package schedules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"financial-reporting-system/internal/jobs"
+	"financial-reporting-system/internal/mail"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// pollInterval is how often the scheduler looks for due schedules and for
+	// finished jobs to email.
+	pollInterval = 15 * time.Second
+	// maxSendAttempts is how many times an email is tried before its delivery
+	// is failed.
+	maxSendAttempts = 3
+	// sendBackoff is the delay before the first resend; it doubles with each
+	// further attempt.
+	sendBackoff = time.Minute
+	// sendTimeout bounds one conversation with the SMTP server.
+	sendTimeout = time.Minute
+	// deliveryLease is how long a claimed delivery is left to its sender. It
+	// outlasts a send, so only a sender that stopped loses its claim.
+	deliveryLease = 2 * sendTimeout
+)
+
+// Start runs the scheduler until ctx is canceled. Every API process may run
+// one: due schedules are locked while they are handled and deliveries are
+// claimed before they are sent, so each run is queued and emailed once.
+func (s *Service) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.run(ctx)
+	}()
+}
+
+// Wait blocks until the scheduler started by Start has stopped after its
+// context was canceled. An email interrupted by cancellation is sent again
+// once its claim expires.
+func (s *Service) Wait() {
+	s.running.Wait()
+}
+
+func (s *Service) run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.step(ctx, "fire a schedule", s.fireNext) {
+		}
+		for s.step(ctx, "deliver a report", s.deliverNext) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// step runs fn once and reports whether it found work.
+func (s *Service) step(ctx context.Context, what string, fn func(context.Context) (bool, error)) bool {
+	found, err := fn(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("report schedules: failed to %s: %v", what, err)
+		}
+		return false
+	}
+	return found
+}
+
+// fireNext queues the report of the most overdue schedule and advances the
+// schedule to its next run after now, all in one transaction. Runs missed
+// while no process was running are collapsed into one. It reports whether a
+// schedule was due.
+func (s *Service) fireNext(ctx context.Context) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		SELECT id, cron_expr, timezone, report_type, params, format, recipients, next_run_at, COALESCE(created_by, '')
+		FROM "%s".report_schedules
+		WHERE enabled AND next_run_at <= NOW()
+		ORDER BY next_run_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, s.schema)
+	var id, cronExpr, timezone, createdBy string
+	var req jobs.JobRequest
+	var recipients []string
+	var scheduledFor time.Time
+	err = tx.QueryRow(ctx, query).Scan(&id, &cronExpr, &timezone, &req.ReportType, &req.Params, &req.Format,
+		&recipients, &scheduledFor, &createdBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The unique (schedule, time) pair makes the run idempotent even if the
+	// schedule was not advanced.
+	insert := fmt.Sprintf(`
+		INSERT INTO "%s".schedule_deliveries (schedule_id, scheduled_for, recipients)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (schedule_id, scheduled_for) DO NOTHING
+		RETURNING id`, s.schema)
+	var deliveryID string
+	err = tx.QueryRow(ctx, insert, id, scheduledFor, recipients).Scan(&deliveryID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	cron, cronErr := parseCron(cronExpr)
+	loc, locErr := time.LoadLocation(timezone)
+	if err := errors.Join(cronErr, locErr); err != nil {
+		return true, s.disable(ctx, tx, id, deliveryID, err)
+	}
+
+	if deliveryID != "" {
+		local := scheduledFor.In(loc)
+		referenceDate := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		jobID, err := s.jobs.EnqueueTx(ctx, tx, req, createdBy, referenceDate)
+		var validationErr *jobs.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			if err := s.failDelivery(ctx, tx, deliveryID, "report parameters are no longer valid: "+err.Error()); err != nil {
+				return false, err
+			}
+		case err != nil:
+			return false, err
+		default:
+			update := fmt.Sprintf(`UPDATE "%s".schedule_deliveries SET job_id = $2 WHERE id = $1`, s.schema)
+			if _, err := tx.Exec(ctx, update, deliveryID, jobID); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	now := time.Now()
+	if scheduledFor.After(now) {
+		now = scheduledFor
+	}
+	next := cron.Next(now, loc)
+	if next.IsZero() {
+		return true, s.disable(ctx, tx, id, "", nil)
+	}
+	advance := fmt.Sprintf(`UPDATE "%s".report_schedules SET next_run_at = $2 WHERE id = $1`, s.schema)
+	if _, err := tx.Exec(ctx, advance, id, next); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// disable turns off a schedule that can no longer run, failing its current
+// delivery with cause when there is one.
+func (s *Service) disable(ctx context.Context, tx pgx.Tx, id, deliveryID string, cause error) error {
+	if deliveryID != "" && cause != nil {
+		if err := s.failDelivery(ctx, tx, deliveryID, "schedule disabled: "+cause.Error()); err != nil {
+			return err
+		}
+	}
+	query := fmt.Sprintf(`
+		UPDATE "%s".report_schedules SET enabled = FALSE, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, s.schema)
+	if _, err := tx.Exec(ctx, query, id); err != nil {
+		return err
+	}
+	reason := "it has no further runs"
+	if cause != nil {
+		reason = cause.Error()
+	}
+	log.Printf("report schedules: disabled schedule %s: %s", id, reason)
+	return tx.Commit(ctx)
+}
+
+// execer is implemented by both the pool and transactions.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+func (s *Service) failDelivery(ctx context.Context, db execer, id, message string) error {
+	query := fmt.Sprintf(`
+		UPDATE "%s".schedule_deliveries SET status = 'failed', last_error = $2, lease_expires_at = NULL
+		WHERE id = $1`, s.schema)
+	_, err := db.Exec(ctx, query, id, message)
+	return err
+}
+
+// deliverNext emails the result of one pending delivery whose report job has
+// finished, and reports whether there was one. The delivery is claimed and the
+// claim committed before the email is sent, so no transaction is held open
+// during the SMTP conversation; the outcome is recorded afterwards.
+func (s *Service) deliverNext(ctx context.Context) (bool, error) {
+	d, err := s.claimDelivery(ctx)
+	if err != nil || d == nil {
+		return false, err
+	}
+
+	switch {
+	case d.jobID == nil:
+		err = s.failDelivery(ctx, s.db, d.id, "report job no longer exists")
+	case d.jobStatus == jobs.StatusFailed:
+		err = s.failDelivery(ctx, s.db, d.id, "report failed: "+d.jobError)
+	default:
+		sendErr := s.send(ctx, *d.jobID, d.scheduledFor, d.recipients, d.name, d.reportType, d.timezone)
+		if ctx.Err() != nil {
+			// Sent again once the lease expires
+			return false, ctx.Err()
+		}
+		err = s.recordSend(ctx, d.id, sendErr)
+	}
+	return err == nil, err
+}
+
+// claimedDelivery is what the scheduler needs to email a delivery.
+type claimedDelivery struct {
+	id                         string
+	jobID                      *string
+	scheduledFor               time.Time
+	recipients                 []string
+	jobStatus, jobError        string
+	name, reportType, timezone string
+}
+
+// claimDelivery leases the next delivery to email: a pending one whose job
+// has finished and whose next attempt is due, or one whose sender stopped
+// before recording the outcome. The latter may have been emailed already, so
+// an interrupted send can arrive twice. Deliveries that have used up their
+// attempts that way are failed first. It returns nil when there is none.
+func (s *Service) claimDelivery(ctx context.Context) (*claimedDelivery, error) {
+	expired := fmt.Sprintf(`
+		UPDATE "%s".schedule_deliveries
+		SET status = 'failed', lease_expires_at = NULL,
+			last_error = 'scheduler stopped before the email was sent'
+		WHERE status = 'sending' AND lease_expires_at < NOW() AND attempts >= $1`, s.schema)
+	if _, err := s.db.Exec(ctx, expired, maxSendAttempts); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		WITH due AS (
+			SELECT d.id, COALESCE(j.status, '') AS job_status, COALESCE(j.last_error, '') AS job_error
+			FROM "%[1]s".schedule_deliveries d
+			LEFT JOIN "%[1]s".report_jobs j ON d.job_id = j.id
+			WHERE ((d.status = 'pending' AND d.next_attempt_at <= NOW())
+					OR (d.status = 'sending' AND d.lease_expires_at < NOW()))
+				AND (j.id IS NULL OR j.status IN ('succeeded', 'failed'))
+			ORDER BY d.next_attempt_at
+			LIMIT 1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE "%[1]s".schedule_deliveries d
+		SET status = 'sending', attempts = d.attempts + 1,
+			lease_expires_at = NOW() + make_interval(secs => $1)
+		FROM due, "%[1]s".report_schedules sc
+		WHERE d.id = due.id AND sc.id = d.schedule_id
+		RETURNING d.id, d.job_id, d.scheduled_for, d.recipients, due.job_status, due.job_error,
+			sc.name, sc.report_type, sc.timezone`, s.schema)
+	var d claimedDelivery
+	err := s.db.QueryRow(ctx, query, deliveryLease.Seconds()).Scan(&d.id, &d.jobID, &d.scheduledFor, &d.recipients,
+		&d.jobStatus, &d.jobError, &d.name, &d.reportType, &d.timezone)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// send emails the result of a succeeded job.
+func (s *Service) send(ctx context.Context, jobID string, scheduledFor time.Time, recipients []string, name, reportType, timezone string) error {
+	// The scheduler reads results for every schedule, whoever owns it
+	file, err := s.jobs.Result(ctx, jobID, "", true)
+	if err != nil {
+		return err
+	}
+
+	if loc, err := time.LoadLocation(timezone); err == nil {
+		scheduledFor = scheduledFor.In(loc)
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return s.mailer.Send(sendCtx, mail.Message{
+		To:      recipients,
+		Subject: name,
+		Body: fmt.Sprintf("Attached is the %s report of schedule %q, run for %s.\r\n",
+			reportType, name, scheduledFor.Format("2 January 2006 15:04 MST")),
+		Attachments: []mail.Attachment{{
+			Name:        file.Name,
+			ContentType: file.ContentType,
+			Data:        file.Data,
+		}},
+	})
+}
+
+// recordSend marks a claimed delivery sent, or schedules a resend with
+// exponential backoff until its attempts are used up. A permanent failure,
+// such as a missing SMTP server or a rejected recipient, fails the delivery at
+// once.
+func (s *Service) recordSend(ctx context.Context, id string, sendErr error) error {
+	if sendErr == nil {
+		query := fmt.Sprintf(`
+			UPDATE "%s".schedule_deliveries
+			SET status = 'sent', last_error = NULL, lease_expires_at = NULL, sent_at = NOW()
+			WHERE id = $1`, s.schema)
+		_, err := s.db.Exec(ctx, query, id)
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".schedule_deliveries
+		SET last_error = $2, lease_expires_at = NULL,
+			status = CASE WHEN $3::BOOLEAN OR attempts >= $4 THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = NOW() + make_interval(secs => $5 * POWER(2, attempts - 1))
+		WHERE id = $1`, s.schema)
+	permanent := mail.IsPermanent(sendErr)
+	_, err := s.db.Exec(ctx, query, id, sendErr.Error(), permanent, maxSendAttempts, sendBackoff.Seconds())
+	return err
+}
@@ -0,0 +1,377 @@
+// Package schedules runs reports on cron schedules and emails the results.
+package schedules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/jobs"
+	"financial-reporting-system/internal/mail"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending = "pending"
+	DeliverySending = "sending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// ValidationError is returned when a schedule is rejected. Its message is
+// safe to show to API clients.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ErrScheduleNotFound is returned when a schedule ID does not exist.
+var ErrScheduleNotFound = errors.New("report schedule not found")
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+type Service struct {
+	db     *pgxpool.Pool
+	jobs   *jobs.Service
+	mailer mail.Mailer
+	schema string
+	// running tracks the goroutine started by Start
+	running sync.WaitGroup
+}
+
+func NewService(db *pgxpool.Pool, jobService *jobs.Service, mailer mail.Mailer, schema string) *Service {
+	return &Service{
+		db:     db,
+		jobs:   jobService,
+		mailer: mailer,
+		schema: schema,
+	}
+}
+
+// ScheduleRequest creates or replaces a schedule. Cron is a five-field cron
+// expression or a macro such as @daily, evaluated in Timezone (UTC by
+// default). Params are those of the report's endpoint; named periods such as
+// last_month resolve against the date of each run. Format defaults to pdf.
+type ScheduleRequest struct {
+	Name       string            `json:"name" binding:"required"`
+	Cron       string            `json:"cron" binding:"required"`
+	Timezone   string            `json:"timezone"`
+	ReportType string            `json:"report_type" binding:"required"`
+	Params     map[string]string `json:"params"`
+	Format     string            `json:"format"`
+	Recipients []string          `json:"recipients" binding:"required"`
+	Enabled    *bool             `json:"enabled"`
+}
+
+type Schedule struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Cron       string            `json:"cron"`
+	Timezone   string            `json:"timezone"`
+	ReportType string            `json:"report_type"`
+	Params     map[string]string `json:"params"`
+	Format     string            `json:"format"`
+	Recipients []string          `json:"recipients"`
+	Enabled    bool              `json:"enabled"`
+	NextRunAt  *time.Time        `json:"next_run_at,omitempty"`
+	CreatedBy  string            `json:"created_by,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Delivery is one scheduled run of a report and the email that sends it.
+type Delivery struct {
+	ID           string     `json:"id"`
+	ScheduleID   string     `json:"schedule_id"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	JobID        string     `json:"job_id,omitempty"`
+	JobStatus    string     `json:"job_status,omitempty"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	LastError    string     `json:"last_error,omitempty"`
+	Recipients   []string   `json:"recipients"`
+	CreatedAt    time.Time  `json:"created_at"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+}
+
+// scheduleRow is a validated schedule ready to be written.
+type scheduleRow struct {
+	name       string
+	cron       string
+	timezone   string
+	job        jobs.JobRequest
+	recipients []string
+	enabled    bool
+	nextRunAt  time.Time
+}
+
+const selectSchedule = `
+	SELECT id, name, cron_expr, timezone, report_type, params, format, recipients, enabled,
+		next_run_at, COALESCE(created_by, ''), created_at, updated_at
+	FROM "%s".report_schedules`
+
+// List returns the schedules created by username, or every schedule for an
+// administrator.
+func (s *Service) List(ctx context.Context, username string, admin bool) ([]Schedule, error) {
+	query := fmt.Sprintf(selectSchedule, s.schema) + `
+	WHERE $1 OR created_by = $2
+	ORDER BY name, created_at`
+	rows, err := s.db.Query(ctx, query, admin, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// Get returns a schedule created by username, or any schedule for an
+// administrator. Other users' schedules are not found.
+func (s *Service) Get(ctx context.Context, id, username string, admin bool) (*Schedule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrScheduleNotFound
+	}
+
+	query := fmt.Sprintf(selectSchedule, s.schema) + `
+	WHERE id = $1 AND ($2 OR created_by = $3)`
+	schedule, err := scanSchedule(s.db.QueryRow(ctx, query, id, admin, username))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, err
+}
+
+func (s *Service) Create(ctx context.Context, req ScheduleRequest, createdBy string) (*Schedule, error) {
+	row, err := s.validate(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO "%s".report_schedules (name, cron_expr, timezone, report_type, params, format, recipients,
+			enabled, next_run_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		RETURNING id`, s.schema)
+	var id string
+	err = s.db.QueryRow(ctx, query, row.name, row.cron, row.timezone, row.job.ReportType, row.job.Params,
+		row.job.Format, row.recipients, row.enabled, row.nextRunAt, createdBy).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report schedule: %w", err)
+	}
+
+	return s.Get(ctx, id, createdBy, false)
+}
+
+// Update replaces every field of a schedule other than its owner, with the
+// same access as Get. The next run is recomputed from the new expression, so
+// runs already due are skipped.
+func (s *Service) Update(ctx context.Context, id string, req ScheduleRequest, username string, admin bool) (*Schedule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrScheduleNotFound
+	}
+
+	row, err := s.validate(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE "%s".report_schedules
+		SET name = $2, cron_expr = $3, timezone = $4, report_type = $5, params = $6, format = $7,
+			recipients = $8, enabled = $9, next_run_at = $10, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND ($11 OR created_by = $12)`, s.schema)
+	tag, err := s.db.Exec(ctx, query, id, row.name, row.cron, row.timezone, row.job.ReportType, row.job.Params,
+		row.job.Format, row.recipients, row.enabled, row.nextRunAt, admin, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update report schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrScheduleNotFound
+	}
+
+	return s.Get(ctx, id, username, admin)
+}
+
+// Delete removes a schedule with its delivery history, with the same access
+// as Get. Report jobs already queued still run, but their results are not
+// emailed.
+func (s *Service) Delete(ctx context.Context, id, username string, admin bool) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrScheduleNotFound
+	}
+
+	query := fmt.Sprintf(`DELETE FROM "%s".report_schedules WHERE id = $1 AND ($2 OR created_by = $3)`, s.schema)
+	tag, err := s.db.Exec(ctx, query, id, admin, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete report schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// DeliveryFilter narrows Deliveries to one status and the most recent runs.
+type DeliveryFilter struct {
+	Status string
+	Limit  int
+}
+
+// Deliveries returns the delivery history of a schedule, most recent first,
+// with the same access as Get.
+func (s *Service) Deliveries(ctx context.Context, scheduleID string, filter DeliveryFilter, username string, admin bool) ([]Delivery, error) {
+	if _, err := s.Get(ctx, scheduleID, username, admin); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"d.schedule_id = $1"}
+	args := []interface{}{scheduleID}
+	if filter.Status != "" {
+		switch filter.Status {
+		case DeliveryPending, DeliverySending, DeliverySent, DeliveryFailed:
+		default:
+			return nil, invalid("status must be %s, %s, %s or %s", DeliveryPending, DeliverySending, DeliverySent, DeliveryFailed)
+		}
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("d.status = $%d", len(args)))
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT d.id, d.schedule_id, d.scheduled_for, d.job_id, COALESCE(j.status, ''), d.status, d.attempts,
+			COALESCE(d.last_error, ''), d.recipients, d.created_at, d.sent_at
+		FROM "%[1]s".schedule_deliveries d
+		LEFT JOIN "%[1]s".report_jobs j ON d.job_id = j.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY d.scheduled_for DESC
+		LIMIT $%[2]d`, s.schema, len(args))
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		var jobID *string
+		err := rows.Scan(&d.ID, &d.ScheduleID, &d.ScheduledFor, &jobID, &d.JobStatus, &d.Status, &d.Attempts,
+			&d.LastError, &d.Recipients, &d.CreatedAt, &d.SentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		if jobID != nil {
+			d.JobID = *jobID
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// validate checks a schedule request and computes its first run after now.
+func (s *Service) validate(req ScheduleRequest, now time.Time) (*scheduleRow, error) {
+	row := &scheduleRow{
+		name:     strings.TrimSpace(req.Name),
+		cron:     strings.Join(strings.Fields(req.Cron), " "),
+		timezone: strings.TrimSpace(req.Timezone),
+		job: jobs.JobRequest{
+			ReportType: req.ReportType,
+			Params:     req.Params,
+			Format:     strings.ToLower(strings.TrimSpace(req.Format)),
+		},
+		enabled: req.Enabled == nil || *req.Enabled,
+	}
+	if row.name == "" {
+		return nil, invalid("name is required")
+	}
+	if row.timezone == "" {
+		row.timezone = "UTC"
+	}
+	if row.job.Format == "" {
+		row.job.Format = string(export.FormatPDF)
+	}
+	if row.job.Params == nil {
+		row.job.Params = map[string]string{}
+	}
+
+	cron, err := parseCron(row.cron)
+	if err != nil {
+		return nil, invalid("%s", err.Error())
+	}
+	loc, err := time.LoadLocation(row.timezone)
+	if err != nil {
+		return nil, invalid("unknown timezone %q", row.timezone)
+	}
+	if row.nextRunAt = cron.Next(now, loc); row.nextRunAt.IsZero() {
+		return nil, invalid("cron expression %q never runs", row.cron)
+	}
+
+	if len(req.Recipients) == 0 {
+		return nil, invalid("at least one recipient is required")
+	}
+	for _, recipient := range req.Recipients {
+		address, err := netmail.ParseAddress(recipient)
+		if err != nil {
+			return nil, invalid("invalid recipient %q", recipient)
+		}
+		row.recipients = append(row.recipients, address.Address)
+	}
+
+	if err := s.jobs.Validate(row.job, now); err != nil {
+		var validationErr *jobs.ValidationError
+		if errors.As(err, &validationErr) {
+			return nil, invalid("%s", validationErr.Message)
+		}
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func scanSchedule(row pgx.Row) (*Schedule, error) {
+	var schedule Schedule
+	var nextRunAt time.Time
+	err := row.Scan(&schedule.ID, &schedule.Name, &schedule.Cron, &schedule.Timezone, &schedule.ReportType,
+		&schedule.Params, &schedule.Format, &schedule.Recipients, &schedule.Enabled, &nextRunAt,
+		&schedule.CreatedBy, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan report schedule: %w", err)
+	}
+	if schedule.Enabled {
+		schedule.NextRunAt = &nextRunAt
+	}
+	return &schedule, nil
+}
@@ -1,27 +1,61 @@
 package server
 
 import (
+	"context"
+	"net/http"
+	"time"
+
 	"financial-reporting-system/internal/auth"
+	"financial-reporting-system/internal/budgets"
+	"financial-reporting-system/internal/cache"
+	"financial-reporting-system/internal/export"
+	"financial-reporting-system/internal/fx"
+	"financial-reporting-system/internal/jobs"
+	"financial-reporting-system/internal/journal"
+	"financial-reporting-system/internal/money"
+	"financial-reporting-system/internal/periods"
 	"financial-reporting-system/internal/reports"
+	"financial-reporting-system/internal/savedreports"
+	"financial-reporting-system/internal/schedules"
 
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout is how long requests in flight may take to finish when the
+// server stops.
+const shutdownTimeout = 30 * time.Second
+
 type Server struct {
-	router        *gin.Engine
-	authHandler   *auth.Handler
-	reportHandler *reports.Handler
+	router             *gin.Engine
+	authHandler        *auth.Handler
+	reportHandler      *reports.Handler
+	journalHandler     *journal.Handler
+	periodHandler      *periods.Handler
+	budgetHandler      *budgets.Handler
+	fxHandler          *fx.Handler
+	jobHandler         *jobs.Handler
+	scheduleHandler    *schedules.Handler
+	savedReportHandler *savedreports.Handler
+	cacheHandler       *cache.Handler
 }
 
-func NewServer(authHandler *auth.Handler, reportHandler *reports.Handler) *Server {
+func NewServer(authHandler *auth.Handler, reportHandler *reports.Handler, journalHandler *journal.Handler, periodHandler *periods.Handler, budgetHandler *budgets.Handler, fxHandler *fx.Handler, jobHandler *jobs.Handler, scheduleHandler *schedules.Handler, savedReportHandler *savedreports.Handler, cacheHandler *cache.Handler) *Server {
 	if gin.Mode() == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	s := &Server{
-		router:        gin.Default(),
-		authHandler:   authHandler,
-		reportHandler: reportHandler,
+		router:             gin.Default(),
+		authHandler:        authHandler,
+		reportHandler:      reportHandler,
+		journalHandler:     journalHandler,
+		periodHandler:      periodHandler,
+		budgetHandler:      budgetHandler,
+		fxHandler:          fxHandler,
+		jobHandler:         jobHandler,
+		scheduleHandler:    scheduleHandler,
+		savedReportHandler: savedReportHandler,
+		cacheHandler:       cacheHandler,
 	}
 
 	s.setupRoutes()
@@ -60,17 +94,132 @@ func (s *Server) setupRoutes() {
 
 		// Report routes (auth required)
 		reports := api.Group("/reports")
-		reports.Use(s.authHandler.RequireAuth())
+		reports.Use(s.authHandler.RequireAuth(), money.ValidateFormat(), export.ValidateFormat())
 		{
 			reports.GET("/profit-loss", s.reportHandler.GetProfitLoss)
 			reports.GET("/revenue-category", s.reportHandler.GetRevenueByCategory)
 			reports.GET("/top-customers", s.reportHandler.GetTopCustomers)
+			reports.GET("/ar-aging", s.reportHandler.GetARAging)
 			reports.GET("/parallel", s.reportHandler.GetMultipleReportsParallel)
+			reports.GET("/balance-sheet", s.reportHandler.GetBalanceSheet)
+			reports.GET("/trial-balance", s.reportHandler.GetTrialBalance)
+			reports.GET("/ledger/:account_code", s.reportHandler.GetLedger)
+			reports.GET("/cash-flow", s.reportHandler.GetCashFlow)
+			reports.GET("/timeseries", s.reportHandler.GetTimeSeries)
+			reports.GET("/budget-vs-actual", s.budgetHandler.GetBudgetVsActual)
+			reports.GET("/fx-revaluation", s.reportHandler.GetFXRevaluation)
+		}
+
+		// Journal entry routes (auth required)
+		journal := api.Group("/journal-entries")
+		journal.Use(s.authHandler.RequireAuth())
+		{
+			journal.POST("", s.journalHandler.PostEntry)
+			journal.GET("/:id", s.journalHandler.GetEntry)
+			journal.POST("/:id/reverse", s.journalHandler.ReverseEntry)
+			journal.PUT("/:id", s.journalHandler.RejectModification)
+			journal.DELETE("/:id", s.journalHandler.RejectModification)
+		}
+
+		// Accounting period routes (auth required; changing a status requires
+		// an admin user, as a close cannot be undone)
+		periods := api.Group("/periods")
+		periods.Use(s.authHandler.RequireAuth())
+		{
+			periods.GET("", s.periodHandler.ListPeriods)
+			periods.GET("/:period", s.periodHandler.GetPeriod)
+			periods.POST("/:period/soft-close", s.authHandler.RequireAdmin(), s.periodHandler.SoftClosePeriod)
+			periods.POST("/:period/close", s.authHandler.RequireAdmin(), s.periodHandler.ClosePeriod)
+			periods.POST("/:period/reopen", s.authHandler.RequireAdmin(), s.periodHandler.ReopenPeriod)
+		}
+
+		// Budget routes (auth required)
+		budgets := api.Group("/budgets")
+		budgets.Use(s.authHandler.RequireAuth())
+		{
+			budgets.GET("", s.budgetHandler.ListBudgets)
+			budgets.POST("", s.budgetHandler.CreateBudget)
+			budgets.POST("/upload", s.budgetHandler.UploadBudgets)
+			budgets.GET("/:id", s.budgetHandler.GetBudget)
+			budgets.PUT("/:id", s.budgetHandler.UpdateBudget)
+			budgets.DELETE("/:id", s.budgetHandler.DeleteBudget)
+		}
+
+		// Exchange rate and ledger routes (auth required; setting a rate
+		// requires an admin user, as every converted report uses it)
+		fxRates := api.Group("/fx-rates")
+		fxRates.Use(s.authHandler.RequireAuth())
+		{
+			fxRates.GET("", s.fxHandler.ListRates)
+			fxRates.POST("", s.authHandler.RequireAdmin(), s.fxHandler.SetRate)
+		}
+		api.GET("/ledgers", s.authHandler.RequireAuth(), s.fxHandler.ListLedgers)
+
+		// Background report job routes (auth required; users see only their
+		// own jobs unless they are admins)
+		reportJobs := api.Group("/report-jobs")
+		reportJobs.Use(s.authHandler.RequireAuth())
+		{
+			reportJobs.POST("", s.jobHandler.CreateJob)
+			reportJobs.GET("/:id", s.jobHandler.GetJob)
+			reportJobs.DELETE("/:id", s.jobHandler.DeleteJob)
+			reportJobs.GET("/:id/download", s.jobHandler.DownloadResult)
+		}
+
+		// Scheduled report routes (auth required; users see only their own
+		// schedules unless they are admins)
+		reportSchedules := api.Group("/report-schedules")
+		reportSchedules.Use(s.authHandler.RequireAuth())
+		{
+			reportSchedules.GET("", s.scheduleHandler.ListSchedules)
+			reportSchedules.POST("", s.scheduleHandler.CreateSchedule)
+			reportSchedules.GET("/:id", s.scheduleHandler.GetSchedule)
+			reportSchedules.PUT("/:id", s.scheduleHandler.UpdateSchedule)
+			reportSchedules.DELETE("/:id", s.scheduleHandler.DeleteSchedule)
+			reportSchedules.GET("/:id/deliveries", s.scheduleHandler.ListDeliveries)
+		}
+
+		// Saved report routes (auth required)
+		savedReports := api.Group("/saved-reports")
+		savedReports.Use(s.authHandler.RequireAuth())
+		{
+			savedReports.GET("", s.savedReportHandler.ListSavedReports)
+			savedReports.POST("", s.savedReportHandler.CreateSavedReport)
+			savedReports.GET("/:id", s.savedReportHandler.GetSavedReport)
+			savedReports.PUT("/:id", s.savedReportHandler.UpdateSavedReport)
+			savedReports.DELETE("/:id", s.savedReportHandler.DeleteSavedReport)
+			savedReports.GET("/:id/run", money.ValidateFormat(), export.ValidateFormat(), s.savedReportHandler.RunSavedReport)
+			savedReports.POST("/:id/shares", s.savedReportHandler.ShareSavedReport)
+			savedReports.DELETE("/:id/shares/:username", s.savedReportHandler.UnshareSavedReport)
+		}
+
+		// Admin routes (auth and an admin user required)
+		admin := api.Group("/admin")
+		admin.Use(s.authHandler.RequireAuth(), s.authHandler.RequireAdmin())
+		{
+			admin.GET("/cache/stats", s.cacheHandler.GetStats)
+			admin.POST("/cache/invalidate", s.reportHandler.InvalidateCache)
 		}
 	}
 }
 
-func (s *Server) Run(addr string) error {
-	return s.router.Run(addr)
+// Run serves the API on addr until ctx is canceled. It then stops accepting
+// connections and waits up to shutdownTimeout for requests in flight.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s.router}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
 }
 
@@ -45,6 +45,47 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+-- Trial Balance Report
+-- Returns opening balance, period debits, period credits and closing balance per account.
+-- Balances are expressed as debit minus credit so that the columns of a balanced
+-- ledger sum to zero.
+CREATE OR REPLACE FUNCTION sp_trial_balance(
+    start_date DATE,
+    end_date DATE
+)
+RETURNS TABLE (
+    account_id UUID,
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    opening_balance DECIMAL(15, 2),
+    period_debit DECIMAL(15, 2),
+    period_credit DECIMAL(15, 2),
+    closing_balance DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT 
+        a.id AS account_id,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(SUM(CASE WHEN m.transaction_date < start_date THEN m.debit - m.credit ELSE 0 END), 0)::DECIMAL(15, 2) AS opening_balance,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.debit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_debit,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.credit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_credit,
+        COALESCE(SUM(m.debit - m.credit), 0)::DECIMAL(15, 2) AS closing_balance
+    FROM accounts a
+    LEFT JOIN (
+        SELECT ti.account_id, ti.debit, ti.credit, t.transaction_date
+        FROM transaction_items ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date <= end_date
+    ) m ON m.account_id = a.id
+    GROUP BY a.id, a.code, a.name, a.type
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
 -- Revenue by Category Report
 -- Optimized with direct joins and date filtering
 CREATE OR REPLACE FUNCTION sp_revenue_by_category(
@@ -118,3 +159,353 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+
+-- Balance Sheet Report
+-- Returns the cumulative balance of every account up to and including as_of_date.
+-- Balances are signed by the account's normal side (debit for assets and expenses,
+-- credit for liabilities, equity and revenue) so they can be summed per section.
+CREATE OR REPLACE FUNCTION sp_balance_sheet(
+    as_of_date DATE
+)
+RETURNS TABLE (
+    account_id UUID,
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    balance DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT 
+        a.id AS account_id,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(
+            SUM(CASE 
+                WHEN a.type IN ('asset', 'expense') THEN ti.debit - ti.credit
+                ELSE ti.credit - ti.debit
+            END), 
+            0
+        )::DECIMAL(15, 2) AS balance
+    FROM accounts a
+    LEFT JOIN (
+        SELECT ti.account_id, ti.debit, ti.credit
+        FROM transaction_items ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date <= as_of_date
+    ) ti ON ti.account_id = a.id
+    GROUP BY a.id, a.code, a.name, a.type
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
+-- General Ledger Summary
+-- Returns an account's opening balance, period totals and closing balance for a date range,
+-- signed by the account's normal side. Returns no rows when the account code is unknown.
+CREATE OR REPLACE FUNCTION sp_account_ledger_summary(
+    p_account_code VARCHAR(50),
+    start_date DATE,
+    end_date DATE
+)
+RETURNS TABLE (
+    account_id UUID,
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    opening_balance DECIMAL(15, 2),
+    period_debit DECIMAL(15, 2),
+    period_credit DECIMAL(15, 2),
+    closing_balance DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT 
+        a.id AS account_id,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        (CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+            * COALESCE(SUM(CASE WHEN m.transaction_date < start_date THEN m.debit - m.credit ELSE 0 END), 0))::DECIMAL(15, 2) AS opening_balance,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.debit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_debit,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.credit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_credit,
+        (CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+            * COALESCE(SUM(m.debit - m.credit), 0))::DECIMAL(15, 2) AS closing_balance
+    FROM accounts a
+    LEFT JOIN (
+        SELECT ti.account_id, ti.debit, ti.credit, t.transaction_date
+        FROM transaction_items ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date <= end_date
+    ) m ON m.account_id = a.id
+    WHERE a.code = p_account_code
+    GROUP BY a.id, a.code, a.name, a.type;
+END;
+$$ LANGUAGE plpgsql;
+
+-- General Ledger Lines
+-- Returns one page of an account's transaction lines ordered by (transaction_date, item_id),
+-- starting after the (after_date, after_item_id) keyset cursor when one is given.
+-- The running balance is seeded with everything posted before the page, so pages can be
+-- fetched independently without scanning the lines in between.
+CREATE OR REPLACE FUNCTION sp_general_ledger(
+    p_account_code VARCHAR(50),
+    start_date DATE,
+    end_date DATE,
+    after_date DATE,
+    after_item_id UUID,
+    page_size INTEGER
+)
+RETURNS TABLE (
+    item_id UUID,
+    transaction_id UUID,
+    transaction_date DATE,
+    reference_number VARCHAR(100),
+    description TEXT,
+    debit DECIMAL(15, 2),
+    credit DECIMAL(15, 2),
+    running_balance DECIMAL(15, 2)
+) AS $$
+DECLARE
+    v_account_id UUID;
+    v_sign INTEGER;
+    v_opening DECIMAL(15, 2);
+BEGIN
+    SELECT a.id, CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+    INTO v_account_id, v_sign
+    FROM accounts a
+    WHERE a.code = p_account_code;
+
+    IF v_account_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    SELECT v_sign * COALESCE(SUM(ti.debit - ti.credit), 0)
+    INTO v_opening
+    FROM transaction_items ti
+    INNER JOIN transactions t ON ti.transaction_id = t.id
+    WHERE ti.account_id = v_account_id
+        AND (
+            t.transaction_date < start_date
+            OR (
+                after_date IS NOT NULL
+                AND t.transaction_date <= end_date
+                AND (t.transaction_date, ti.id) <= (after_date, after_item_id)
+            )
+        );
+
+    RETURN QUERY
+    SELECT 
+        p.item_id,
+        p.transaction_id,
+        p.transaction_date,
+        p.reference_number,
+        p.description,
+        p.debit,
+        p.credit,
+        (v_opening + SUM(v_sign * (p.debit - p.credit)) OVER (ORDER BY p.transaction_date, p.item_id))::DECIMAL(15, 2) AS running_balance
+    FROM (
+        SELECT 
+            ti.id AS item_id,
+            t.id AS transaction_id,
+            t.transaction_date,
+            t.reference_number,
+            COALESCE(t.description, ti.description) AS description,
+            ti.debit,
+            ti.credit
+        FROM transaction_items ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE ti.account_id = v_account_id
+            AND t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+            AND (after_date IS NULL OR (t.transaction_date, ti.id) > (after_date, after_item_id))
+        ORDER BY t.transaction_date, ti.id
+        LIMIT page_size
+    ) p
+    ORDER BY p.transaction_date, p.item_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Cash Flow Activity Classification
+-- Maps the counter-account of a cash movement to an activity. Revenue and expense
+-- accounts are operating; within assets and liabilities the conventional code ranges
+-- separate working capital (1000-1499, 2000-2499 -> operating) from long-term
+-- balances (assets -> investing, liabilities -> financing). Equity is financing.
+CREATE OR REPLACE FUNCTION fn_cash_flow_activity(
+    p_account_type VARCHAR(50),
+    p_account_code VARCHAR(50)
+)
+RETURNS VARCHAR(20) AS $$
+    SELECT CASE
+        WHEN p_account_type IN ('revenue', 'expense') THEN 'operating'
+        WHEN p_account_type = 'asset' AND p_account_code < '1500' THEN 'operating'
+        WHEN p_account_type = 'asset' THEN 'investing'
+        WHEN p_account_type = 'liability' AND p_account_code < '2500' THEN 'operating'
+        ELSE 'financing'
+    END::VARCHAR(20);
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Cash Flow Statement (direct method)
+-- For every transaction that touches the cash account, the counter-account lines carry
+-- the cash effect (credit minus debit), so receipts and payments can be classified by
+-- counter-account and grouped by transaction type.
+CREATE OR REPLACE FUNCTION sp_cash_flow_direct(
+    start_date DATE,
+    end_date DATE,
+    cash_account_code VARCHAR(50)
+)
+RETURNS TABLE (
+    activity VARCHAR(20),
+    transaction_type VARCHAR(50),
+    receipts DECIMAL(15, 2),
+    payments DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT 
+        fn_cash_flow_activity(a.type, a.code) AS activity,
+        t.transaction_type,
+        COALESCE(SUM(GREATEST(ti.credit - ti.debit, 0)), 0)::DECIMAL(15, 2) AS receipts,
+        COALESCE(SUM(GREATEST(ti.debit - ti.credit, 0)), 0)::DECIMAL(15, 2) AS payments
+    FROM transactions t
+    INNER JOIN transaction_items ti ON ti.transaction_id = t.id
+    INNER JOIN accounts a ON ti.account_id = a.id
+    WHERE t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND a.code <> cash_account_code
+        AND EXISTS (
+            SELECT 1
+            FROM transaction_items cti
+            INNER JOIN accounts ca ON cti.account_id = ca.id
+            WHERE cti.transaction_id = t.id
+                AND ca.code = cash_account_code
+        )
+    GROUP BY 1, t.transaction_type
+    ORDER BY 1, t.transaction_type;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Cash Flow Statement (indirect method)
+-- Returns the net movement (credit minus debit) of every non-cash account over the range.
+-- Revenue and expense rows add up to net income; the remaining rows are the balance sheet
+-- changes that adjust net income to the change in cash.
+CREATE OR REPLACE FUNCTION sp_cash_flow_indirect(
+    start_date DATE,
+    end_date DATE,
+    cash_account_code VARCHAR(50)
+)
+RETURNS TABLE (
+    activity VARCHAR(20),
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    amount DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT 
+        fn_cash_flow_activity(a.type, a.code) AS activity,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(SUM(ti.credit - ti.debit), 0)::DECIMAL(15, 2) AS amount
+    FROM transactions t
+    INNER JOIN transaction_items ti ON ti.transaction_id = t.id
+    INNER JOIN accounts a ON ti.account_id = a.id
+    WHERE t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND a.code <> cash_account_code
+    GROUP BY a.id, a.code, a.name, a.type
+    HAVING COALESCE(SUM(ti.credit - ti.debit), 0) <> 0
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Accounts Receivable Aging Report
+-- Applies each customer's receipts to their oldest sales first (FIFO) and buckets the
+-- remaining open amounts by days past due, where a sale falls due terms_days after
+-- its transaction date. Receipts exceeding sales are returned as unapplied credit.
+CREATE OR REPLACE FUNCTION sp_ar_aging(
+    as_of_date DATE,
+    terms_days INTEGER DEFAULT 30
+)
+RETURNS TABLE (
+    customer_id UUID,
+    customer_name VARCHAR(255),
+    current_amount DECIMAL(15, 2),
+    days_1_30 DECIMAL(15, 2),
+    days_31_60 DECIMAL(15, 2),
+    days_61_90 DECIMAL(15, 2),
+    days_over_90 DECIMAL(15, 2),
+    total_open DECIMAL(15, 2),
+    unapplied_credit DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    WITH sales AS (
+        SELECT 
+            t.customer_id AS cust_id,
+            t.transaction_date AS sale_date,
+            t.total_amount AS sale_amount,
+            SUM(t.total_amount) OVER (
+                PARTITION BY t.customer_id
+                ORDER BY t.transaction_date, t.id
+            ) AS cumulative_sales
+        FROM transactions t
+        WHERE t.transaction_type = 'sale'
+            AND t.customer_id IS NOT NULL
+            AND t.transaction_date <= as_of_date
+    ),
+    receipts AS (
+        SELECT t.customer_id AS cust_id, SUM(t.total_amount) AS received
+        FROM transactions t
+        WHERE t.transaction_type = 'receipt'
+            AND t.customer_id IS NOT NULL
+            AND t.transaction_date <= as_of_date
+        GROUP BY t.customer_id
+    ),
+    open_sales AS (
+        SELECT 
+            s.cust_id,
+            as_of_date - (s.sale_date + terms_days) AS days_past_due,
+            LEAST(s.sale_amount, GREATEST(s.cumulative_sales - COALESCE(r.received, 0), 0)) AS open_amount
+        FROM sales s
+        LEFT JOIN receipts r ON r.cust_id = s.cust_id
+    ),
+    buckets AS (
+        SELECT 
+            o.cust_id,
+            SUM(CASE WHEN o.days_past_due <= 0 THEN o.open_amount ELSE 0 END) AS b_current,
+            SUM(CASE WHEN o.days_past_due BETWEEN 1 AND 30 THEN o.open_amount ELSE 0 END) AS b_1_30,
+            SUM(CASE WHEN o.days_past_due BETWEEN 31 AND 60 THEN o.open_amount ELSE 0 END) AS b_31_60,
+            SUM(CASE WHEN o.days_past_due BETWEEN 61 AND 90 THEN o.open_amount ELSE 0 END) AS b_61_90,
+            SUM(CASE WHEN o.days_past_due > 90 THEN o.open_amount ELSE 0 END) AS b_over_90,
+            SUM(o.open_amount) AS b_total
+        FROM open_sales o
+        WHERE o.open_amount > 0
+        GROUP BY o.cust_id
+    ),
+    credits AS (
+        SELECT r.cust_id, r.received - COALESCE(SUM(s.sale_amount), 0) AS unapplied
+        FROM receipts r
+        LEFT JOIN sales s ON s.cust_id = r.cust_id
+        GROUP BY r.cust_id, r.received
+        HAVING r.received > COALESCE(SUM(s.sale_amount), 0)
+    )
+    SELECT 
+        c.id AS customer_id,
+        c.name AS customer_name,
+        COALESCE(b.b_current, 0)::DECIMAL(15, 2) AS current_amount,
+        COALESCE(b.b_1_30, 0)::DECIMAL(15, 2) AS days_1_30,
+        COALESCE(b.b_31_60, 0)::DECIMAL(15, 2) AS days_31_60,
+        COALESCE(b.b_61_90, 0)::DECIMAL(15, 2) AS days_61_90,
+        COALESCE(b.b_over_90, 0)::DECIMAL(15, 2) AS days_over_90,
+        COALESCE(b.b_total, 0)::DECIMAL(15, 2) AS total_open,
+        COALESCE(cr.unapplied, 0)::DECIMAL(15, 2) AS unapplied_credit
+    FROM customers c
+    LEFT JOIN buckets b ON b.cust_id = c.id
+    LEFT JOIN credits cr ON cr.cust_id = c.id
+    WHERE b.cust_id IS NOT NULL OR cr.cust_id IS NOT NULL
+    ORDER BY COALESCE(b.b_total, 0) DESC, c.name;
+END;
+$$ LANGUAGE plpgsql;
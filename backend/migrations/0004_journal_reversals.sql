@@ -0,0 +1,182 @@
+-- Journal Entry Reversals
+-- Posted transactions are immutable: mistakes are corrected by posting a mirror
+-- 'adjustment' transaction that links back to the original through reversal_of.
+
+ALTER TABLE transactions
+    ADD COLUMN reversal_of UUID REFERENCES transactions(id) ON DELETE RESTRICT;
+
+-- A transaction can be reversed at most once
+CREATE UNIQUE INDEX idx_transactions_reversal_of ON transactions(reversal_of) WHERE reversal_of IS NOT NULL;
+
+-- Block edits and deletes of posted transactions and their lines
+CREATE OR REPLACE FUNCTION fn_prevent_posted_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'posted % rows cannot be modified or deleted', TG_TABLE_NAME
+        USING ERRCODE = 'FR001',
+              HINT = 'Post a reversal through POST /api/journal-entries/:id/reverse instead.';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_transactions_immutable
+    BEFORE UPDATE OR DELETE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION fn_prevent_posted_changes();
+
+CREATE TRIGGER trg_transaction_items_immutable
+    BEFORE UPDATE OR DELETE ON transaction_items
+    FOR EACH ROW EXECUTE FUNCTION fn_prevent_posted_changes();
+
+-- ON DELETE SET NULL is carried out as an UPDATE of the referencing rows, which the
+-- triggers above reject with a misleading FR001. A customer or category still used by
+-- posted transactions therefore cannot be deleted; the caller gets a plain foreign key
+-- violation instead.
+ALTER TABLE transactions
+    DROP CONSTRAINT transactions_customer_id_fkey,
+    ADD CONSTRAINT transactions_customer_id_fkey
+        FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE RESTRICT;
+
+ALTER TABLE transaction_items
+    DROP CONSTRAINT transaction_items_category_id_fkey,
+    ADD CONSTRAINT transaction_items_category_id_fkey
+        FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT;
+
+-- Profit & Loss Report
+-- Adds reversal_count so reversed and reversing entries are visible in the totals.
+-- Only lines of transactions inside the date range are summed.
+DROP FUNCTION IF EXISTS sp_profit_loss(DATE, DATE);
+
+CREATE FUNCTION sp_profit_loss(
+    start_date DATE,
+    end_date DATE
+)
+RETURNS TABLE (
+    category_name VARCHAR(255),
+    category_type VARCHAR(50),
+    total_amount DECIMAL(15, 2),
+    transaction_count BIGINT,
+    reversal_count BIGINT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        c.name AS category_name,
+        c.type AS category_type,
+        COALESCE(
+            SUM(CASE
+                WHEN c.type = 'revenue' THEN m.credit - m.debit
+                WHEN c.type = 'expense' THEN m.debit - m.credit
+                ELSE 0
+            END),
+            0
+        )::DECIMAL(15, 2) AS total_amount,
+        COUNT(DISTINCT m.transaction_id) AS transaction_count,
+        COUNT(DISTINCT m.transaction_id) FILTER (WHERE m.is_reversal) AS reversal_count
+    FROM categories c
+    LEFT JOIN (
+        SELECT ti.category_id, ti.debit, ti.credit, t.id AS transaction_id, t.reversal_of IS NOT NULL AS is_reversal
+        FROM transaction_items ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+    ) m ON m.category_id = c.id
+    WHERE c.type IN ('revenue', 'expense')
+    GROUP BY c.id, c.name, c.type
+    HAVING COALESCE(
+        SUM(CASE
+            WHEN c.type = 'revenue' THEN m.credit - m.debit
+            WHEN c.type = 'expense' THEN m.debit - m.credit
+            ELSE 0
+        END),
+        0
+    ) != 0
+    ORDER BY c.type, c.name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- General Ledger Lines
+-- Adds reversal_of and reversed_by so both sides of a reversal link to each other.
+DROP FUNCTION IF EXISTS sp_general_ledger(VARCHAR, DATE, DATE, DATE, UUID, INTEGER);
+
+CREATE FUNCTION sp_general_ledger(
+    p_account_code VARCHAR(50),
+    start_date DATE,
+    end_date DATE,
+    after_date DATE,
+    after_item_id UUID,
+    page_size INTEGER
+)
+RETURNS TABLE (
+    item_id UUID,
+    transaction_id UUID,
+    transaction_date DATE,
+    reference_number VARCHAR(100),
+    description TEXT,
+    debit DECIMAL(15, 2),
+    credit DECIMAL(15, 2),
+    running_balance DECIMAL(15, 2),
+    reversal_of UUID,
+    reversed_by UUID
+) AS $$
+DECLARE
+    v_account_id UUID;
+    v_sign INTEGER;
+    v_opening DECIMAL(15, 2);
+BEGIN
+    SELECT a.id, CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+    INTO v_account_id, v_sign
+    FROM accounts a
+    WHERE a.code = p_account_code;
+
+    IF v_account_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    SELECT v_sign * COALESCE(SUM(ti.debit - ti.credit), 0)
+    INTO v_opening
+    FROM transaction_items ti
+    INNER JOIN transactions t ON ti.transaction_id = t.id
+    WHERE ti.account_id = v_account_id
+        AND (
+            t.transaction_date < start_date
+            OR (
+                after_date IS NOT NULL
+                AND t.transaction_date <= end_date
+                AND (t.transaction_date, ti.id) <= (after_date, after_item_id)
+            )
+        );
+
+    RETURN QUERY
+    SELECT
+        p.item_id,
+        p.transaction_id,
+        p.transaction_date,
+        p.reference_number,
+        p.description,
+        p.debit,
+        p.credit,
+        (v_opening + SUM(v_sign * (p.debit - p.credit)) OVER (ORDER BY p.transaction_date, p.item_id))::DECIMAL(15, 2) AS running_balance,
+        p.reversal_of,
+        r.id AS reversed_by
+    FROM (
+        SELECT
+            ti.id AS item_id,
+            t.id AS transaction_id,
+            t.transaction_date,
+            t.reference_number,
+            COALESCE(t.description, ti.description) AS description,
+            ti.debit,
+            ti.credit,
+            t.reversal_of
+        FROM transaction_items ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE ti.account_id = v_account_id
+            AND t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+            AND (after_date IS NULL OR (t.transaction_date, ti.id) > (after_date, after_item_id))
+        ORDER BY t.transaction_date, ti.id
+        LIMIT page_size
+    ) p
+    LEFT JOIN transactions r ON r.reversal_of = p.transaction_id
+    ORDER BY p.transaction_date, p.item_id;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,73 @@
+-- Accounting Periods
+-- One row per calendar month that has left the default 'open' state.
+--   open        - any transaction may be posted
+--   soft_closed - only 'adjustment' transactions may be posted
+--   closed      - nothing may be posted on or before the period end
+-- Closing snapshots the trial balance into period_balances and records the
+-- retained earnings carried forward into equity.
+CREATE TABLE accounting_periods (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    period_start DATE NOT NULL UNIQUE,
+    period_end DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'soft_closed', 'closed')),
+    net_income DECIMAL(15, 2),
+    retained_earnings DECIMAL(15, 2),
+    closed_at TIMESTAMP WITH TIME ZONE,
+    closed_by VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_period_month CHECK (
+        period_start = DATE_TRUNC('month', period_start)::DATE
+        AND period_end = (DATE_TRUNC('month', period_start) + INTERVAL '1 month - 1 day')::DATE
+    )
+);
+
+-- Trial balance snapshot taken when a period is closed (debit minus credit)
+CREATE TABLE period_balances (
+    period_id UUID NOT NULL REFERENCES accounting_periods(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE RESTRICT,
+    opening_balance DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    period_debit DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    period_credit DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    closing_balance DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (period_id, account_id)
+);
+
+CREATE INDEX idx_accounting_periods_status_end ON accounting_periods(status, period_end);
+
+-- Posting status for a transaction date. A closed period locks every date up to
+-- its end, so earlier months cannot change the retained earnings it carried forward.
+CREATE OR REPLACE FUNCTION fn_period_status(
+    p_date DATE
+)
+RETURNS VARCHAR(20) AS $$
+    SELECT CASE
+        WHEN EXISTS (
+            SELECT 1 FROM accounting_periods
+            WHERE status = 'closed' AND period_end >= p_date
+        ) THEN 'closed'
+        ELSE COALESCE(
+            (SELECT status FROM accounting_periods WHERE period_start = DATE_TRUNC('month', p_date)::DATE),
+            'open'
+        )
+    END::VARCHAR(20);
+$$ LANGUAGE sql STABLE;
+
+-- Reject postings into closed (and, except adjustments, soft-closed) periods
+CREATE OR REPLACE FUNCTION fn_check_period_open()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_status VARCHAR(20);
+BEGIN
+    v_status := fn_period_status(NEW.transaction_date);
+    IF v_status = 'closed' OR (v_status = 'soft_closed' AND NEW.transaction_type <> 'adjustment') THEN
+        RAISE EXCEPTION 'accounting period for % is %', NEW.transaction_date, v_status
+            USING ERRCODE = 'FR002';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_transactions_period_open
+    BEFORE INSERT ON transactions
+    FOR EACH ROW EXECUTE FUNCTION fn_check_period_open();
@@ -0,0 +1,85 @@
+-- Revenue & Expense Time Series
+-- Buckets activity by day, week, month, quarter or year between start_date and end_date.
+-- Every bucket of every series is returned, zero-filled when there was no activity, so
+-- charts have no gaps. The first bucket starts at the truncated start_date.
+--   p_metric: revenue | expense | net | transaction_count
+--   p_split:  NULL (one 'total' series) | category | transaction_type
+CREATE OR REPLACE FUNCTION sp_timeseries(
+    start_date DATE,
+    end_date DATE,
+    p_granularity VARCHAR(10),
+    p_metric VARCHAR(20),
+    p_split VARCHAR(20)
+)
+RETURNS TABLE (
+    series_name VARCHAR(255),
+    bucket_start DATE,
+    bucket_end DATE,
+    value DECIMAL(15, 2)
+) AS $$
+DECLARE
+    v_step INTERVAL;
+BEGIN
+    v_step := CASE p_granularity
+        WHEN 'quarter' THEN INTERVAL '3 months'
+        ELSE ('1 ' || p_granularity)::INTERVAL
+    END;
+
+    RETURN QUERY
+    WITH buckets AS (
+        SELECT
+            b::DATE AS bs,
+            (b + v_step - INTERVAL '1 day')::DATE AS be
+        FROM generate_series(DATE_TRUNC(p_granularity, start_date::TIMESTAMP), end_date::TIMESTAMP, v_step) AS b
+    ),
+    lines AS (
+        SELECT
+            DATE_TRUNC(p_granularity, t.transaction_date::TIMESTAMP)::DATE AS bs,
+            (CASE p_split
+                WHEN 'category' THEN c.name
+                WHEN 'transaction_type' THEN t.transaction_type
+                ELSE 'total'
+            END)::VARCHAR(255) AS gn,
+            t.id AS txn_id,
+            -- Revenue is credit-normal and expense debit-normal, so credit minus
+            -- debit across both gives net income
+            CASE WHEN p_metric = 'expense' THEN ti.debit - ti.credit ELSE ti.credit - ti.debit END AS amount
+        FROM transactions t
+        INNER JOIN transaction_items ti ON ti.transaction_id = t.id
+        LEFT JOIN categories c ON ti.category_id = c.id
+        WHERE t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+            AND (
+                p_metric = 'transaction_count'
+                OR (p_metric = 'net' AND c.id IS NOT NULL)
+                OR c.type = p_metric
+            )
+            AND (p_split IS DISTINCT FROM 'category' OR c.id IS NOT NULL)
+    ),
+    groups AS (
+        SELECT DISTINCT l.gn FROM lines l
+        UNION
+        SELECT 'total'::VARCHAR(255) WHERE p_split IS NULL
+    ),
+    totals AS (
+        SELECT
+            l.bs,
+            l.gn,
+            CASE
+                WHEN p_metric = 'transaction_count' THEN COUNT(DISTINCT l.txn_id)::DECIMAL(15, 2)
+                ELSE SUM(l.amount)
+            END AS total
+        FROM lines l
+        GROUP BY l.bs, l.gn
+    )
+    SELECT
+        g.gn,
+        b.bs,
+        b.be,
+        COALESCE(x.total, 0)::DECIMAL(15, 2)
+    FROM groups g
+    CROSS JOIN buckets b
+    LEFT JOIN totals x ON x.gn = g.gn AND x.bs = b.bs
+    ORDER BY g.gn, b.bs;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -0,0 +1,21 @@
+-- Budgets
+-- One planned amount per month for either a category or an account. Amounts are
+-- expressed on the line's normal side (revenue earned, expense incurred), so they
+-- compare directly with sp_profit_loss and the trial balance.
+CREATE TABLE budgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    period_start DATE NOT NULL,
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+    account_id UUID REFERENCES accounts(id) ON DELETE CASCADE,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount >= 0),
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_budget_target CHECK ((category_id IS NULL) <> (account_id IS NULL)),
+    CONSTRAINT check_budget_month CHECK (period_start = DATE_TRUNC('month', period_start)::DATE)
+);
+
+-- At most one budget per category or account per month
+CREATE UNIQUE INDEX idx_budgets_category_month ON budgets(category_id, period_start) WHERE category_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_budgets_account_month ON budgets(account_id, period_start) WHERE account_id IS NOT NULL;
+CREATE INDEX idx_budgets_period_start ON budgets(period_start);
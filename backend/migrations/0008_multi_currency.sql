@@ -0,0 +1,833 @@
+-- Multi-Currency
+-- Every transaction belongs to a ledger with a base currency. Debits and credits
+-- stay in the ledger's base currency so every existing report and the double-entry
+-- checks keep working; the document currency, the rate used at posting and the
+-- original amounts are kept alongside. Original amounts are NULL when a
+-- transaction was posted in the ledger's base currency.
+
+CREATE TABLE ledgers (
+    code VARCHAR(20) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    base_currency CHAR(3) NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Exactly one ledger is the default; reports use its base currency unless asked otherwise
+CREATE UNIQUE INDEX idx_ledgers_default ON ledgers(is_default) WHERE is_default;
+
+INSERT INTO ledgers (code, name, base_currency, is_default) VALUES ('MAIN', 'Main Ledger', 'IDR', TRUE);
+
+-- One unit of base_currency is worth rate units of quote_currency from rate_date on
+CREATE TABLE fx_rates (
+    base_currency CHAR(3) NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    quote_currency CHAR(3) NOT NULL CHECK (quote_currency ~ '^[A-Z]{3}$'),
+    rate_date DATE NOT NULL,
+    rate DECIMAL(18, 8) NOT NULL CHECK (rate > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (base_currency, quote_currency, rate_date),
+    CONSTRAINT check_fx_pair CHECK (base_currency <> quote_currency)
+);
+
+ALTER TABLE transactions
+    ADD COLUMN ledger_code VARCHAR(20) NOT NULL DEFAULT 'MAIN' REFERENCES ledgers(code) ON DELETE RESTRICT,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' CHECK (currency ~ '^[A-Z]{3}$'),
+    ADD COLUMN fx_rate DECIMAL(18, 8) NOT NULL DEFAULT 1 CHECK (fx_rate > 0),
+    ADD COLUMN original_amount DECIMAL(15, 2);
+
+ALTER TABLE transaction_items
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' CHECK (currency ~ '^[A-Z]{3}$'),
+    ADD COLUMN original_debit DECIMAL(15, 2),
+    ADD COLUMN original_credit DECIMAL(15, 2);
+
+CREATE INDEX idx_transactions_currency ON transactions(currency) WHERE original_amount IS NOT NULL;
+
+-- Exchange rate from one currency to another on a date: the most recent rate on or
+-- before the date, stored directly or as the inverse of the opposite pair.
+CREATE OR REPLACE FUNCTION fn_fx_rate(
+    p_from CHAR(3),
+    p_to CHAR(3),
+    p_date DATE
+)
+RETURNS NUMERIC AS $$
+DECLARE
+    v_rate NUMERIC;
+BEGIN
+    IF p_from = p_to THEN
+        RETURN 1;
+    END IF;
+
+    SELECT x.rate INTO v_rate
+    FROM (
+        SELECT r.rate_date, r.rate::NUMERIC AS rate
+        FROM fx_rates r
+        WHERE r.base_currency = p_from AND r.quote_currency = p_to AND r.rate_date <= p_date
+        UNION ALL
+        SELECT r.rate_date, 1 / r.rate::NUMERIC
+        FROM fx_rates r
+        WHERE r.base_currency = p_to AND r.quote_currency = p_from AND r.rate_date <= p_date
+    ) x
+    ORDER BY x.rate_date DESC
+    LIMIT 1;
+
+    IF v_rate IS NULL THEN
+        RAISE EXCEPTION 'no exchange rate from % to % on or before %', p_from, p_to, p_date
+            USING ERRCODE = 'FR003';
+    END IF;
+
+    RETURN v_rate;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Transactions with the rate that converts their ledger's base currency into the
+-- reporting currency on their date. A NULL reporting currency means the default
+-- ledger's base currency. Ledgers already in the reporting currency get 1 without
+-- looking at fx_rates, so base-currency reports pay only a join with ledgers;
+-- other ledgers find the rate fn_fx_rate would by an index lookup. Being a single
+-- SQL query, the function is inlined into the reports reading it rather than
+-- called per row.
+CREATE OR REPLACE FUNCTION fn_converted_transactions(
+    p_currency CHAR(3)
+)
+RETURNS TABLE (
+    id UUID,
+    customer_id UUID,
+    transaction_date DATE,
+    transaction_type VARCHAR(50),
+    total_amount NUMERIC,
+    rate NUMERIC
+) AS $$
+    SELECT
+        t.id,
+        t.customer_id,
+        t.transaction_date,
+        t.transaction_type,
+        t.total_amount,
+        CASE
+            WHEN l.base_currency = d.currency THEN 1
+            -- Without a rate, fn_fx_rate raises the missing rate error
+            ELSE COALESCE(x.rate, fn_fx_rate(l.base_currency, d.currency, t.transaction_date))
+        END
+    FROM transactions t
+    INNER JOIN ledgers l ON l.code = t.ledger_code
+    CROSS JOIN (SELECT COALESCE(p_currency, dl.base_currency) AS currency FROM ledgers dl WHERE dl.is_default) d
+    LEFT JOIN LATERAL (
+        SELECT y.rate
+        FROM (
+            (SELECT r.rate_date, r.rate::NUMERIC AS rate
+            FROM fx_rates r
+            WHERE l.base_currency <> d.currency
+                AND r.base_currency = l.base_currency AND r.quote_currency = d.currency
+                AND r.rate_date <= t.transaction_date
+            ORDER BY r.rate_date DESC
+            LIMIT 1)
+            UNION ALL
+            (SELECT r.rate_date, 1 / r.rate::NUMERIC
+            FROM fx_rates r
+            WHERE l.base_currency <> d.currency
+                AND r.base_currency = d.currency AND r.quote_currency = l.base_currency
+                AND r.rate_date <= t.transaction_date
+            ORDER BY r.rate_date DESC
+            LIMIT 1)
+        ) y
+        ORDER BY y.rate_date DESC
+        LIMIT 1
+    ) x ON TRUE;
+$$ LANGUAGE sql STABLE;
+
+-- Transaction lines with debits and credits converted into the reporting currency
+-- at the rate on each transaction's date. Whole transactions share one rate, so
+-- converted entries still balance.
+CREATE OR REPLACE FUNCTION fn_converted_items(
+    p_currency CHAR(3)
+)
+RETURNS TABLE (
+    id UUID,
+    transaction_id UUID,
+    account_id UUID,
+    category_id UUID,
+    debit NUMERIC,
+    credit NUMERIC,
+    description TEXT
+) AS $$
+    SELECT
+        ti.id,
+        ti.transaction_id,
+        ti.account_id,
+        ti.category_id,
+        ti.debit * t.rate,
+        ti.credit * t.rate,
+        ti.description
+    FROM transaction_items ti
+    INNER JOIN fn_converted_transactions(p_currency) t ON ti.transaction_id = t.id;
+$$ LANGUAGE sql STABLE;
+
+-- The report procedures below are redefined with a trailing reporting_currency
+-- argument (NULL for the default ledger's base currency). Their bodies are
+-- unchanged apart from reading fn_converted_items instead of transaction_items,
+-- or fn_converted_transactions instead of transactions for transaction totals.
+
+-- Profit & Loss Report
+DROP FUNCTION IF EXISTS sp_profit_loss(DATE, DATE);
+
+CREATE FUNCTION sp_profit_loss(
+    start_date DATE,
+    end_date DATE,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    category_name VARCHAR(255),
+    category_type VARCHAR(50),
+    total_amount DECIMAL(15, 2),
+    transaction_count BIGINT,
+    reversal_count BIGINT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        c.name AS category_name,
+        c.type AS category_type,
+        COALESCE(
+            SUM(CASE
+                WHEN c.type = 'revenue' THEN m.credit - m.debit
+                WHEN c.type = 'expense' THEN m.debit - m.credit
+                ELSE 0
+            END),
+            0
+        )::DECIMAL(15, 2) AS total_amount,
+        COUNT(DISTINCT m.transaction_id) AS transaction_count,
+        COUNT(DISTINCT m.transaction_id) FILTER (WHERE m.is_reversal) AS reversal_count
+    FROM categories c
+    LEFT JOIN (
+        SELECT ti.category_id, ti.debit, ti.credit, t.id AS transaction_id, t.reversal_of IS NOT NULL AS is_reversal
+        FROM fn_converted_items(reporting_currency) ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+    ) m ON m.category_id = c.id
+    WHERE c.type IN ('revenue', 'expense')
+    GROUP BY c.id, c.name, c.type
+    HAVING COALESCE(
+        SUM(CASE
+            WHEN c.type = 'revenue' THEN m.credit - m.debit
+            WHEN c.type = 'expense' THEN m.debit - m.credit
+            ELSE 0
+        END),
+        0
+    ) != 0
+    ORDER BY c.type, c.name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Trial Balance Report
+DROP FUNCTION IF EXISTS sp_trial_balance(DATE, DATE);
+
+CREATE FUNCTION sp_trial_balance(
+    start_date DATE,
+    end_date DATE,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    account_id UUID,
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    opening_balance DECIMAL(15, 2),
+    period_debit DECIMAL(15, 2),
+    period_credit DECIMAL(15, 2),
+    closing_balance DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        a.id AS account_id,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(SUM(CASE WHEN m.transaction_date < start_date THEN m.debit - m.credit ELSE 0 END), 0)::DECIMAL(15, 2) AS opening_balance,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.debit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_debit,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.credit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_credit,
+        COALESCE(SUM(m.debit - m.credit), 0)::DECIMAL(15, 2) AS closing_balance
+    FROM accounts a
+    LEFT JOIN (
+        SELECT ti.account_id, ti.debit, ti.credit, t.transaction_date
+        FROM fn_converted_items(reporting_currency) ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date <= end_date
+    ) m ON m.account_id = a.id
+    GROUP BY a.id, a.code, a.name, a.type
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Revenue by Category Report
+DROP FUNCTION IF EXISTS sp_revenue_by_category(DATE, DATE);
+
+CREATE FUNCTION sp_revenue_by_category(
+    start_date DATE,
+    end_date DATE,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    category_name VARCHAR(255),
+    revenue_amount DECIMAL(15, 2),
+    transaction_count BIGINT,
+    average_transaction DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        c.name AS category_name,
+        COALESCE(SUM(ti.credit - ti.debit), 0)::DECIMAL(15, 2) AS revenue_amount,
+        COUNT(DISTINCT t.id) AS transaction_count,
+        (CASE
+            WHEN COUNT(DISTINCT t.id) > 0
+            THEN COALESCE(SUM(ti.credit - ti.debit), 0) / COUNT(DISTINCT t.id)
+            ELSE 0
+        END)::DECIMAL(15, 2) AS average_transaction
+    FROM categories c
+    INNER JOIN fn_converted_items(reporting_currency) ti ON ti.category_id = c.id
+    INNER JOIN transactions t ON ti.transaction_id = t.id
+    WHERE c.type = 'revenue'
+        AND t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND ti.credit > 0
+    GROUP BY c.id, c.name
+    HAVING COALESCE(SUM(ti.credit - ti.debit), 0) > 0
+    ORDER BY revenue_amount DESC;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Top Customers Report
+DROP FUNCTION IF EXISTS sp_top_customers(DATE, DATE, INTEGER);
+
+CREATE FUNCTION sp_top_customers(
+    start_date DATE,
+    end_date DATE,
+    limit_count INTEGER DEFAULT 10,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    customer_id UUID,
+    customer_name VARCHAR(255),
+    total_revenue DECIMAL(15, 2),
+    transaction_count BIGINT,
+    average_transaction DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        c.id AS customer_id,
+        c.name AS customer_name,
+        COALESCE(SUM(t.amount), 0)::DECIMAL(15, 2) AS total_revenue,
+        COUNT(t.id) AS transaction_count,
+        (CASE
+            WHEN COUNT(t.id) > 0
+            THEN COALESCE(SUM(t.amount), 0) / COUNT(t.id)
+            ELSE 0
+        END)::DECIMAL(15, 2) AS average_transaction
+    FROM customers c
+    INNER JOIN (
+        SELECT
+            tx.id,
+            tx.customer_id,
+            tx.total_amount * tx.rate AS amount
+        FROM fn_converted_transactions(reporting_currency) tx
+        WHERE tx.transaction_date >= start_date
+            AND tx.transaction_date <= end_date
+            AND tx.transaction_type IN ('sale', 'receipt')
+    ) t ON t.customer_id = c.id
+    GROUP BY c.id, c.name
+    ORDER BY total_revenue DESC
+    LIMIT limit_count;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Balance Sheet Report
+DROP FUNCTION IF EXISTS sp_balance_sheet(DATE);
+
+CREATE FUNCTION sp_balance_sheet(
+    as_of_date DATE,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    account_id UUID,
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    balance DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        a.id AS account_id,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(
+            SUM(CASE
+                WHEN a.type IN ('asset', 'expense') THEN ti.debit - ti.credit
+                ELSE ti.credit - ti.debit
+            END),
+            0
+        )::DECIMAL(15, 2) AS balance
+    FROM accounts a
+    LEFT JOIN (
+        SELECT ti.account_id, ti.debit, ti.credit
+        FROM fn_converted_items(reporting_currency) ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date <= as_of_date
+    ) ti ON ti.account_id = a.id
+    GROUP BY a.id, a.code, a.name, a.type
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
+-- General Ledger Summary
+DROP FUNCTION IF EXISTS sp_account_ledger_summary(VARCHAR, DATE, DATE);
+
+CREATE FUNCTION sp_account_ledger_summary(
+    p_account_code VARCHAR(50),
+    start_date DATE,
+    end_date DATE,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    account_id UUID,
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    opening_balance DECIMAL(15, 2),
+    period_debit DECIMAL(15, 2),
+    period_credit DECIMAL(15, 2),
+    closing_balance DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        a.id AS account_id,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        (CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+            * COALESCE(SUM(CASE WHEN m.transaction_date < start_date THEN m.debit - m.credit ELSE 0 END), 0))::DECIMAL(15, 2) AS opening_balance,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.debit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_debit,
+        COALESCE(SUM(CASE WHEN m.transaction_date >= start_date THEN m.credit ELSE 0 END), 0)::DECIMAL(15, 2) AS period_credit,
+        (CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+            * COALESCE(SUM(m.debit - m.credit), 0))::DECIMAL(15, 2) AS closing_balance
+    FROM accounts a
+    LEFT JOIN (
+        SELECT ti.account_id, ti.debit, ti.credit, t.transaction_date
+        FROM fn_converted_items(reporting_currency) ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE t.transaction_date <= end_date
+    ) m ON m.account_id = a.id
+    WHERE a.code = p_account_code
+    GROUP BY a.id, a.code, a.name, a.type;
+END;
+$$ LANGUAGE plpgsql;
+
+-- General Ledger Lines
+DROP FUNCTION IF EXISTS sp_general_ledger(VARCHAR, DATE, DATE, DATE, UUID, INTEGER);
+
+CREATE FUNCTION sp_general_ledger(
+    p_account_code VARCHAR(50),
+    start_date DATE,
+    end_date DATE,
+    after_date DATE,
+    after_item_id UUID,
+    page_size INTEGER,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    item_id UUID,
+    transaction_id UUID,
+    transaction_date DATE,
+    reference_number VARCHAR(100),
+    description TEXT,
+    debit DECIMAL(15, 2),
+    credit DECIMAL(15, 2),
+    running_balance DECIMAL(15, 2),
+    reversal_of UUID,
+    reversed_by UUID
+) AS $$
+DECLARE
+    v_account_id UUID;
+    v_sign INTEGER;
+    v_opening NUMERIC;
+BEGIN
+    SELECT a.id, CASE WHEN a.type IN ('asset', 'expense') THEN 1 ELSE -1 END
+    INTO v_account_id, v_sign
+    FROM accounts a
+    WHERE a.code = p_account_code;
+
+    IF v_account_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    SELECT v_sign * COALESCE(SUM(ti.debit - ti.credit), 0)
+    INTO v_opening
+    FROM fn_converted_items(reporting_currency) ti
+    INNER JOIN transactions t ON ti.transaction_id = t.id
+    WHERE ti.account_id = v_account_id
+        AND (
+            t.transaction_date < start_date
+            OR (
+                after_date IS NOT NULL
+                AND t.transaction_date <= end_date
+                AND (t.transaction_date, ti.id) <= (after_date, after_item_id)
+            )
+        );
+
+    RETURN QUERY
+    SELECT
+        p.item_id,
+        p.transaction_id,
+        p.transaction_date,
+        p.reference_number,
+        p.description,
+        p.debit::DECIMAL(15, 2),
+        p.credit::DECIMAL(15, 2),
+        (v_opening + SUM(v_sign * (p.debit - p.credit)) OVER (ORDER BY p.transaction_date, p.item_id))::DECIMAL(15, 2) AS running_balance,
+        p.reversal_of,
+        r.id AS reversed_by
+    FROM (
+        SELECT
+            ti.id AS item_id,
+            t.id AS transaction_id,
+            t.transaction_date,
+            t.reference_number,
+            COALESCE(t.description, ti.description) AS description,
+            ti.debit,
+            ti.credit,
+            t.reversal_of
+        FROM fn_converted_items(reporting_currency) ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        WHERE ti.account_id = v_account_id
+            AND t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+            AND (after_date IS NULL OR (t.transaction_date, ti.id) > (after_date, after_item_id))
+        ORDER BY t.transaction_date, ti.id
+        LIMIT page_size
+    ) p
+    LEFT JOIN transactions r ON r.reversal_of = p.transaction_id
+    ORDER BY p.transaction_date, p.item_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Cash Flow Statement (direct method)
+DROP FUNCTION IF EXISTS sp_cash_flow_direct(DATE, DATE, VARCHAR);
+
+CREATE FUNCTION sp_cash_flow_direct(
+    start_date DATE,
+    end_date DATE,
+    cash_account_code VARCHAR(50),
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    activity VARCHAR(20),
+    transaction_type VARCHAR(50),
+    receipts DECIMAL(15, 2),
+    payments DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        fn_cash_flow_activity(a.type, a.code) AS activity,
+        t.transaction_type,
+        COALESCE(SUM(GREATEST(ti.credit - ti.debit, 0)), 0)::DECIMAL(15, 2) AS receipts,
+        COALESCE(SUM(GREATEST(ti.debit - ti.credit, 0)), 0)::DECIMAL(15, 2) AS payments
+    FROM transactions t
+    INNER JOIN fn_converted_items(reporting_currency) ti ON ti.transaction_id = t.id
+    INNER JOIN accounts a ON ti.account_id = a.id
+    WHERE t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND a.code <> cash_account_code
+        AND EXISTS (
+            SELECT 1
+            FROM transaction_items cti
+            INNER JOIN accounts ca ON cti.account_id = ca.id
+            WHERE cti.transaction_id = t.id
+                AND ca.code = cash_account_code
+        )
+    GROUP BY 1, t.transaction_type
+    ORDER BY 1, t.transaction_type;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Cash Flow Statement (indirect method)
+DROP FUNCTION IF EXISTS sp_cash_flow_indirect(DATE, DATE, VARCHAR);
+
+CREATE FUNCTION sp_cash_flow_indirect(
+    start_date DATE,
+    end_date DATE,
+    cash_account_code VARCHAR(50),
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    activity VARCHAR(20),
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    amount DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        fn_cash_flow_activity(a.type, a.code) AS activity,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(SUM(ti.credit - ti.debit), 0)::DECIMAL(15, 2) AS amount
+    FROM transactions t
+    INNER JOIN fn_converted_items(reporting_currency) ti ON ti.transaction_id = t.id
+    INNER JOIN accounts a ON ti.account_id = a.id
+    WHERE t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND a.code <> cash_account_code
+    GROUP BY a.id, a.code, a.name, a.type
+    HAVING COALESCE(SUM(ti.credit - ti.debit), 0) <> 0
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Accounts Receivable Aging Report
+DROP FUNCTION IF EXISTS sp_ar_aging(DATE, INTEGER);
+
+CREATE FUNCTION sp_ar_aging(
+    as_of_date DATE,
+    terms_days INTEGER DEFAULT 30,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    customer_id UUID,
+    customer_name VARCHAR(255),
+    current_amount DECIMAL(15, 2),
+    days_1_30 DECIMAL(15, 2),
+    days_31_60 DECIMAL(15, 2),
+    days_61_90 DECIMAL(15, 2),
+    days_over_90 DECIMAL(15, 2),
+    total_open DECIMAL(15, 2),
+    unapplied_credit DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    WITH converted AS (
+        SELECT
+            t.id,
+            t.customer_id,
+            t.transaction_date,
+            t.transaction_type,
+            t.total_amount * t.rate AS amount
+        FROM fn_converted_transactions(reporting_currency) t
+        WHERE t.transaction_type IN ('sale', 'receipt')
+            AND t.customer_id IS NOT NULL
+            AND t.transaction_date <= as_of_date
+    ),
+    sales AS (
+        SELECT
+            t.customer_id AS cust_id,
+            t.transaction_date AS sale_date,
+            t.amount AS sale_amount,
+            SUM(t.amount) OVER (
+                PARTITION BY t.customer_id
+                ORDER BY t.transaction_date, t.id
+            ) AS cumulative_sales
+        FROM converted t
+        WHERE t.transaction_type = 'sale'
+    ),
+    receipts AS (
+        SELECT t.customer_id AS cust_id, SUM(t.amount) AS received
+        FROM converted t
+        WHERE t.transaction_type = 'receipt'
+        GROUP BY t.customer_id
+    ),
+    open_sales AS (
+        SELECT
+            s.cust_id,
+            as_of_date - (s.sale_date + terms_days) AS days_past_due,
+            LEAST(s.sale_amount, GREATEST(s.cumulative_sales - COALESCE(r.received, 0), 0)) AS open_amount
+        FROM sales s
+        LEFT JOIN receipts r ON r.cust_id = s.cust_id
+    ),
+    buckets AS (
+        SELECT
+            o.cust_id,
+            SUM(CASE WHEN o.days_past_due <= 0 THEN o.open_amount ELSE 0 END) AS b_current,
+            SUM(CASE WHEN o.days_past_due BETWEEN 1 AND 30 THEN o.open_amount ELSE 0 END) AS b_1_30,
+            SUM(CASE WHEN o.days_past_due BETWEEN 31 AND 60 THEN o.open_amount ELSE 0 END) AS b_31_60,
+            SUM(CASE WHEN o.days_past_due BETWEEN 61 AND 90 THEN o.open_amount ELSE 0 END) AS b_61_90,
+            SUM(CASE WHEN o.days_past_due > 90 THEN o.open_amount ELSE 0 END) AS b_over_90,
+            SUM(o.open_amount) AS b_total
+        FROM open_sales o
+        WHERE o.open_amount > 0
+        GROUP BY o.cust_id
+    ),
+    credits AS (
+        SELECT r.cust_id, r.received - COALESCE(SUM(s.sale_amount), 0) AS unapplied
+        FROM receipts r
+        LEFT JOIN sales s ON s.cust_id = r.cust_id
+        GROUP BY r.cust_id, r.received
+        HAVING r.received > COALESCE(SUM(s.sale_amount), 0)
+    )
+    SELECT
+        c.id AS customer_id,
+        c.name AS customer_name,
+        COALESCE(b.b_current, 0)::DECIMAL(15, 2) AS current_amount,
+        COALESCE(b.b_1_30, 0)::DECIMAL(15, 2) AS days_1_30,
+        COALESCE(b.b_31_60, 0)::DECIMAL(15, 2) AS days_31_60,
+        COALESCE(b.b_61_90, 0)::DECIMAL(15, 2) AS days_61_90,
+        COALESCE(b.b_over_90, 0)::DECIMAL(15, 2) AS days_over_90,
+        COALESCE(b.b_total, 0)::DECIMAL(15, 2) AS total_open,
+        COALESCE(cr.unapplied, 0)::DECIMAL(15, 2) AS unapplied_credit
+    FROM customers c
+    LEFT JOIN buckets b ON b.cust_id = c.id
+    LEFT JOIN credits cr ON cr.cust_id = c.id
+    WHERE b.cust_id IS NOT NULL OR cr.cust_id IS NOT NULL
+    ORDER BY c.name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Revenue & Expense Time Series
+DROP FUNCTION IF EXISTS sp_timeseries(DATE, DATE, VARCHAR, VARCHAR, VARCHAR);
+
+CREATE FUNCTION sp_timeseries(
+    start_date DATE,
+    end_date DATE,
+    p_granularity VARCHAR(10),
+    p_metric VARCHAR(20),
+    p_split VARCHAR(20),
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    series_name VARCHAR(255),
+    bucket_start DATE,
+    bucket_end DATE,
+    value DECIMAL(15, 2)
+) AS $$
+DECLARE
+    v_step INTERVAL;
+BEGIN
+    v_step := CASE p_granularity
+        WHEN 'quarter' THEN INTERVAL '3 months'
+        ELSE ('1 ' || p_granularity)::INTERVAL
+    END;
+
+    RETURN QUERY
+    WITH buckets AS (
+        SELECT
+            b::DATE AS bs,
+            (b + v_step - INTERVAL '1 day')::DATE AS be
+        FROM generate_series(DATE_TRUNC(p_granularity, start_date::TIMESTAMP), end_date::TIMESTAMP, v_step) AS b
+    ),
+    lines AS (
+        SELECT
+            DATE_TRUNC(p_granularity, t.transaction_date::TIMESTAMP)::DATE AS bs,
+            (CASE p_split
+                WHEN 'category' THEN c.name
+                WHEN 'transaction_type' THEN t.transaction_type
+                ELSE 'total'
+            END)::VARCHAR(255) AS gn,
+            t.id AS txn_id,
+            CASE WHEN p_metric = 'expense' THEN ti.debit - ti.credit ELSE ti.credit - ti.debit END AS amount
+        FROM transactions t
+        INNER JOIN fn_converted_items(reporting_currency) ti ON ti.transaction_id = t.id
+        LEFT JOIN categories c ON ti.category_id = c.id
+        WHERE t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+            AND (
+                p_metric = 'transaction_count'
+                OR (p_metric = 'net' AND c.id IS NOT NULL)
+                OR c.type = p_metric
+            )
+            AND (p_split IS DISTINCT FROM 'category' OR c.id IS NOT NULL)
+    ),
+    groups AS (
+        SELECT DISTINCT l.gn FROM lines l
+        UNION
+        SELECT 'total'::VARCHAR(255) WHERE p_split IS NULL
+    ),
+    totals AS (
+        SELECT
+            l.bs,
+            l.gn,
+            CASE
+                WHEN p_metric = 'transaction_count' THEN COUNT(DISTINCT l.txn_id)::DECIMAL(15, 2)
+                ELSE SUM(l.amount)
+            END AS total
+        FROM lines l
+        GROUP BY l.bs, l.gn
+    )
+    SELECT
+        g.gn,
+        b.bs,
+        b.be,
+        COALESCE(x.total, 0)::DECIMAL(15, 2)
+    FROM groups g
+    CROSS JOIN buckets b
+    LEFT JOIN totals x ON x.gn = g.gn AND x.bs = b.bs
+    ORDER BY g.gn, b.bs;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Unrealized FX Revaluation of Accounts Receivable
+-- For every customer with an open balance in a currency other than its ledger's base
+-- currency, revalues the open foreign amount (sales minus receipts) at the rate on
+-- as_of_date and compares it with the base amount booked at the original rates.
+-- A positive unrealized_gain_loss is a gain.
+CREATE OR REPLACE FUNCTION sp_fx_revaluation(
+    as_of_date DATE
+)
+RETURNS TABLE (
+    customer_id UUID,
+    customer_name VARCHAR(255),
+    ledger_code VARCHAR(20),
+    base_currency CHAR(3),
+    currency CHAR(3),
+    open_amount DECIMAL(15, 2),
+    booked_amount DECIMAL(15, 2),
+    revaluation_rate DECIMAL(18, 8),
+    revalued_amount DECIMAL(15, 2),
+    unrealized_gain_loss DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    WITH open_balances AS (
+        SELECT
+            t.customer_id AS cust_id,
+            t.ledger_code AS ledger,
+            l.base_currency AS base,
+            t.currency AS cur,
+            SUM(CASE WHEN t.transaction_type = 'sale' THEN t.original_amount ELSE -t.original_amount END) AS open_foreign,
+            SUM(CASE WHEN t.transaction_type = 'sale' THEN t.total_amount ELSE -t.total_amount END) AS booked
+        FROM transactions t
+        INNER JOIN ledgers l ON t.ledger_code = l.code
+        WHERE t.transaction_type IN ('sale', 'receipt')
+            AND t.customer_id IS NOT NULL
+            AND t.original_amount IS NOT NULL
+            AND t.currency <> l.base_currency
+            AND t.transaction_date <= as_of_date
+        GROUP BY t.customer_id, t.ledger_code, l.base_currency, t.currency
+        HAVING SUM(CASE WHEN t.transaction_type = 'sale' THEN t.original_amount ELSE -t.original_amount END) <> 0
+    ),
+    revalued AS (
+        SELECT o.*, fn_fx_rate(o.cur, o.base, as_of_date) AS rate
+        FROM open_balances o
+    )
+    SELECT
+        c.id,
+        c.name,
+        r.ledger,
+        r.base,
+        r.cur,
+        r.open_foreign::DECIMAL(15, 2),
+        r.booked::DECIMAL(15, 2),
+        r.rate::DECIMAL(18, 8),
+        (r.open_foreign * r.rate)::DECIMAL(15, 2),
+        (r.open_foreign * r.rate - r.booked)::DECIMAL(15, 2)
+    FROM revalued r
+    INNER JOIN customers c ON c.id = r.cust_id
+    ORDER BY c.name, r.cur;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -0,0 +1,33 @@
+-- Report Jobs
+-- Reports too large to compute within an HTTP request run in the background on the
+-- API's worker pool. Jobs are kept here so they survive a restart: a worker leases a
+-- job while running it and renews the lease as it goes, so a job whose worker died
+-- is picked up again once its lease expires. The finished report is stored in the
+-- requested format until the job is deleted, by its creator or by the API once the
+-- job is older than REPORT_JOB_RETENTION (see 0015).
+
+CREATE TABLE report_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_type VARCHAR(50) NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    format VARCHAR(10) NOT NULL CHECK (format IN ('json', 'csv', 'xlsx', 'pdf')),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    progress SMALLINT NOT NULL DEFAULT 0 CHECK (progress BETWEEN 0 AND 100),
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL CHECK (max_attempts > 0),
+    last_error TEXT,
+    -- Earliest time of the next attempt; retries are backed off
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lease_expires_at TIMESTAMP WITH TIME ZONE,
+    result BYTEA,
+    content_type VARCHAR(255),
+    file_name VARCHAR(255),
+    created_by VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Workers look for queued jobs that are due and running jobs whose lease expired
+CREATE INDEX idx_report_jobs_queued ON report_jobs(run_at) WHERE status = 'queued';
+CREATE INDEX idx_report_jobs_running ON report_jobs(lease_expires_at) WHERE status = 'running';
@@ -0,0 +1,48 @@
+-- Report Schedules
+-- A schedule queues a report job on a cron expression and emails the result to its
+-- recipients. next_run_at is advanced in the same transaction that records the
+-- delivery and queues the job, and each (schedule, scheduled_for) pair is unique, so
+-- several API processes, or one restarted mid-run, never send a scheduled run twice.
+
+-- Scheduled jobs resolve named periods such as last_month against the date they were
+-- scheduled for rather than the date a worker happens to run them
+ALTER TABLE report_jobs ADD COLUMN reference_date DATE;
+
+CREATE TABLE report_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(200) NOT NULL,
+    -- Five-field cron expression evaluated in timezone
+    cron_expr VARCHAR(100) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    report_type VARCHAR(50) NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    format VARCHAR(10) NOT NULL CHECK (format IN ('json', 'csv', 'xlsx', 'pdf')),
+    recipients TEXT[] NOT NULL CHECK (cardinality(recipients) > 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_report_schedules_due ON report_schedules(next_run_at) WHERE enabled;
+
+CREATE TABLE schedule_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    schedule_id UUID NOT NULL REFERENCES report_schedules(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    job_id UUID REFERENCES report_jobs(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    -- Recipients at the time of the run; the schedule may change later
+    recipients TEXT[] NOT NULL,
+    -- Earliest time of the next send attempt; failed sends are backed off
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (schedule_id, scheduled_for)
+);
+
+CREATE INDEX idx_schedule_deliveries_pending ON schedule_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_schedule_deliveries_history ON schedule_deliveries(schedule_id, scheduled_for DESC);
@@ -0,0 +1,29 @@
+-- Saved Reports
+-- A saved report stores a report type with its period expression, filters and limit
+-- so it can be run again in one request. Period expressions such as last_30_days are
+-- stored as written and resolved on every run. Owners may share a saved report with
+-- other users, who can run it but not change it.
+
+CREATE TABLE saved_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner VARCHAR(100) NOT NULL REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+    name VARCHAR(200) NOT NULL,
+    report_type VARCHAR(50) NOT NULL,
+    period VARCHAR(50),
+    -- Query parameters of the report's endpoint other than period and limit
+    filters JSONB NOT NULL DEFAULT '{}',
+    row_limit INT CHECK (row_limit > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner, name)
+);
+
+CREATE TABLE saved_report_shares (
+    saved_report_id UUID NOT NULL REFERENCES saved_reports(id) ON DELETE CASCADE,
+    username VARCHAR(100) NOT NULL REFERENCES users(username) ON DELETE CASCADE ON UPDATE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (saved_report_id, username)
+);
+
+-- Users list the reports shared with them
+CREATE INDEX idx_saved_report_shares_username ON saved_report_shares(username);
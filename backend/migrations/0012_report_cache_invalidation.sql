@@ -0,0 +1,90 @@
+-- Report Cache Invalidation
+-- Every statement that writes transactions or their lines notifies the
+-- report_cache channel with its schema and the range of transaction dates it
+-- touched; each API process evicts the cached reports covering that range.
+-- Posted rows are immutable, so this is mostly inserts, but data fixes made
+-- with fn_prevent_posted_changes disabled are covered too. A reversal also
+-- touches the date of the entry it reverses, whose ledger line now shows it.
+-- Notifications are sent on commit, and Postgres delivers identical payloads
+-- of one transaction once, so an entry and its lines notify once.
+
+CREATE OR REPLACE FUNCTION fn_notify_report_cache()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_from DATE;
+    v_through DATE;
+    v_old_from DATE;
+    v_old_through DATE;
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        IF TG_TABLE_NAME = 'transactions' THEN
+            SELECT MIN(d.transaction_date), MAX(d.transaction_date) INTO v_from, v_through
+            FROM (
+                SELECT n.transaction_date FROM new_rows n
+                UNION ALL
+                SELECT t.transaction_date FROM new_rows n JOIN transactions t ON t.id = n.reversal_of
+            ) d;
+        ELSE
+            SELECT MIN(t.transaction_date), MAX(t.transaction_date) INTO v_from, v_through
+            FROM new_rows n JOIN transactions t ON t.id = n.transaction_id;
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        IF TG_TABLE_NAME = 'transactions' THEN
+            SELECT MIN(d.transaction_date), MAX(d.transaction_date) INTO v_old_from, v_old_through
+            FROM (
+                SELECT o.transaction_date FROM old_rows o
+                UNION ALL
+                SELECT t.transaction_date FROM old_rows o JOIN transactions t ON t.id = o.reversal_of
+            ) d;
+        ELSE
+            SELECT MIN(t.transaction_date), MAX(t.transaction_date) INTO v_old_from, v_old_through
+            FROM old_rows o JOIN transactions t ON t.id = o.transaction_id;
+        END IF;
+        -- LEAST and GREATEST ignore NULLs
+        v_from := LEAST(v_from, v_old_from);
+        v_through := GREATEST(v_through, v_old_through);
+    END IF;
+
+    IF v_from IS NOT NULL THEN
+        PERFORM pg_notify('report_cache', json_build_object(
+            'schema', TG_TABLE_SCHEMA,
+            'from', v_from,
+            'through', v_through
+        )::TEXT);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Transition tables allow one event per trigger, hence three per table
+CREATE TRIGGER trg_transactions_report_cache_insert
+    AFTER INSERT ON transactions
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache();
+
+CREATE TRIGGER trg_transactions_report_cache_update
+    AFTER UPDATE ON transactions
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache();
+
+CREATE TRIGGER trg_transactions_report_cache_delete
+    AFTER DELETE ON transactions
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache();
+
+CREATE TRIGGER trg_transaction_items_report_cache_insert
+    AFTER INSERT ON transaction_items
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache();
+
+CREATE TRIGGER trg_transaction_items_report_cache_update
+    AFTER UPDATE ON transaction_items
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache();
+
+CREATE TRIGGER trg_transaction_items_report_cache_delete
+    AFTER DELETE ON transaction_items
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache();
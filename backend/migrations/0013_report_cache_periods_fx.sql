@@ -0,0 +1,96 @@
+-- Report Cache Invalidation for Periods and Exchange Rates
+-- Closing, soft-closing or reopening a period changes the snapshot-backed
+-- trial balances and balance sheets and the retained earnings carried
+-- forward, and a new or changed rate changes every converted figure from its
+-- date on. Both notify the report_cache channel like fn_notify_report_cache,
+-- with an open end: every cached report dated on or after the first day they
+-- touch is evicted.
+
+CREATE OR REPLACE FUNCTION fn_notify_report_cache_from()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_from DATE;
+    v_old_from DATE;
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        IF TG_TABLE_NAME = 'accounting_periods' THEN
+            SELECT MIN(n.period_start) INTO v_from FROM new_rows n;
+        ELSIF TG_TABLE_NAME = 'period_balances' THEN
+            SELECT MIN(p.period_start) INTO v_from
+            FROM new_rows n JOIN accounting_periods p ON p.id = n.period_id;
+        ELSE
+            SELECT MIN(n.rate_date) INTO v_from FROM new_rows n;
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        IF TG_TABLE_NAME = 'accounting_periods' THEN
+            SELECT MIN(o.period_start) INTO v_old_from FROM old_rows o;
+        ELSIF TG_TABLE_NAME = 'period_balances' THEN
+            -- The period row may be gone already when deletes cascade
+            SELECT COALESCE(MIN(p.period_start), '-infinity') INTO v_old_from
+            FROM old_rows o LEFT JOIN accounting_periods p ON p.id = o.period_id;
+        ELSE
+            SELECT MIN(o.rate_date) INTO v_old_from FROM old_rows o;
+        END IF;
+        -- LEAST ignores NULLs
+        v_from := LEAST(v_from, v_old_from);
+    END IF;
+
+    IF v_from IS NOT NULL THEN
+        PERFORM pg_notify('report_cache', json_build_object(
+            'schema', TG_TABLE_SCHEMA,
+            -- An unknown start evicts every report
+            'from', CASE WHEN isfinite(v_from) THEN v_from END,
+            'through', NULL
+        )::TEXT);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Transition tables allow one event per trigger, hence three per table
+CREATE TRIGGER trg_accounting_periods_report_cache_insert
+    AFTER INSERT ON accounting_periods
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_accounting_periods_report_cache_update
+    AFTER UPDATE ON accounting_periods
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_accounting_periods_report_cache_delete
+    AFTER DELETE ON accounting_periods
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_period_balances_report_cache_insert
+    AFTER INSERT ON period_balances
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_period_balances_report_cache_update
+    AFTER UPDATE ON period_balances
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_period_balances_report_cache_delete
+    AFTER DELETE ON period_balances
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_fx_rates_report_cache_insert
+    AFTER INSERT ON fx_rates
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_fx_rates_report_cache_update
+    AFTER UPDATE ON fx_rates
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
+
+CREATE TRIGGER trg_fx_rates_report_cache_delete
+    AFTER DELETE ON fx_rates
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION fn_notify_report_cache_from();
@@ -0,0 +1,13 @@
+-- Schedule Delivery Leases
+-- A delivery is claimed, and the claim committed, before its email is sent, so no
+-- transaction or row lock is held during the SMTP conversation. A claimed delivery is
+-- 'sending' until the outcome is recorded. If its process stops first, the lease
+-- expires and another process sends it again, so an interrupted email may arrive twice.
+
+ALTER TABLE schedule_deliveries DROP CONSTRAINT schedule_deliveries_status_check;
+ALTER TABLE schedule_deliveries ADD CONSTRAINT schedule_deliveries_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'failed'));
+
+ALTER TABLE schedule_deliveries ADD COLUMN lease_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_schedule_deliveries_sending ON schedule_deliveries(lease_expires_at) WHERE status = 'sending';
@@ -0,0 +1,5 @@
+-- Report Job Retention
+-- Finished jobs are deleted with their results once they are older than
+-- REPORT_JOB_RETENTION; the API looks for them every hour.
+
+CREATE INDEX idx_report_jobs_finished ON report_jobs(finished_at) WHERE status IN ('succeeded', 'failed');
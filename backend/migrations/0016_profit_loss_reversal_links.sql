@@ -0,0 +1,64 @@
+-- Profit & Loss Reversal Links
+-- Adds reversals: for each category, the transactions in range that reverse another
+-- or were reversed, with reversal_of and reversed_by as in the general ledger, so
+-- both sides of a reversal can be matched up from the P&L as well.
+DROP FUNCTION IF EXISTS sp_profit_loss(DATE, DATE, CHAR(3));
+
+CREATE FUNCTION sp_profit_loss(
+    start_date DATE,
+    end_date DATE,
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    category_name VARCHAR(255),
+    category_type VARCHAR(50),
+    total_amount DECIMAL(15, 2),
+    transaction_count BIGINT,
+    reversal_count BIGINT,
+    reversals JSONB
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        c.name AS category_name,
+        c.type AS category_type,
+        COALESCE(
+            SUM(CASE
+                WHEN c.type = 'revenue' THEN m.credit - m.debit
+                WHEN c.type = 'expense' THEN m.debit - m.credit
+                ELSE 0
+            END),
+            0
+        )::DECIMAL(15, 2) AS total_amount,
+        COUNT(DISTINCT m.transaction_id) AS transaction_count,
+        COUNT(DISTINCT m.transaction_id) FILTER (WHERE m.reversal_of IS NOT NULL) AS reversal_count,
+        COALESCE(
+            jsonb_agg(DISTINCT jsonb_build_object(
+                'transaction_id', m.transaction_id,
+                'reversal_of', m.reversal_of,
+                'reversed_by', m.reversed_by
+            )) FILTER (WHERE m.reversal_of IS NOT NULL OR m.reversed_by IS NOT NULL),
+            '[]'::JSONB
+        ) AS reversals
+    FROM categories c
+    LEFT JOIN (
+        SELECT ti.category_id, ti.debit, ti.credit, t.id AS transaction_id, t.reversal_of, r.id AS reversed_by
+        FROM fn_converted_items(reporting_currency) ti
+        INNER JOIN transactions t ON ti.transaction_id = t.id
+        LEFT JOIN transactions r ON r.reversal_of = t.id
+        WHERE t.transaction_date >= start_date
+            AND t.transaction_date <= end_date
+    ) m ON m.category_id = c.id
+    WHERE c.type IN ('revenue', 'expense')
+    GROUP BY c.id, c.name, c.type
+    HAVING COALESCE(
+        SUM(CASE
+            WHEN c.type = 'revenue' THEN m.credit - m.debit
+            WHEN c.type = 'expense' THEN m.debit - m.credit
+            ELSE 0
+        END),
+        0
+    ) != 0
+    ORDER BY c.type, c.name;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,116 @@
+-- Account Cash Flow Activity
+-- The cash flow statements classified the counter-account of a cash movement by comparing
+-- its code, as a string, against the seed chart's ranges. Each account now carries its
+-- activity explicitly. Accounts inserted without one get the default for their type:
+-- revenue, expense, asset and liability accounts are operating, equity is financing.
+-- Long-term assets (investing) and long-term debt (financing) must be set explicitly.
+
+CREATE OR REPLACE FUNCTION fn_default_cash_flow_activity(p_account_type VARCHAR(50))
+RETURNS VARCHAR(20) AS $$
+    SELECT CASE
+        WHEN p_account_type = 'equity' THEN 'financing'
+        ELSE 'operating'
+    END::VARCHAR(20);
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE accounts ADD COLUMN cash_flow_activity VARCHAR(20)
+    CHECK (cash_flow_activity IN ('operating', 'investing', 'financing'));
+
+-- Existing accounts keep the activity they had: numeric asset codes from 1500 and
+-- liability codes from 2500 are the long-term ranges of the seed chart. Codes that are
+-- not plain numbers take the default for their type.
+UPDATE accounts SET cash_flow_activity = CASE
+    WHEN type = 'asset' AND code ~ '^[0-9]+$' AND code::NUMERIC >= 1500 THEN 'investing'
+    WHEN type = 'liability' AND code ~ '^[0-9]+$' AND code::NUMERIC >= 2500 THEN 'financing'
+    ELSE fn_default_cash_flow_activity(type)
+END;
+
+CREATE OR REPLACE FUNCTION fn_set_default_cash_flow_activity()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.cash_flow_activity IS NULL THEN
+        NEW.cash_flow_activity := fn_default_cash_flow_activity(NEW.type);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_accounts_cash_flow_activity
+    BEFORE INSERT OR UPDATE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION fn_set_default_cash_flow_activity();
+
+ALTER TABLE accounts ALTER COLUMN cash_flow_activity SET NOT NULL;
+
+-- Cash Flow Statement (direct method)
+CREATE OR REPLACE FUNCTION sp_cash_flow_direct(
+    start_date DATE,
+    end_date DATE,
+    cash_account_code VARCHAR(50),
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    activity VARCHAR(20),
+    transaction_type VARCHAR(50),
+    receipts DECIMAL(15, 2),
+    payments DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        a.cash_flow_activity AS activity,
+        t.transaction_type,
+        COALESCE(SUM(GREATEST(ti.credit - ti.debit, 0)), 0)::DECIMAL(15, 2) AS receipts,
+        COALESCE(SUM(GREATEST(ti.debit - ti.credit, 0)), 0)::DECIMAL(15, 2) AS payments
+    FROM transactions t
+    INNER JOIN fn_converted_items(reporting_currency) ti ON ti.transaction_id = t.id
+    INNER JOIN accounts a ON ti.account_id = a.id
+    WHERE t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND a.code <> cash_account_code
+        AND EXISTS (
+            SELECT 1
+            FROM transaction_items cti
+            INNER JOIN accounts ca ON cti.account_id = ca.id
+            WHERE cti.transaction_id = t.id
+                AND ca.code = cash_account_code
+        )
+    GROUP BY 1, t.transaction_type
+    ORDER BY 1, t.transaction_type;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Cash Flow Statement (indirect method)
+CREATE OR REPLACE FUNCTION sp_cash_flow_indirect(
+    start_date DATE,
+    end_date DATE,
+    cash_account_code VARCHAR(50),
+    reporting_currency CHAR(3) DEFAULT NULL
+)
+RETURNS TABLE (
+    activity VARCHAR(20),
+    account_code VARCHAR(50),
+    account_name VARCHAR(255),
+    account_type VARCHAR(50),
+    amount DECIMAL(15, 2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        a.cash_flow_activity AS activity,
+        a.code AS account_code,
+        a.name AS account_name,
+        a.type AS account_type,
+        COALESCE(SUM(ti.credit - ti.debit), 0)::DECIMAL(15, 2) AS amount
+    FROM transactions t
+    INNER JOIN fn_converted_items(reporting_currency) ti ON ti.transaction_id = t.id
+    INNER JOIN accounts a ON ti.account_id = a.id
+    WHERE t.transaction_date >= start_date
+        AND t.transaction_date <= end_date
+        AND a.code <> cash_account_code
+    GROUP BY a.id, a.code, a.name, a.type, a.cash_flow_activity
+    HAVING COALESCE(SUM(ti.credit - ti.debit), 0) <> 0
+    ORDER BY a.code;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS fn_cash_flow_activity(VARCHAR, VARCHAR);